@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/sddev/weight-tracker/config"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/logging"
+	"github.com/sddev/weight-tracker/synth"
+)
+
+const adminUsage = `Usage: weight-tracker-api [command] [flags]
+
+Commands:
+  serve               Run the API server (the default)
+  migrate             Create or migrate the database schema
+  backup              Copy the database to the backup directory
+  restore <file>      Replace the database with a backup; stop the server first
+  vacuum              Reclaim free space and refresh query statistics
+  integrity-check     Check the database for corruption
+  seed                Add generated weight entries (--days N, --trend, --seed S)
+  wipe --confirm      Permanently remove every weight entry, tag and the goal
+
+Every command takes the server's configuration flags, e.g. --database-path
+and --backup-dir. Run "weight-tracker-api <command> --help" to list them.
+`
+
+// adminFunc runs an admin command with the remaining positional arguments
+type adminFunc func(cfg *config.Config, args []string, out io.Writer) error
+
+// adminCommands declare each command's own flags and return its runner
+var adminCommands = map[string]func(fs *flag.FlagSet) adminFunc{
+	"migrate":         migrateCommand,
+	"backup":          backupCommand,
+	"restore":         restoreCommand,
+	"vacuum":          vacuumCommand,
+	"integrity-check": integrityCheckCommand,
+	"seed":            seedCommand,
+	"wipe":            wipeCommand,
+}
+
+// runAdmin runs a maintenance command directly on the configured database
+// and returns the process exit code
+func runAdmin(name string, args []string, stdout, stderr io.Writer) int {
+	if name == "help" {
+		fmt.Fprint(stdout, adminUsage)
+		return 0
+	}
+	declare, ok := adminCommands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "weight-tracker-api: unknown command %q\n\n%s", name, adminUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("weight-tracker-api "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	run := declare(fs)
+
+	cfg, err := config.LoadFlagSet(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "weight-tracker-api %s: %v\n", name, err)
+		return 2
+	}
+
+	// Logs go to stderr so that stdout holds only the command's output
+	logger, err := logging.New(stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fmt.Fprintf(stderr, "weight-tracker-api %s: %v\n", name, err)
+		return 2
+	}
+	slog.SetDefault(logger)
+
+	if err := run(cfg, fs.Args(), stdout); err != nil {
+		fmt.Fprintf(stderr, "weight-tracker-api %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// withDatabase opens the configured database for fn and closes it after
+func withDatabase(cfg *config.Config, fn func() error) error {
+	if err := openDatabase(cfg); err != nil {
+		return err
+	}
+	err := fn()
+	if closeErr := db.CloseDB(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// requireSQLite fails commands that work on the database file
+func requireSQLite(cfg *config.Config, command string) error {
+	if cfg.UsesPostgres() {
+		return fmt.Errorf("%s works on SQLite databases only; use the PostgreSQL tools instead", command)
+	}
+	return nil
+}
+
+// noArgs fails commands given positional arguments
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %q", args)
+	}
+	return nil
+}
+
+// migrateCommand creates or migrates the schema
+func migrateCommand(fs *flag.FlagSet) adminFunc {
+	return func(cfg *config.Config, args []string, out io.Writer) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		return withDatabase(cfg, func() error {
+			version, err := db.SchemaVersion()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Schema version %d\n", version)
+			return nil
+		})
+	}
+}
+
+// backupCommand copies the database to the backup directory
+func backupCommand(fs *flag.FlagSet) adminFunc {
+	return func(cfg *config.Config, args []string, out io.Writer) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		if err := requireSQLite(cfg, "backup"); err != nil {
+			return err
+		}
+		return withDatabase(cfg, func() error {
+			path, err := db.Backup(cfg.BackupDir)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Backed up to %s\n", path)
+			return nil
+		})
+	}
+}
+
+// restoreCommand replaces the database with a backup, first backing up the
+// database it replaces
+func restoreCommand(fs *flag.FlagSet) adminFunc {
+	return func(cfg *config.Config, args []string, out io.Writer) error {
+		if len(args) != 1 {
+			return errors.New("expected the backup file to restore")
+		}
+		if err := requireSQLite(cfg, "restore"); err != nil {
+			return err
+		}
+
+		if info, err := os.Stat(cfg.DatabasePath); err == nil && info.Size() > 0 {
+			err := withDatabase(cfg, func() error {
+				path, err := db.Backup(cfg.BackupDir)
+				if err == nil {
+					fmt.Fprintf(out, "Backed up the current database to %s\n", path)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := db.Restore(args[0], cfg.DatabasePath); err != nil {
+			return err
+		}
+
+		// Bring an older backup up to the current schema
+		return withDatabase(cfg, func() error {
+			version, err := db.SchemaVersion()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Restored %s (schema version %d)\n", args[0], version)
+			return nil
+		})
+	}
+}
+
+// vacuumCommand reclaims free space
+func vacuumCommand(fs *flag.FlagSet) adminFunc {
+	return func(cfg *config.Config, args []string, out io.Writer) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		return withDatabase(cfg, func() error {
+			before, err := db.Size()
+			if err != nil {
+				return err
+			}
+			if err := db.Vacuum(); err != nil {
+				return err
+			}
+			after, err := db.Size()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Vacuumed: %d bytes before, %d bytes after\n", before, after)
+			return nil
+		})
+	}
+}
+
+// integrityCheckCommand reports corruption, failing if any is found
+func integrityCheckCommand(fs *flag.FlagSet) adminFunc {
+	return func(cfg *config.Config, args []string, out io.Writer) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		if err := requireSQLite(cfg, "integrity-check"); err != nil {
+			return err
+		}
+		return withDatabase(cfg, func() error {
+			problems, err := db.IntegrityCheck()
+			if err != nil {
+				return err
+			}
+			for _, problem := range problems {
+				fmt.Fprintln(out, problem)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("found %d problems", len(problems))
+			}
+			fmt.Fprintln(out, "ok")
+			return nil
+		})
+	}
+}
+
+// seedCommand adds generated weight entries up to today
+func seedCommand(fs *flag.FlagSet) adminFunc {
+	days := fs.Int("days", 50, "number of days to generate, ending today")
+	trend := fs.Bool("trend", false, "lose weight gradually over the period instead of holding steady")
+	seed := fs.Int64("seed", 0, "random seed, to generate the same entries again (default random)")
+	return func(cfg *config.Config, args []string, out io.Writer) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		if *days < 1 {
+			return fmt.Errorf("--days must be at least 1, got %d", *days)
+		}
+		// Any seed given, including 0, is used as is
+		seeded := false
+		fs.Visit(func(f *flag.Flag) {
+			seeded = seeded || f.Name == "seed"
+		})
+		if !seeded {
+			*seed = time.Now().UnixNano()
+		}
+
+		// The server checks dates against today in UTC
+		opts := synth.DefaultOptions()
+		opts.Seed = *seed
+		opts.Days = *days
+		opts.Start = time.Now().UTC().AddDate(0, 0, 1-*days)
+		if !*trend {
+			opts.TrendPoundsPerWeek, opts.GoalPounds = 0, 0
+		}
+
+		generated := synth.Generate(opts)
+		weights := make([]db.SeedWeight, len(generated))
+		for i, entry := range generated {
+			weights[i] = db.SeedWeight{Date: entry.Date, Pounds: entry.Pounds}
+		}
+
+		return withDatabase(cfg, func() error {
+			added, err := db.Seed(weights)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Added %d entries, skipped %d dates that already had one (seed %d)\n", added, len(weights)-added, *seed)
+			return nil
+		})
+	}
+}
+
+// wipeCommand permanently removes all data
+func wipeCommand(fs *flag.FlagSet) adminFunc {
+	confirm := fs.Bool("confirm", false, "confirm that every weight entry, tag and the goal should be removed")
+	return func(cfg *config.Config, args []string, out io.Writer) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		if !*confirm {
+			return errors.New("this permanently removes every weight entry, tag and the goal; rerun with --confirm")
+		}
+		return withDatabase(cfg, func() error {
+			result, err := db.Wipe()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Removed %d weight entries and %d tags", result.Weights, result.Tags)
+			if result.GoalCleared {
+				fmt.Fprint(out, " and cleared the goal")
+			}
+			fmt.Fprintln(out)
+			return nil
+		})
+	}
+}
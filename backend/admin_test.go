@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sddev/weight-tracker/db"
+)
+
+// runAdminTest runs an admin command on the database in dir and returns its
+// exit code and standard output
+func runAdminTest(t *testing.T, dir, command string, args ...string) (int, string) {
+	t.Helper()
+	t.Setenv("DATABASE_URL", "")
+	args = append([]string{
+		"--database-path", filepath.Join(dir, "weights.db"),
+		"--backup-dir", filepath.Join(dir, "backups"),
+		"--log-level", "error",
+	}, args...)
+
+	var stdout, stderr bytes.Buffer
+	code := runAdmin(command, args, &stdout, &stderr)
+	if code != 0 {
+		t.Logf("%s: %s", command, stderr.String())
+	}
+	return code, stdout.String()
+}
+
+func TestAdmin_Maintenance(t *testing.T) {
+	dir := t.TempDir()
+
+	if code, out := runAdminTest(t, dir, "migrate"); code != 0 || !strings.HasPrefix(out, "Schema version ") {
+		t.Fatalf("migrate: exit %d, output %q", code, out)
+	}
+
+	code, out := runAdminTest(t, dir, "seed", "--days", "30", "--trend", "--seed", "7")
+	var added, skipped int
+	if _, err := fmt.Sscanf(out, "Added %d entries, skipped %d", &added, &skipped); code != 0 || err != nil || added == 0 {
+		t.Fatalf("seed: exit %d, output %q", code, out)
+	}
+	// The same seed generates the same entries, whose dates are now taken
+	want := fmt.Sprintf("Added 0 entries, skipped %d", added)
+	if code, out := runAdminTest(t, dir, "seed", "--days", "30", "--trend", "--seed", "7"); code != 0 || !strings.HasPrefix(out, want) {
+		t.Errorf("seed again: exit %d, output %q", code, out)
+	}
+
+	code, out = runAdminTest(t, dir, "backup")
+	if code != 0 || !strings.HasPrefix(out, "Backed up to ") {
+		t.Fatalf("backup: exit %d, output %q", code, out)
+	}
+	backup := strings.TrimSpace(strings.TrimPrefix(out, "Backed up to "))
+
+	for _, command := range []string{"vacuum", "integrity-check"} {
+		if code, out := runAdminTest(t, dir, command); code != 0 {
+			t.Errorf("%s: exit %d, output %q", command, code, out)
+		}
+	}
+
+	// Wiping needs confirmation
+	if code, _ := runAdminTest(t, dir, "wipe"); code != 1 {
+		t.Errorf("wipe without --confirm: expected exit 1, got %d", code)
+	}
+	if code, out := runAdminTest(t, dir, "wipe", "--confirm"); code != 0 || !strings.HasPrefix(out, fmt.Sprintf("Removed %d weight entries", added)) {
+		t.Errorf("wipe: exit %d, output %q", code, out)
+	}
+
+	// Backups made less than a second apart would share a name
+	time.Sleep(time.Second)
+	code, out = runAdminTest(t, dir, "restore", backup)
+	if code != 0 || !strings.Contains(out, "Backed up the current database") || !strings.Contains(out, "Restored ") {
+		t.Fatalf("restore: exit %d, output %q", code, out)
+	}
+
+	// The restored backup holds the entries from before the wipe, and the
+	// wiped database was itself backed up first
+	if code, out := runAdminTest(t, dir, "seed", "--days", "30", "--trend", "--seed", "7"); code != 0 || !strings.HasPrefix(out, want) {
+		t.Errorf("seed after restore: exit %d, output %q", code, out)
+	}
+	backups, _ := os.ReadDir(filepath.Join(dir, "backups"))
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 backups, got %d", len(backups))
+	}
+}
+
+func TestAdmin_SeedEndsTodayInUTC(t *testing.T) {
+	dir := t.TempDir()
+
+	// Pick a local zone whose date differs from UTC's right now
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	now := time.Now().UTC()
+	if now.Hour() < 12 {
+		time.Local = time.FixedZone("UTC-12", -12*60*60)
+	} else {
+		time.Local = time.FixedZone("UTC+13", 13*60*60)
+	}
+
+	// Seed 0 is a seed like any other, not a request for a random one
+	code, out := runAdminTest(t, dir, "seed", "--days", "1", "--seed", "0")
+	if code != 0 || !strings.HasSuffix(strings.TrimSpace(out), "(seed 0)") {
+		t.Fatalf("seed: exit %d, output %q", code, out)
+	}
+
+	if err := db.InitDB(filepath.Join(dir, "weights.db"), db.DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer db.CloseDB()
+
+	var date string
+	if err := db.DB.QueryRow("SELECT MAX(date) FROM weights").Scan(&date); err != nil {
+		t.Fatal(err)
+	}
+	if today := time.Now().UTC().Format("2006-01-02"); date != today {
+		t.Errorf("Expected the last seeded date to be %s, got %s", today, date)
+	}
+}
+
+func TestAdmin_Usage(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		command string
+		args    []string
+		code    int
+	}{
+		{"unknown", nil, 2},
+		{"seed", []string{"--days", "0"}, 1},
+		{"seed", []string{"--no-such-flag"}, 2},
+		{"restore", nil, 1},
+		{"migrate", []string{"extra"}, 1},
+		{"backup", []string{"--help"}, 0},
+	}
+	for _, tt := range tests {
+		if code, _ := runAdminTest(t, dir, tt.command, tt.args...); code != tt.code {
+			t.Errorf("%s %v: expected exit %d, got %d", tt.command, tt.args, tt.code, code)
+		}
+	}
+}
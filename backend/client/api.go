@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/sddev/weight-tracker/models"
+)
+
+// WeightFilter narrows ListWeights. Zero fields don't filter.
+type WeightFilter struct {
+	StartDate   string
+	EndDate     string
+	Tags        []string
+	ExcludeTags []string
+}
+
+// StatsFilter narrows Stats. Zero fields don't filter.
+type StatsFilter struct {
+	StartDate       string
+	EndDate         string
+	ExcludeTags     []string
+	IncludeExcluded bool
+}
+
+// HistoryFilter narrows and pages the audit log. Entity and Action only
+// apply to History; a zero Limit uses the server's default page size.
+type HistoryFilter struct {
+	Entity string
+	Action string
+	Limit  int
+	Offset int
+}
+
+// Health returns the server's health; verbose adds the detailed checks
+func (c *Client) Health(ctx context.Context, verbose bool) (*models.HealthResponse, error) {
+	query := url.Values{}
+	if verbose {
+		query.Set("verbose", "1")
+	}
+	var health models.HealthResponse
+	if err := c.do(ctx, http.MethodGet, "/health", query, nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Ready returns nil when the server is ready to serve traffic
+func (c *Client) Ready(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/readyz", nil, nil, nil)
+}
+
+// ListWeights returns the active weight entries, newest first
+func (c *Client) ListWeights(ctx context.Context, filter WeightFilter) ([]models.Weight, error) {
+	query := url.Values{}
+	setIf(query, "start_date", filter.StartDate)
+	setIf(query, "end_date", filter.EndDate)
+	addAll(query, "tag", filter.Tags)
+	addAll(query, "exclude_tag", filter.ExcludeTags)
+
+	var response models.WeightsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/weights", query, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Weights, nil
+}
+
+// GetWeight returns a weight entry
+func (c *Client) GetWeight(ctx context.Context, id int) (*models.Weight, error) {
+	return c.weight(ctx, http.MethodGet, weightPath(id), nil)
+}
+
+// CreateWeight creates a weight entry. It fails with ErrConflict if an entry
+// exists for the date.
+func (c *Client) CreateWeight(ctx context.Context, input models.WeightInput) (*models.Weight, error) {
+	return c.weight(ctx, http.MethodPost, "/api/v1/weights", input)
+}
+
+// UpdateWeight replaces a weight entry. A nil Note or Tags keeps the stored
+// value.
+func (c *Client) UpdateWeight(ctx context.Context, id int, input models.WeightInput) (*models.Weight, error) {
+	return c.weight(ctx, http.MethodPut, weightPath(id), input)
+}
+
+// PatchWeight applies a JSON Merge Patch to a weight entry; a nil value
+// clears a field
+func (c *Client) PatchWeight(ctx context.Context, id int, patch map[string]interface{}) (*models.Weight, error) {
+	return c.weight(ctx, http.MethodPatch, weightPath(id), patch)
+}
+
+// DeleteWeight moves a weight entry to the trash
+func (c *Client) DeleteWeight(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, weightPath(id), nil, nil, nil)
+}
+
+// ListTrash returns the trashed weight entries
+func (c *Client) ListTrash(ctx context.Context) ([]models.Weight, error) {
+	var response models.WeightsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/weights/trash", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Weights, nil
+}
+
+// RestoreWeight moves a weight entry out of the trash
+func (c *Client) RestoreWeight(ctx context.Context, id int) (*models.Weight, error) {
+	return c.weight(ctx, http.MethodPost, weightPath(id)+"/restore", nil)
+}
+
+// weight sends a request answered with a weight entry
+func (c *Client) weight(ctx context.Context, method, path string, body interface{}) (*models.Weight, error) {
+	var w models.Weight
+	if err := c.do(ctx, method, path, nil, body, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ListTags returns every tag with its usage count
+func (c *Client) ListTags(ctx context.Context) ([]models.Tag, error) {
+	var response models.TagsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/tags", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Tags, nil
+}
+
+// CreateTag creates a tag. It fails with ErrConflict if the name is taken,
+// ignoring case.
+func (c *Client) CreateTag(ctx context.Context, input models.TagInput) (*models.Tag, error) {
+	return c.tag(ctx, http.MethodPost, "/api/v1/tags", input)
+}
+
+// UpdateTag renames a tag or changes whether it is excluded from stats
+func (c *Client) UpdateTag(ctx context.Context, id int, input models.TagInput) (*models.Tag, error) {
+	return c.tag(ctx, http.MethodPut, "/api/v1/tags/"+strconv.Itoa(id), input)
+}
+
+// DeleteTag deletes a tag and detaches it from every entry
+func (c *Client) DeleteTag(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/tags/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+// tag sends a request answered with a tag
+func (c *Client) tag(ctx context.Context, method, path string, body interface{}) (*models.Tag, error) {
+	var t models.Tag
+	if err := c.do(ctx, method, path, nil, body, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetGoal returns the goal weight; Pounds is nil when none is set
+func (c *Client) GetGoal(ctx context.Context) (*models.Goal, error) {
+	var goal models.Goal
+	if err := c.do(ctx, http.MethodGet, "/api/v1/goal", nil, nil, &goal); err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// SetGoal sets the goal weight, or clears it when pounds is nil
+func (c *Client) SetGoal(ctx context.Context, pounds *float64) (*models.Goal, error) {
+	var goal models.Goal
+	if err := c.do(ctx, http.MethodPut, "/api/v1/goal", nil, models.GoalInput{Pounds: pounds}, &goal); err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// Stats returns summary statistics and the weekly trend
+func (c *Client) Stats(ctx context.Context, filter StatsFilter) (*models.StatsResponse, error) {
+	query := url.Values{}
+	setIf(query, "start_date", filter.StartDate)
+	setIf(query, "end_date", filter.EndDate)
+	addAll(query, "exclude_tag", filter.ExcludeTags)
+	if filter.IncludeExcluded {
+		query.Set("include_excluded", "true")
+	}
+
+	var stats models.StatsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats", query, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// History returns a page of the audit log, newest first
+func (c *Client) History(ctx context.Context, filter HistoryFilter) (*models.AuditLogResponse, error) {
+	query := pageQuery(filter)
+	setIf(query, "entity", filter.Entity)
+	setIf(query, "action", filter.Action)
+	return c.history(ctx, "/api/v1/history", query)
+}
+
+// WeightHistory returns a page of a weight entry's audit log
+func (c *Client) WeightHistory(ctx context.Context, id int, filter HistoryFilter) (*models.AuditLogResponse, error) {
+	return c.history(ctx, weightPath(id)+"/history", pageQuery(filter))
+}
+
+// GoalHistory returns a page of the goal weight's audit log
+func (c *Client) GoalHistory(ctx context.Context, filter HistoryFilter) (*models.AuditLogResponse, error) {
+	return c.history(ctx, "/api/v1/goal/history", pageQuery(filter))
+}
+
+// history fetches a page of audit entries
+func (c *Client) history(ctx context.Context, path string, query url.Values) (*models.AuditLogResponse, error) {
+	var page models.AuditLogResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// pageQuery encodes a history filter's paging
+func pageQuery(filter HistoryFilter) url.Values {
+	query := url.Values{}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		query.Set("offset", strconv.Itoa(filter.Offset))
+	}
+	return query
+}
+
+// weightPath returns the path of a weight entry
+func weightPath(id int) string {
+	return "/api/v1/weights/" + strconv.Itoa(id)
+}
+
+// setIf sets a query parameter when value is non-empty
+func setIf(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// addAll adds a repeated query parameter
+func addAll(query url.Values, key string, values []string) {
+	for _, value := range values {
+		query.Add(key, value)
+	}
+}
@@ -0,0 +1,315 @@
+// Package client is a typed Go client for the Weight Tracker API.
+//
+//	c := client.New("http://localhost:8080", client.WithActor("import-script"))
+//	w, err := c.CreateWeight(ctx, models.WeightInput{Date: "2026-01-27", Pounds: 168.5})
+//	if errors.Is(err, client.ErrConflict) {
+//		// an entry already exists for the date
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sddev/weight-tracker/models"
+)
+
+// Client calls the API of one server. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	actor      string
+	userAgent  string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests, for custom
+// transports and timeouts. The default is a client with a 30s timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and the delay
+// before the first retry, which doubles on each further attempt. The
+// default is 3 retries starting at 200ms; 0 disables retries.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithActor sets the X-Actor header recorded in the audit log for changes
+func WithActor(actor string) Option {
+	return func(c *Client) {
+		c.actor = actor
+	}
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// New returns a client for the server at baseURL, such as
+// "http://localhost:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 3,
+		backoff:    200 * time.Millisecond,
+		userAgent:  "weight-tracker-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// maxRetryAfter caps the delay a server may ask for with Retry-After
+const maxRetryAfter = 30 * time.Second
+
+// do sends a request with a JSON body, if body is non-nil, and decodes a
+// JSON response into out, if out is non-nil. Error responses are returned
+// as *APIError.
+//
+// 429 responses are retried for every method, since the server did not
+// process the request. 5xx responses and network errors are retried only
+// for idempotent methods (GET, PUT, DELETE), so a create is never applied
+// twice.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, target, payload)
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !idempotent(method) || attempt >= c.maxRetries {
+				return fmt.Errorf("%s %s: %w", method, path, err)
+			}
+		case resp.StatusCode >= 400 && attempt < c.maxRetries && retryable(method, resp.StatusCode):
+			delay = retryAfter(resp.Header.Get("Retry-After"))
+			drain(resp)
+		default:
+			defer drain(resp)
+			return decode(resp, out)
+		}
+
+		if delay == 0 {
+			delay = c.backoff << attempt
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one attempt at a request
+func (c *Client) send(ctx context.Context, method, target string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.actor != "" {
+		req.Header.Set("X-Actor", c.actor)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// idempotent reports whether repeating a request has the same effect as
+// sending it once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a response status is worth retrying
+func retryable(method string, status int) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	return status >= 500 && idempotent(method)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date, returning 0 when it is absent or invalid
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		delay = time.Until(at)
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+	return min(delay, maxRetryAfter)
+}
+
+// decode reads a response into out, or into an *APIError for error statuses
+func decode(resp *http.Response, out interface{}) error {
+	if resp.StatusCode >= 400 {
+		return newAPIError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// drain discards the rest of a response body so the connection is reused
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+}
+
+// Errors matched by errors.Is against an *APIError, by its code
+var (
+	ErrValidation         = errors.New("invalid request")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrServer             = errors.New("server error")
+)
+
+// APIError is an error response from the server
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     []models.FieldError
+	Details    map[string]interface{}
+	RequestID  string
+}
+
+// newAPIError decodes an error response. Bodies that are not an
+// ErrorResponse, such as from a proxy, keep the status text as the message.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+
+	var body models.ErrorResponse
+	if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body) == nil && body.Error != "" {
+		apiErr.Code = body.Code
+		apiErr.Message = body.Error
+		apiErr.Fields = body.Fields
+		apiErr.Details = body.Details
+		if body.RequestID != "" {
+			apiErr.RequestID = body.RequestID
+		}
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " %s", e.Code)
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+	for i, field := range e.Fields {
+		sep := ", "
+		if i == 0 {
+			sep = " ("
+		}
+		fmt.Fprintf(&b, "%s%s %s", sep, field.Field, field.Message)
+	}
+	if len(e.Fields) > 0 {
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// Is matches the sentinel error for the response's code, falling back to
+// its status for responses without one
+func (e *APIError) Is(target error) bool {
+	switch e.Code {
+	case models.ErrorCodeValidationFailed, models.ErrorCodeMalformedBody, models.ErrorCodeInvalidID:
+		return target == ErrValidation
+	case models.ErrorCodeNotFound, models.ErrorCodeRouteNotFound:
+		return target == ErrNotFound
+	case models.ErrorCodeDuplicateDate, models.ErrorCodeDuplicateTag:
+		return target == ErrConflict
+	case models.ErrorCodePreconditionFailed:
+		return target == ErrPreconditionFailed
+	case models.ErrorCodeInternal:
+		return target == ErrServer
+	}
+
+	switch {
+	case e.StatusCode >= 500:
+		return target == ErrServer
+	case e.StatusCode == http.StatusNotFound:
+		return target == ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return target == ErrConflict
+	case e.StatusCode == http.StatusPreconditionFailed:
+		return target == ErrPreconditionFailed
+	case e.StatusCode == http.StatusBadRequest:
+		return target == ErrValidation
+	}
+	return false
+}
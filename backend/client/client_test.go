@@ -0,0 +1,298 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/handlers"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// newTestServer serves the real handlers over an in-memory database
+func newTestServer(t *testing.T) *httptest.Server {
+	if err := db.InitDB(":memory:", db.DefaultOptions()); err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handlers.ErrorHandler())
+	router.NoRoute(handlers.NoRoute)
+	handlers.RegisterRoutes(router, func(c *gin.Context) {
+		c.String(http.StatusOK, "")
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// flakyServer answers the first failures requests with status, then hands
+// over to next, counting every request
+func flakyServer(t *testing.T, failures int, status int, next http.Handler) (*httptest.Server, *int32) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) <= int32(failures) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &count
+}
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestClient_Weights(t *testing.T) {
+	server := newTestServer(t)
+	c := New(server.URL, WithActor("client-test"))
+	ctx := context.Background()
+
+	note := "start"
+	created, err := c.CreateWeight(ctx, models.WeightInput{Date: "2026-01-01", Pounds: 170, Note: &note, Tags: []string{"holiday"}})
+	if err != nil {
+		t.Fatalf("CreateWeight failed: %v", err)
+	}
+	if created.ID == 0 || created.Pounds != 170 || len(created.Tags) != 1 {
+		t.Errorf("Unexpected created entry %+v", created)
+	}
+	if _, err := c.CreateWeight(ctx, models.WeightInput{Date: "2026-01-08", Pounds: 168}); err != nil {
+		t.Fatalf("CreateWeight failed: %v", err)
+	}
+
+	got, err := c.GetWeight(ctx, created.ID)
+	if err != nil || got.Date != "2026-01-01" || got.Note == nil || *got.Note != "start" {
+		t.Errorf("GetWeight returned %+v, %v", got, err)
+	}
+
+	weights, err := c.ListWeights(ctx, WeightFilter{Tags: []string{"holiday"}})
+	if err != nil || len(weights) != 1 || weights[0].ID != created.ID {
+		t.Errorf("ListWeights by tag returned %+v, %v", weights, err)
+	}
+	weights, err = c.ListWeights(ctx, WeightFilter{StartDate: "2026-01-02"})
+	if err != nil || len(weights) != 1 || weights[0].Date != "2026-01-08" {
+		t.Errorf("ListWeights by date returned %+v, %v", weights, err)
+	}
+
+	updated, err := c.UpdateWeight(ctx, created.ID, models.WeightInput{Date: "2026-01-01", Pounds: 169.5})
+	if err != nil || updated.Pounds != 169.5 || updated.Version != 2 {
+		t.Errorf("UpdateWeight returned %+v, %v", updated, err)
+	}
+
+	patched, err := c.PatchWeight(ctx, created.ID, map[string]interface{}{"note": nil})
+	if err != nil || patched.Note != nil {
+		t.Errorf("PatchWeight returned %+v, %v", patched, err)
+	}
+
+	if err := c.DeleteWeight(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteWeight failed: %v", err)
+	}
+	trash, err := c.ListTrash(ctx)
+	if err != nil || len(trash) != 1 {
+		t.Errorf("ListTrash returned %+v, %v", trash, err)
+	}
+	if _, err := c.RestoreWeight(ctx, created.ID); err != nil {
+		t.Errorf("RestoreWeight failed: %v", err)
+	}
+
+	history, err := c.WeightHistory(ctx, created.ID, HistoryFilter{Limit: 2})
+	if err != nil || history.Total != 5 || len(history.Entries) != 2 {
+		t.Fatalf("WeightHistory returned %+v, %v", history, err)
+	}
+	if history.Entries[0].Action != "restore" || history.Entries[0].Actor != "client-test" {
+		t.Errorf("Expected the restore by client-test first, got %+v", history.Entries[0])
+	}
+}
+
+func TestClient_TagsGoalAndStats(t *testing.T) {
+	server := newTestServer(t)
+	c := New(server.URL)
+	ctx := context.Background()
+
+	tag, err := c.CreateTag(ctx, models.TagInput{Name: "sick"})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	if tag, err = c.UpdateTag(ctx, tag.ID, models.TagInput{Name: "ill", ExcludeFromStats: true}); err != nil || !tag.ExcludeFromStats {
+		t.Errorf("UpdateTag returned %+v, %v", tag, err)
+	}
+
+	c.CreateWeight(ctx, models.WeightInput{Date: "2026-01-01", Pounds: 170})
+	c.CreateWeight(ctx, models.WeightInput{Date: "2026-01-02", Pounds: 175, Tags: []string{"ill"}})
+
+	stats, err := c.Stats(ctx, StatsFilter{})
+	if err != nil || stats.Count != 1 || stats.ExcludedCount != 1 {
+		t.Errorf("Stats returned %+v, %v", stats, err)
+	}
+	if stats, err = c.Stats(ctx, StatsFilter{IncludeExcluded: true}); err != nil || stats.Count != 2 {
+		t.Errorf("Stats including excluded returned %+v, %v", stats, err)
+	}
+
+	tags, err := c.ListTags(ctx)
+	if err != nil || len(tags) != 1 || tags[0].WeightCount != 1 {
+		t.Errorf("ListTags returned %+v, %v", tags, err)
+	}
+	if err := c.DeleteTag(ctx, tag.ID); err != nil {
+		t.Errorf("DeleteTag failed: %v", err)
+	}
+
+	goal, err := c.SetGoal(ctx, float(160))
+	if err != nil || goal.Pounds == nil || *goal.Pounds != 160 {
+		t.Errorf("SetGoal returned %+v, %v", goal, err)
+	}
+	if goal, err = c.SetGoal(ctx, nil); err != nil || goal.Pounds != nil {
+		t.Errorf("SetGoal(nil) returned %+v, %v", goal, err)
+	}
+	if goal, err = c.GetGoal(ctx); err != nil || goal.Pounds != nil {
+		t.Errorf("GetGoal returned %+v, %v", goal, err)
+	}
+	if page, err := c.GoalHistory(ctx, HistoryFilter{}); err != nil || page.Total != 2 {
+		t.Errorf("GoalHistory returned %+v, %v", page, err)
+	}
+	// Two creations, and deleting the tag updated the entry carrying it
+	if page, err := c.History(ctx, HistoryFilter{Entity: "weight"}); err != nil || page.Total != 3 {
+		t.Errorf("History returned %+v, %v", page, err)
+	}
+
+	health, err := c.Health(ctx, false)
+	if err != nil || health.Status != "healthy" {
+		t.Errorf("Health returned %+v, %v", health, err)
+	}
+	if err := c.Ready(ctx); err != nil {
+		t.Errorf("Ready failed: %v", err)
+	}
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	server := newTestServer(t)
+	c := New(server.URL)
+	ctx := context.Background()
+
+	_, err := c.GetWeight(ctx, 999)
+	var apiErr *APIError
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &apiErr) {
+		t.Fatalf("Expected ErrNotFound as *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != models.ErrorCodeNotFound || apiErr.Message != "Weight entry not found" {
+		t.Errorf("Unexpected error %+v", apiErr)
+	}
+
+	c.CreateWeight(ctx, models.WeightInput{Date: "2026-01-01", Pounds: 170})
+	if _, err := c.CreateWeight(ctx, models.WeightInput{Date: "2026-01-01", Pounds: 171}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+
+	_, err = c.CreateWeight(ctx, models.WeightInput{Date: "2026-01-02", Pounds: -1})
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) {
+		t.Fatalf("Expected ErrValidation, got %v", err)
+	}
+	if len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "pounds" {
+		t.Errorf("Expected a pounds field error, got %+v", apiErr.Fields)
+	}
+	if got := err.Error(); got != "400 validation_failed: Invalid request (pounds must be greater than 0)" {
+		t.Errorf("Unexpected error text %q", got)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Error("Expected a validation error not to match ErrNotFound")
+	}
+}
+
+func TestClient_RetriesIdempotentRequests(t *testing.T) {
+	server := newTestServer(t)
+	flaky, count := flakyServer(t, 2, http.StatusServiceUnavailable, server.Config.Handler)
+
+	c := New(flaky.URL, WithRetries(3, time.Millisecond))
+	if _, err := c.ListWeights(context.Background(), WeightFilter{}); err != nil {
+		t.Fatalf("Expected the request to succeed after retries, got %v", err)
+	}
+	if *count != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *count)
+	}
+}
+
+func TestClient_GivesUpAfterMaxRetries(t *testing.T) {
+	server := newTestServer(t)
+	flaky, count := flakyServer(t, 10, http.StatusBadGateway, server.Config.Handler)
+
+	c := New(flaky.URL, WithRetries(2, time.Millisecond))
+	_, err := c.GetGoal(context.Background())
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("Expected ErrServer, got %v", err)
+	}
+	if *count != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *count)
+	}
+}
+
+func TestClient_DoesNotRetryCreateOnServerError(t *testing.T) {
+	server := newTestServer(t)
+	flaky, count := flakyServer(t, 1, http.StatusInternalServerError, server.Config.Handler)
+
+	c := New(flaky.URL, WithRetries(3, time.Millisecond))
+	_, err := c.CreateWeight(context.Background(), models.WeightInput{Date: "2026-01-01", Pounds: 170})
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("Expected ErrServer, got %v", err)
+	}
+	if *count != 1 {
+		t.Errorf("Expected a single attempt, got %d", *count)
+	}
+}
+
+func TestClient_RetriesCreateWhenRateLimited(t *testing.T) {
+	server := newTestServer(t)
+	flaky, count := flakyServer(t, 1, http.StatusTooManyRequests, server.Config.Handler)
+
+	c := New(flaky.URL, WithRetries(3, time.Millisecond))
+	if _, err := c.CreateWeight(context.Background(), models.WeightInput{Date: "2026-01-01", Pounds: 170}); err != nil {
+		t.Fatalf("Expected the create to succeed after a 429, got %v", err)
+	}
+	if *count != 2 {
+		t.Errorf("Expected 2 attempts, got %d", *count)
+	}
+}
+
+func TestClient_StopsRetryingWhenContextEnds(t *testing.T) {
+	flaky, _ := flakyServer(t, 1000, http.StatusServiceUnavailable, nil)
+
+	c := New(flaky.URL, WithRetries(100, 20*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.ListTags(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to stop at the deadline, took %v", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"-1", 0},
+		{"3600", maxRetryAfter},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
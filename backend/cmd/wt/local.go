@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/handlers"
+)
+
+// localTransport serves client requests with the server's handlers in this
+// process, so local mode validates and audits changes exactly as the server
+// does
+type localTransport struct {
+	handler http.Handler
+}
+
+func (t localTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, req)
+	return recorder.Result(), nil
+}
+
+// openLocal opens the SQLite database at path, creating or migrating it,
+// and returns an HTTP client served from it
+func openLocal(path string) (*http.Client, error) {
+	if err := db.InitDB(path, db.DefaultOptions()); err != nil {
+		return nil, err
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(handlers.ErrorHandler())
+	router.NoRoute(handlers.NoRoute)
+	handlers.RegisterRoutes(router, handlers.NoRoute)
+
+	return &http.Client{Transport: localTransport{handler: router}}, nil
+}
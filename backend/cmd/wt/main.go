@@ -0,0 +1,386 @@
+// Command wt logs and queries weights from a terminal, through the HTTP API
+// or directly on the SQLite database file in local mode.
+//
+//	wt add 12st 3lb
+//	wt add 77.4kg --date 2026-10-01 --tag holiday
+//	wt list --since 30d
+//	wt goal set 11st
+//	wt stats --unit kg
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sddev/weight-tracker/client"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+)
+
+const usage = `Usage: wt <command> [flags] [arguments]
+
+Commands:
+  add <weight>        Log a weight, e.g. "12st 3lb", "77.4kg" or "170"
+  list                List weights, newest first
+  goal [set <weight> | clear]
+                      Show, set or clear the goal weight
+  stats               Show summary statistics and the weekly trend
+
+Flags for every command:
+  --server URL        API server (WT_SERVER, default http://localhost:8080)
+  --db PATH           Use the SQLite database file directly (WT_DB)
+  --unit lb|kg|st     Unit for bare numbers and output (WT_UNIT, default lb)
+  --json              Print JSON instead of a table
+
+Run "wt <command> --help" for the command's own flags.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes a command and returns the process exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stderr, usage)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	commands := map[string]func(context.Context, *command) error{
+		"add":   addCommand,
+		"list":  listCommand,
+		"goal":  goalCommand,
+		"stats": statsCommand,
+	}
+	handler, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "wt: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	cmd := newCommand(args[0], stdout, stderr)
+	err := cmd.prepare(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err == nil {
+		defer cmd.close()
+		err = handler(ctx, cmd)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "wt: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// command holds one invocation's flags, arguments and API client
+type command struct {
+	flags  *flag.FlagSet
+	args   []string
+	stdout io.Writer
+
+	server string
+	dbPath string
+	unit   string
+	json   bool
+
+	client *client.Client
+	local  bool
+}
+
+// newCommand declares the flags shared by every command
+func newCommand(name string, stdout, stderr io.Writer) *command {
+	cmd := &command{flags: flag.NewFlagSet("wt "+name, flag.ContinueOnError), stdout: stdout}
+	cmd.flags.SetOutput(stderr)
+
+	cmd.flags.StringVar(&cmd.server, "server", envOr("WT_SERVER", "http://localhost:8080"), "API server URL")
+	cmd.flags.StringVar(&cmd.dbPath, "db", os.Getenv("WT_DB"), "SQLite database file to use instead of the server")
+	cmd.flags.StringVar(&cmd.unit, "unit", envOr("WT_UNIT", "lb"), "unit for bare numbers and output: lb, kg or st")
+	cmd.flags.BoolVar(&cmd.json, "json", false, "print JSON")
+	return cmd
+}
+
+// prepare lets the command declare its own flags, parses the arguments and
+// connects to the API
+func (cmd *command) prepare(args []string) error {
+	if declare, ok := commandFlags[cmd.flags.Name()]; ok {
+		declare(cmd)
+	}
+
+	var err error
+	if cmd.args, err = parseInterleaved(cmd.flags, args); err != nil {
+		return err
+	}
+	if err := checkUnit(cmd.unit); err != nil {
+		return err
+	}
+
+	opts := []client.Option{client.WithActor(envOr("USER", "wt")), client.WithUserAgent("wt")}
+	if cmd.dbPath != "" {
+		// Keep the database's own logging out of the command's output
+		slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		httpClient, err := openLocal(cmd.dbPath)
+		if err != nil {
+			return err
+		}
+		cmd.local = true
+		opts = append(opts, client.WithHTTPClient(httpClient), client.WithRetries(0, 0))
+	}
+	cmd.client = client.New(cmd.server, opts...)
+	return nil
+}
+
+// close releases the local database
+func (cmd *command) close() {
+	if cmd.local {
+		db.CloseDB()
+	}
+}
+
+// parseInterleaved parses flags that may come before, between or after the
+// positional arguments, which it returns
+func parseInterleaved(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// commandFlags declares each command's own flags
+var commandFlags = map[string]func(*command){
+	"wt add": func(cmd *command) {
+		cmd.flags.String("date", "", "date of the weigh-in, YYYY-MM-DD (default today)")
+		cmd.flags.String("note", "", "free-text note")
+		cmd.flags.Var(&stringList{}, "tag", "tag to attach (repeatable)")
+	},
+	"wt list": func(cmd *command) {
+		cmd.flags.String("since", "", "only from this date or span back, e.g. 2026-01-01, 30d, 6w, 3m, 1y")
+		cmd.flags.String("until", "", "only up to this date, YYYY-MM-DD")
+		cmd.flags.Var(&stringList{}, "tag", "only entries with this tag (repeatable)")
+	},
+	"wt stats": func(cmd *command) {
+		cmd.flags.String("since", "", "only from this date or span back, e.g. 2026-01-01, 30d, 6w, 3m, 1y")
+		cmd.flags.String("until", "", "only up to this date, YYYY-MM-DD")
+	},
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// flagString returns the value of a string flag
+func (cmd *command) flagString(name string) string {
+	return cmd.flags.Lookup(name).Value.String()
+}
+
+// flagList returns the values of a repeatable flag
+func (cmd *command) flagList(name string) []string {
+	return *cmd.flags.Lookup(name).Value.(*stringList)
+}
+
+// since resolves the --since flag
+func (cmd *command) since() (string, error) {
+	value := cmd.flagString("since")
+	if value == "" {
+		return "", nil
+	}
+	return parseSince(value, time.Now())
+}
+
+// addCommand logs a weight
+func addCommand(ctx context.Context, cmd *command) error {
+	pounds, err := parseWeight(strings.Join(cmd.args, " "), cmd.unit)
+	if err != nil {
+		return err
+	}
+
+	input := models.WeightInput{
+		Date:   cmd.flagString("date"),
+		Pounds: pounds,
+		Tags:   cmd.flagList("tag"),
+	}
+	// The server checks dates against today in UTC
+	if input.Date == "" {
+		input.Date = time.Now().UTC().Format("2006-01-02")
+	}
+	if note := cmd.flagString("note"); note != "" {
+		input.Note = &note
+	}
+
+	w, err := cmd.client.CreateWeight(ctx, input)
+	if err != nil {
+		return err
+	}
+	return cmd.printWeights([]models.Weight{*w}, w)
+}
+
+// listCommand lists weights
+func listCommand(ctx context.Context, cmd *command) error {
+	if len(cmd.args) > 0 {
+		return fmt.Errorf("list takes no arguments, got %q", cmd.args)
+	}
+	since, err := cmd.since()
+	if err != nil {
+		return err
+	}
+
+	weights, err := cmd.client.ListWeights(ctx, client.WeightFilter{
+		StartDate: since,
+		EndDate:   cmd.flagString("until"),
+		Tags:      cmd.flagList("tag"),
+	})
+	if err != nil {
+		return err
+	}
+	return cmd.printWeights(weights, weights)
+}
+
+// goalCommand shows, sets or clears the goal weight
+func goalCommand(ctx context.Context, cmd *command) error {
+	var goal *models.Goal
+	var err error
+
+	action := "show"
+	if len(cmd.args) > 0 {
+		action = cmd.args[0]
+	}
+
+	switch action {
+	case "show":
+		goal, err = cmd.client.GetGoal(ctx)
+	case "set":
+		pounds, parseErr := parseWeight(strings.Join(cmd.args[1:], " "), cmd.unit)
+		if parseErr != nil {
+			return parseErr
+		}
+		goal, err = cmd.client.SetGoal(ctx, &pounds)
+	case "clear":
+		goal, err = cmd.client.SetGoal(ctx, nil)
+	default:
+		return fmt.Errorf("unknown goal action %q: expected show, set or clear", action)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cmd.json {
+		return cmd.printJSON(goal)
+	}
+	if goal.Pounds == nil {
+		fmt.Fprintln(cmd.stdout, "No goal set")
+		return nil
+	}
+	fmt.Fprintf(cmd.stdout, "Goal: %s\n", formatWeight(*goal.Pounds, cmd.unit))
+	return nil
+}
+
+// statsCommand prints summary statistics
+func statsCommand(ctx context.Context, cmd *command) error {
+	since, err := cmd.since()
+	if err != nil {
+		return err
+	}
+
+	stats, err := cmd.client.Stats(ctx, client.StatsFilter{StartDate: since, EndDate: cmd.flagString("until")})
+	if err != nil {
+		return err
+	}
+	if cmd.json {
+		return cmd.printJSON(stats)
+	}
+
+	table := tabwriter.NewWriter(cmd.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "Entries\t%d\n", stats.Count)
+	if stats.ExcludedCount > 0 {
+		fmt.Fprintf(table, "Excluded\t%d\n", stats.ExcludedCount)
+	}
+	if stats.Count > 0 {
+		fmt.Fprintf(table, "Period\t%s to %s\n", *stats.FirstDate, *stats.LastDate)
+	}
+
+	rows := []struct {
+		label  string
+		pounds *float64
+	}{
+		{"Start", stats.StartPounds},
+		{"Latest", stats.LatestPounds},
+		{"Min", stats.MinPounds},
+		{"Max", stats.MaxPounds},
+		{"Average", stats.AveragePounds},
+		{"Change", stats.ChangePounds},
+	}
+	for _, row := range rows {
+		if row.pounds != nil {
+			fmt.Fprintf(table, "%s\t%s\n", row.label, formatWeight(*row.pounds, cmd.unit))
+		}
+	}
+	if stats.TrendPoundsPerWeek != nil {
+		fmt.Fprintf(table, "Trend\t%s per week\n", formatWeight(*stats.TrendPoundsPerWeek, cmd.unit))
+	}
+	return table.Flush()
+}
+
+// printWeights prints weights as a table, or value as JSON
+func (cmd *command) printWeights(weights []models.Weight, value interface{}) error {
+	if cmd.json {
+		return cmd.printJSON(value)
+	}
+
+	table := tabwriter.NewWriter(cmd.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tDATE\tWEIGHT\tTAGS\tNOTE")
+	for _, w := range weights {
+		note := ""
+		if w.Note != nil {
+			note = *w.Note
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n", w.ID, w.Date, formatWeight(w.Pounds, cmd.unit), strings.Join(w.Tags, ","), note)
+	}
+	return table.Flush()
+}
+
+// printJSON prints value as indented JSON
+func (cmd *command) printJSON(value interface{}) error {
+	encoder := json.NewEncoder(cmd.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// envOr returns an environment variable, or fallback when it is unset
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sddev/weight-tracker/models"
+)
+
+// wt runs a command in local mode against path and returns its output and
+// exit code
+func wt(t *testing.T, path string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append(args, "--db", path), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestRun_LocalMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wt.db")
+
+	if out, errOut, code := wt(t, path, "add", "12st", "3lb", "--date", "2026-10-01", "--tag", "holiday"); code != 0 || !strings.Contains(out, "171.0 lb") {
+		t.Fatalf("add returned %d: %s%s", code, out, errOut)
+	}
+	if _, errOut, code := wt(t, path, "add", "77.4kg", "--date", "2026-10-08", "--note", "after run"); code != 0 {
+		t.Fatalf("add returned %d: %s", code, errOut)
+	}
+
+	out, _, code := wt(t, path, "list", "--json", "--since", "2026-10-05")
+	var weights []models.Weight
+	if code != 0 || json.Unmarshal([]byte(out), &weights) != nil {
+		t.Fatalf("list returned %d: %s", code, out)
+	}
+	if len(weights) != 1 || weights[0].Pounds != 170.64 || *weights[0].Note != "after run" {
+		t.Errorf("Unexpected entries %+v", weights)
+	}
+
+	out, _, _ = wt(t, path, "list", "--unit", "st", "--tag", "holiday")
+	if !strings.Contains(out, "12 st 3 lb") || strings.Contains(out, "2026-10-08") {
+		t.Errorf("Expected the holiday entry in stones, got:\n%s", out)
+	}
+
+	if out, _, code := wt(t, path, "goal", "set", "11st"); code != 0 || out != "Goal: 154.0 lb\n" {
+		t.Errorf("goal set returned %d: %q", code, out)
+	}
+	if out, _, _ := wt(t, path, "goal", "--unit", "st"); out != "Goal: 11 st 0 lb\n" {
+		t.Errorf("goal returned %q", out)
+	}
+
+	out, _, _ = wt(t, path, "stats", "--json")
+	var stats models.StatsResponse
+	if json.Unmarshal([]byte(out), &stats) != nil || stats.Count != 2 {
+		t.Errorf("stats returned %s", out)
+	}
+	if out, _, _ := wt(t, path, "stats"); !strings.Contains(out, "Entries  2") || !strings.Contains(out, "Change   -0.4 lb") {
+		t.Errorf("stats table was:\n%s", out)
+	}
+
+	if out, _, _ := wt(t, path, "goal", "clear"); out != "No goal set\n" {
+		t.Errorf("goal clear returned %q", out)
+	}
+}
+
+func TestRun_AddDefaultsToTodayInUTC(t *testing.T) {
+	// Pick a zone whose date differs from UTC's right now
+	zone := time.FixedZone("UTC-12", -12*60*60)
+	if time.Now().UTC().Hour() >= 12 {
+		zone = time.FixedZone("UTC+13", 13*60*60)
+	}
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = zone
+
+	path := filepath.Join(t.TempDir(), "wt.db")
+	if out, errOut, code := wt(t, path, "add", "170"); code != 0 {
+		t.Fatalf("add returned %d: %s%s", code, out, errOut)
+	}
+
+	out, _, _ := wt(t, path, "list", "--json")
+	var weights []models.Weight
+	json.Unmarshal([]byte(out), &weights)
+	if today := time.Now().UTC().Format("2006-01-02"); len(weights) != 1 || weights[0].Date != today {
+		t.Errorf("Expected an entry for %s, got %s", today, out)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wt.db")
+
+	wt(t, path, "add", "170", "--date", "2026-10-01")
+	if _, errOut, code := wt(t, path, "add", "171", "--date", "2026-10-01"); code != 1 || !strings.Contains(errOut, "duplicate_date") {
+		t.Errorf("Expected a duplicate date error, got %d: %s", code, errOut)
+	}
+	if _, errOut, code := wt(t, path, "add", "12kg 3lb"); code != 1 || !strings.Contains(errOut, "only stones") {
+		t.Errorf("Expected a unit error, got %d: %s", code, errOut)
+	}
+	if _, errOut, code := wt(t, path, "list", "--since", "soon"); code != 1 || !strings.Contains(errOut, "invalid --since") {
+		t.Errorf("Expected a --since error, got %d: %s", code, errOut)
+	}
+	if _, _, code := wt(t, path, "weigh"); code != 2 {
+		t.Errorf("Expected exit code 2 for an unknown command, got %d", code)
+	}
+	if _, _, code := wt(t, path, "list", "--unit", "oz"); code != 1 {
+		t.Errorf("Expected exit code 1 for an unknown unit, got %d", code)
+	}
+}
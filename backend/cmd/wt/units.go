@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	poundsPerStone = 14
+	poundsPerKilo  = 2.20462262185
+)
+
+// units maps accepted unit spellings to their weight in pounds
+var units = map[string]float64{
+	"lb": 1, "lbs": 1, "pound": 1, "pounds": 1,
+	"st": poundsPerStone, "stone": poundsPerStone, "stones": poundsPerStone,
+	"kg": poundsPerKilo, "kgs": poundsPerKilo, "kilo": poundsPerKilo, "kilos": poundsPerKilo,
+}
+
+// weightToken matches a number with an optional unit, e.g. "12st", "3.5",
+// "77.4 kg"
+var weightToken = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*([a-zA-Z]*)`)
+
+// parseWeight parses a weight such as "170", "77.4kg", "12st 3lb" or
+// "12st 3" into pounds. A bare number is in defaultUnit, except that one
+// following stones is in pounds.
+func parseWeight(input, defaultUnit string) (float64, error) {
+	rest := strings.TrimSpace(input)
+	if rest == "" {
+		return 0, fmt.Errorf("missing weight")
+	}
+
+	var pounds float64
+	previous := ""
+	for rest != "" {
+		match := weightToken.FindStringSubmatch(rest)
+		if match == nil {
+			return 0, fmt.Errorf("invalid weight %q: expected a number with an optional unit (lb, st, kg)", input)
+		}
+		rest = strings.TrimSpace(rest[len(match[0]):])
+
+		value, _ := strconv.ParseFloat(match[1], 64)
+		unit := strings.ToLower(match[2])
+		switch {
+		case unit != "":
+		case previous == "st":
+			unit = "lb"
+		default:
+			unit = defaultUnit
+		}
+
+		factor, ok := units[unit]
+		if !ok {
+			return 0, fmt.Errorf("invalid weight %q: unknown unit %q", input, match[2])
+		}
+		// Only "12st 3lb" combines units
+		canonical := canonicalUnit(factor)
+		if previous != "" && (previous != "st" || canonical != "lb") {
+			return 0, fmt.Errorf("invalid weight %q: only stones may be followed by pounds", input)
+		}
+		previous = canonical
+		pounds += value * factor
+	}
+
+	if pounds <= 0 {
+		return 0, fmt.Errorf("invalid weight %q: must be greater than 0", input)
+	}
+	return math.Round(pounds*100) / 100, nil
+}
+
+// canonicalUnit names the unit with the given factor
+func canonicalUnit(factor float64) string {
+	switch factor {
+	case poundsPerStone:
+		return "st"
+	case poundsPerKilo:
+		return "kg"
+	}
+	return "lb"
+}
+
+// checkUnit validates a display or default unit
+func checkUnit(unit string) error {
+	switch unit {
+	case "lb", "kg", "st":
+		return nil
+	}
+	return fmt.Errorf("invalid unit %q: must be lb, kg or st", unit)
+}
+
+// formatWeight renders pounds in unit: "170.5 lb", "77.3 kg" or
+// "12 st 2.5 lb"
+func formatWeight(pounds float64, unit string) string {
+	switch unit {
+	case "kg":
+		return strconv.FormatFloat(round1(pounds/poundsPerKilo), 'f', 1, 64) + " kg"
+	case "st":
+		sign := ""
+		if pounds < 0 {
+			sign, pounds = "-", -pounds
+		}
+		stones := math.Floor(pounds / poundsPerStone)
+		rest := round1(pounds - stones*poundsPerStone)
+		if rest >= poundsPerStone {
+			stones, rest = stones+1, 0
+		}
+		if stones == 0 {
+			// Changes and trends are usually under a stone
+			return sign + strconv.FormatFloat(rest, 'f', -1, 64) + " lb"
+		}
+		return fmt.Sprintf("%s%.0f st %s lb", sign, stones, strconv.FormatFloat(rest, 'f', -1, 64))
+	}
+	return strconv.FormatFloat(round1(pounds), 'f', 1, 64) + " lb"
+}
+
+// round1 rounds to one decimal place
+func round1(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+// parseSince resolves a --since value, either a date or a span back from
+// today such as "30d", "6w", "3m" or "1y", to a YYYY-MM-DD date
+func parseSince(value string, today time.Time) (string, error) {
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return value, nil
+	}
+
+	if len(value) >= 2 {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err == nil && n >= 0 {
+			switch value[len(value)-1] {
+			case 'd':
+				return today.AddDate(0, 0, -n).Format("2006-01-02"), nil
+			case 'w':
+				return today.AddDate(0, 0, -7*n).Format("2006-01-02"), nil
+			case 'm':
+				return today.AddDate(0, -n, 0).Format("2006-01-02"), nil
+			case 'y':
+				return today.AddDate(-n, 0, 0).Format("2006-01-02"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid --since %q: expected YYYY-MM-DD or a span such as 30d, 6w, 3m, 1y", value)
+}
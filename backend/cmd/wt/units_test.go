@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWeight(t *testing.T) {
+	tests := []struct {
+		input string
+		unit  string
+		want  float64
+	}{
+		{"170", "lb", 170},
+		{"170.5lb", "kg", 170.5},
+		{"77.4kg", "lb", 170.64},
+		{"77.4 kg", "lb", 170.64},
+		{"12st 3lb", "lb", 171},
+		{"12st3lb", "lb", 171},
+		{"12st 3", "lb", 171},
+		{"12 stone 3 pounds", "lb", 171},
+		{"11st", "lb", 154},
+		{"11", "st", 154},
+		{"80", "kg", 176.37},
+	}
+	for _, tt := range tests {
+		got, err := parseWeight(tt.input, tt.unit)
+		if err != nil || got != tt.want {
+			t.Errorf("parseWeight(%q, %q) = %v, %v; want %v", tt.input, tt.unit, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseWeight_Invalid(t *testing.T) {
+	for _, input := range []string{"", "heavy", "12xy", "12kg 3lb", "3lb 12st", "12st 3lb 2lb", "0", "-5"} {
+		if got, err := parseWeight(input, "lb"); err == nil {
+			t.Errorf("parseWeight(%q) = %v, expected an error", input, got)
+		}
+	}
+}
+
+func TestFormatWeight(t *testing.T) {
+	tests := []struct {
+		pounds float64
+		unit   string
+		want   string
+	}{
+		{170.64, "lb", "170.6 lb"},
+		{170.64, "kg", "77.4 kg"},
+		{171, "st", "12 st 3 lb"},
+		{170.6, "st", "12 st 2.6 lb"},
+		{167.98, "st", "12 st 0 lb"},
+		{-1.5, "st", "-1.5 lb"},
+		{-15, "st", "-1 st 1 lb"},
+	}
+	for _, tt := range tests {
+		if got := formatWeight(tt.pounds, tt.unit); got != tt.want {
+			t.Errorf("formatWeight(%v, %q) = %q, want %q", tt.pounds, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	today := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		"2026-01-15": "2026-01-15",
+		"30d":        "2026-03-01",
+		"2w":         "2026-03-17",
+		"1m":         "2026-03-03",
+		"1y":         "2025-03-31",
+		"0d":         "2026-03-31",
+	}
+	for input, want := range tests {
+		if got, err := parseSince(input, today); err != nil || got != want {
+			t.Errorf("parseSince(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"d", "30", "-3d", "3h", "yesterday"} {
+		if _, err := parseSince(input, today); err == nil {
+			t.Errorf("parseSince(%q) expected an error", input)
+		}
+	}
+}
@@ -1,42 +1,116 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
-
-	_ "github.com/mattn/go-sqlite3"
+	"log/slog"
+	"strings"
+	"time"
 )
 
+// DB is the write pool. It holds a single connection so that writers queue
+// in-process instead of contending for the SQLite write lock.
 var DB *sql.DB
 
-// InitDB initializes the database connection and creates tables if they don't exist
-func InitDB() error {
-	dbPath := os.Getenv("DATABASE_PATH")
-	if dbPath == "" {
-		dbPath = "/data/weight-tracker.db"
+// ReadDB is the pool for read-only queries. In WAL mode its connections
+// read concurrently with the writer. For in-memory and PostgreSQL databases
+// it is DB.
+var ReadDB *sql.DB
+
+// Options tunes the SQLite connections opened by InitDB
+type Options struct {
+	// JournalMode is the journal_mode pragma: DELETE, TRUNCATE, PERSIST,
+	// MEMORY, WAL or OFF
+	JournalMode string
+	// Synchronous is the synchronous pragma: OFF, NORMAL, FULL or EXTRA
+	Synchronous string
+	// BusyTimeout is how long a statement waits for a lock held by another
+	// connection before failing with "database is locked"
+	BusyTimeout time.Duration
+	// ForeignKeys enables foreign key enforcement
+	ForeignKeys bool
+	// MaxReadConns sizes the read pool
+	MaxReadConns int
+}
+
+// DefaultOptions returns WAL mode with NORMAL synchronous, which is durable
+// across application crashes and much faster than FULL
+func DefaultOptions() Options {
+	return Options{
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		BusyTimeout:  5 * time.Second,
+		ForeignKeys:  true,
+		MaxReadConns: 4,
 	}
+}
 
-	log.Printf("Initializing database at: %s", dbPath)
+// InitDB opens the write and read pools for the database at dbPath and
+// creates tables if they don't exist
+func InitDB(dbPath string, opts Options) error {
+	dialect = SQLite
+	slog.Info("Initializing database", "path", dbPath,
+		"journal_mode", opts.JournalMode, "synchronous", opts.Synchronous,
+		"busy_timeout", opts.BusyTimeout, "max_read_conns", opts.MaxReadConns)
 
-	var err error
-	DB, err = sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+	foreignKeys := "OFF"
+	if opts.ForeignKeys {
+		foreignKeys = "ON"
+	}
+	pragmas := []string{
+		fmt.Sprintf("busy_timeout = %d", opts.BusyTimeout.Milliseconds()),
+		"foreign_keys = " + foreignKeys,
+		"synchronous = " + opts.Synchronous,
 	}
 
+	// The journal mode is persistent and needs write access, so only the
+	// writer sets it
+	DB = sql.OpenDB(newConnector(dbPath, append([]string{"journal_mode = " + opts.JournalMode}, pragmas...)))
+	DB.SetMaxOpenConns(1)
+
 	// Test the connection
 	if err := DB.Ping(); err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Create tables
+	// Every connection to an in-memory database opens a new, empty one
+	if isMemory(dbPath) {
+		ReadDB = DB
+	} else {
+		ReadDB = sql.OpenDB(newConnector(dbPath, append(pragmas, "query_only = ON")))
+		ReadDB.SetMaxOpenConns(opts.MaxReadConns)
+		ReadDB.SetMaxIdleConns(opts.MaxReadConns)
+
+		if err := ReadDB.Ping(); err != nil {
+			return fmt.Errorf("failed to ping database: %w", err)
+		}
+	}
+
+	// Create tables and apply schema migrations
+	if err := Migrate(); err != nil {
+		return err
+	}
+
+	slog.Info("Database initialized successfully")
+	return nil
+}
+
+// Migrate creates the base tables if they don't exist and applies any
+// pending schema migrations to the open database
+func Migrate() error {
+	if dialect == Postgres {
+		return migratePostgres()
+	}
+
 	if err := createTables(); err != nil {
 		return fmt.Errorf("failed to create tables: %w", err)
 	}
 
-	log.Println("Database initialized successfully")
+	if err := applyMigrations(); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
 	return nil
 }
 
@@ -74,10 +148,306 @@ func createTables() error {
 	return nil
 }
 
-// CloseDB closes the database connection
+// migrations are applied in order on top of the base schema created by
+// createTables. The index of each entry plus one is the schema version it
+// produces, tracked in SQLite's user_version pragma. Never edit or reorder
+// an entry once released; append a new one instead.
+var migrations = []string{
+	// 1: row versions for optimistic concurrency (ETags)
+	`ALTER TABLE weights ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE settings ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+
+	// 2: soft deletion; the table is rebuilt because SQLite cannot drop the
+	// UNIQUE constraint on date, which must now ignore trashed rows
+	`CREATE TABLE weights_new (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date TEXT NOT NULL,
+		pounds REAL NOT NULL,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		version INTEGER NOT NULL DEFAULT 1,
+		deleted_at TEXT
+	);
+	INSERT INTO weights_new (id, date, pounds, created_at, updated_at, version)
+		SELECT id, date, pounds, created_at, updated_at, version FROM weights;
+	DROP TABLE weights;
+	ALTER TABLE weights_new RENAME TO weights;
+	CREATE INDEX idx_weights_date ON weights(date DESC);
+	CREATE UNIQUE INDEX idx_weights_date_active ON weights(date) WHERE deleted_at IS NULL;
+	CREATE INDEX idx_weights_deleted_at ON weights(deleted_at) WHERE deleted_at IS NOT NULL;`,
+
+	// 3: append-only audit log of every change to weights and the goal
+	`CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity TEXT NOT NULL,
+		entity_id INTEGER,
+		action TEXT NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		actor TEXT NOT NULL,
+		source_ip TEXT,
+		user_agent TEXT,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id, id DESC);
+	CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;
+	CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;`,
+
+	// 4: free-text notes and many-to-many tags on weight entries
+	`ALTER TABLE weights ADD COLUMN note TEXT;
+	CREATE TABLE tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		exclude_from_stats INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE weight_tags (
+		weight_id INTEGER NOT NULL REFERENCES weights(id) ON DELETE CASCADE,
+		tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (weight_id, tag_id)
+	);
+	CREATE INDEX idx_weight_tags_tag ON weight_tags(tag_id);`,
+
+	// 5: outgoing webhook subscriptions and their delivery log
+	`CREATE TABLE webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		active INTEGER NOT NULL DEFAULT 1,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event TEXT NOT NULL,
+		event_id TEXT,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_status INTEGER,
+		error TEXT,
+		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id DESC);
+	CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(status) WHERE status = 'pending';`,
+
+	// 6: offline sync; every change to a weight entry takes the next value
+	// of a global change sequence, hard deletions leave a tombstone, and
+	// each field records when it last changed for conflict resolution
+	`CREATE TABLE sync_state (seq INTEGER NOT NULL);
+	INSERT INTO sync_state (seq) VALUES (0);
+	ALTER TABLE weights ADD COLUMN uuid TEXT;
+	ALTER TABLE weights ADD COLUMN change_seq INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE weights ADD COLUMN date_changed_at TEXT;
+	ALTER TABLE weights ADD COLUMN pounds_changed_at TEXT;
+	ALTER TABLE weights ADD COLUMN note_changed_at TEXT;
+	ALTER TABLE weights ADD COLUMN tags_changed_at TEXT;
+	ALTER TABLE weights ADD COLUMN deleted_changed_at TEXT;
+	UPDATE weights SET uuid = ` + sqliteUUID + `;
+	CREATE UNIQUE INDEX idx_weights_uuid ON weights(uuid);
+	CREATE INDEX idx_weights_change_seq ON weights(change_seq);
+	CREATE TABLE weight_tombstones (
+		uuid TEXT PRIMARY KEY,
+		change_seq INTEGER NOT NULL
+	);
+	CREATE INDEX idx_weight_tombstones_change_seq ON weight_tombstones(change_seq);
+	CREATE TRIGGER weights_sync_insert AFTER INSERT ON weights
+	BEGIN
+		UPDATE sync_state SET seq = seq + 1;
+		UPDATE weights SET change_seq = (SELECT seq FROM sync_state), uuid = COALESCE(NEW.uuid, ` + sqliteUUID + `)
+			WHERE id = NEW.id;
+	END;
+	CREATE TRIGGER weights_sync_update AFTER UPDATE ON weights WHEN NEW.change_seq = OLD.change_seq
+	BEGIN
+		UPDATE sync_state SET seq = seq + 1;
+		UPDATE weights SET change_seq = (SELECT seq FROM sync_state) WHERE id = NEW.id;
+	END;
+	CREATE TRIGGER weights_sync_delete AFTER DELETE ON weights
+	BEGIN
+		UPDATE sync_state SET seq = seq + 1;
+		INSERT INTO weight_tombstones (uuid, change_seq) VALUES (OLD.uuid, (SELECT seq FROM sync_state));
+	END;`,
+}
+
+// sqliteUUID generates a random (version 4) UUID in SQL, which SQLite has no
+// function for
+const sqliteUUID = `lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+	substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) ||
+	substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)))`
+
+// SchemaVersion returns the schema version of the open database
+func SchemaVersion() (int, error) {
+	return SchemaVersionContext(context.Background())
+}
+
+// SchemaVersionContext is SchemaVersion, giving up when ctx is done
+func SchemaVersionContext(ctx context.Context) (int, error) {
+	query := "PRAGMA user_version"
+	if dialect == Postgres {
+		query = "SELECT version FROM schema_version"
+	}
+
+	var version int
+	err := DB.QueryRowContext(ctx, query).Scan(&version)
+	return version, err
+}
+
+// LatestSchemaVersion returns the schema version reached once every
+// migration has been applied. It is the same for both dialects.
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+// FilePath returns the path of the main database file, or "" for an
+// in-memory or Postgres database
+func FilePath() (string, error) {
+	return FilePathContext(context.Background())
+}
+
+// FilePathContext is FilePath, giving up when ctx is done
+func FilePathContext(ctx context.Context) (string, error) {
+	if dialect == Postgres {
+		return "", nil
+	}
+
+	rows, err := DB.QueryContext(ctx, "PRAGMA database_list")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", err
+		}
+		if name == "main" {
+			return file, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// Size returns the size of the main database in bytes, excluding the WAL
+func Size() (int64, error) {
+	return SizeContext(context.Background())
+}
+
+// SizeContext is Size, giving up when ctx is done
+func SizeContext(ctx context.Context) (int64, error) {
+	query := "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	if dialect == Postgres {
+		query = "SELECT pg_database_size(current_database())"
+	}
+
+	var size int64
+	err := DB.QueryRowContext(ctx, query).Scan(&size)
+	return size, err
+}
+
+// applyMigrations runs every migration newer than the database's current
+// schema version, each in its own transaction
+func applyMigrations() error {
+	current, err := SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := DB.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+
+		// PRAGMA statements cannot take bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+
+		slog.Info("Applied database migration", "version", i+1)
+	}
+
+	return nil
+}
+
+// PurgeTrash permanently removes weight entries that have been in the trash
+// for longer than retention and returns the number of entries removed. Each
+// removal is recorded in the audit log with the "system" actor.
+func PurgeTrash(retention time.Duration) (int64, error) {
+	cutoff := time.Now().UTC().Add(-retention).Format("2006-01-02 15:04:05")
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO audit_log (entity, entity_id, action, old_value, actor)
+		SELECT 'weight', id, 'purge', `+weightSnapshot()+`, 'system'
+		FROM weights WHERE deleted_at IS NOT NULL AND deleted_at < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record purge: %w", err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM weight_tags WHERE weight_id IN (
+		SELECT id FROM weights WHERE deleted_at IS NOT NULL AND deleted_at < ?)`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+
+	result, err := tx.Exec("DELETE FROM weights WHERE deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+
+	purged, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+
+	return purged, nil
+}
+
+// CloseDB checkpoints the write-ahead log into the main database file, if
+// there is one, and closes both pools
 func CloseDB() error {
+	if ReadDB != nil && ReadDB != DB {
+		if err := ReadDB.Close(); err != nil {
+			slog.Error("Failed to close read pool", "error", err)
+		}
+	}
 	if DB != nil {
+		if dialect == SQLite {
+			if _, err := DB.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+				slog.Error("Failed to checkpoint database", "error", err)
+			}
+		}
 		return DB.Close()
 	}
 	return nil
 }
+
+// isMemory reports whether dbPath names an in-memory database
+func isMemory(dbPath string) bool {
+	return dbPath == ":memory:" || strings.Contains(dbPath, "mode=memory")
+}
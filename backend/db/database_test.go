@@ -1,16 +1,16 @@
 package db
 
 import (
-	"os"
+	"context"
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestInitDB_Success(t *testing.T) {
 	// Use in-memory database for testing
-	os.Setenv("DATABASE_PATH", ":memory:")
-	defer os.Unsetenv("DATABASE_PATH")
-
-	err := InitDB()
+	err := InitDB(":memory:", DefaultOptions())
 	if err != nil {
 		t.Fatalf("Expected InitDB to succeed, got error: %v", err)
 	}
@@ -34,11 +34,8 @@ func TestInitDB_Success(t *testing.T) {
 }
 
 func TestInitDB_InvalidPath(t *testing.T) {
-	// Set invalid path (directory that doesn't exist and can't be created)
-	os.Setenv("DATABASE_PATH", "/invalid/path/that/does/not/exist/database.db")
-	defer os.Unsetenv("DATABASE_PATH")
-
-	err := InitDB()
+	// Use invalid path (directory that doesn't exist and can't be created)
+	err := InitDB("/invalid/path/that/does/not/exist/database.db", DefaultOptions())
 	if err == nil {
 		t.Error("Expected InitDB to fail with invalid path")
 		CloseDB()
@@ -46,10 +43,7 @@ func TestInitDB_InvalidPath(t *testing.T) {
 }
 
 func TestCloseDB(t *testing.T) {
-	os.Setenv("DATABASE_PATH", ":memory:")
-	defer os.Unsetenv("DATABASE_PATH")
-
-	if err := InitDB(); err != nil {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
 		t.Fatalf("Failed to initialize database: %v", err)
 	}
 
@@ -76,11 +70,8 @@ func TestCloseDB_NilDatabase(t *testing.T) {
 }
 
 func TestCreateTables_Idempotent(t *testing.T) {
-	os.Setenv("DATABASE_PATH", ":memory:")
-	defer os.Unsetenv("DATABASE_PATH")
-
 	// Initialize once
-	if err := InitDB(); err != nil {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
 		t.Fatalf("First InitDB failed: %v", err)
 	}
 
@@ -93,10 +84,7 @@ func TestCreateTables_Idempotent(t *testing.T) {
 }
 
 func TestInitDB_GoalWeightSetting(t *testing.T) {
-	os.Setenv("DATABASE_PATH", ":memory:")
-	defer os.Unsetenv("DATABASE_PATH")
-
-	if err := InitDB(); err != nil {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
 		t.Fatalf("InitDB failed: %v", err)
 	}
 	defer CloseDB()
@@ -114,3 +102,189 @@ func TestInitDB_GoalWeightSetting(t *testing.T) {
 		t.Errorf("Expected 1 goal_weight setting, got %d", count)
 	}
 }
+
+func TestMigrate_Idempotent(t *testing.T) {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	// Running migrations again should be a no-op
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate should be idempotent, got error: %v", err)
+	}
+
+	version, err := SchemaVersion()
+	if err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+
+	if version != len(migrations) {
+		t.Errorf("Expected schema version %d, got %d", len(migrations), version)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+	DB.Exec("INSERT INTO weights (date, pounds, deleted_at) VALUES ('2026-01-02', 170.0, '2020-01-01 00:00:00')")
+	DB.Exec("INSERT INTO weights (date, pounds, deleted_at) VALUES ('2026-01-03', 170.0, CURRENT_TIMESTAMP)")
+
+	purged, err := PurgeTrash(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeTrash failed: %v", err)
+	}
+
+	if purged != 1 {
+		t.Errorf("Expected 1 purged entry, got %d", purged)
+	}
+
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM weights").Scan(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 remaining entries, got %d", count)
+	}
+
+	// Purges are recorded in the audit log
+	DB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE action = 'purge' AND actor = 'system'").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 purge audit entry, got %d", count)
+	}
+}
+
+// recordingHook collects the operations it observes
+type recordingHook struct {
+	operations []string
+	errors     int
+}
+
+func (h *recordingHook) BeforeQuery(ctx context.Context, operation, query string) context.Context {
+	return ctx
+}
+
+func (h *recordingHook) AfterQuery(ctx context.Context, operation, query string, err error) {
+	h.operations = append(h.operations, operation)
+	if err != nil {
+		h.errors++
+	}
+}
+
+func TestQueryHook_ObservesStatements(t *testing.T) {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	hook := &recordingHook{}
+	AddQueryHook(hook)
+	defer func() { hooks = nil }()
+
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+	DB.QueryRow("SELECT COUNT(*) FROM weights").Scan(new(int))
+	DB.Exec("INSERT INTO missing_table VALUES (1)")
+
+	want := []string{"insert", "select", "insert"}
+	if len(hook.operations) != len(want) {
+		t.Fatalf("Expected operations %v, got %v", want, hook.operations)
+	}
+	for i := range want {
+		if hook.operations[i] != want[i] {
+			t.Errorf("Expected operation %s at %d, got %s", want[i], i, hook.operations[i])
+		}
+	}
+	if hook.errors != 1 {
+		t.Errorf("Expected 1 failed statement, got %d", hook.errors)
+	}
+}
+
+func TestInitDB_Options(t *testing.T) {
+	if err := InitDB(filepath.Join(t.TempDir(), "wt.db"), DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	var journalMode string
+	DB.QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+	if journalMode != "wal" {
+		t.Errorf("Expected journal mode wal, got %s", journalMode)
+	}
+
+	// Pragmas apply to every pooled connection, readers included
+	var foreignKeys, busyTimeout int
+	ReadDB.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys)
+	ReadDB.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout)
+	if foreignKeys != 1 || busyTimeout != 5000 {
+		t.Errorf("Expected foreign_keys 1 and busy_timeout 5000 on readers, got %d and %d", foreignKeys, busyTimeout)
+	}
+
+	if _, err := ReadDB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)"); err == nil {
+		t.Error("Expected the read pool to reject writes")
+	}
+
+	if stats := DB.Stats(); stats.MaxOpenConnections != 1 {
+		t.Errorf("Expected a single writer connection, got %d", stats.MaxOpenConnections)
+	}
+	if stats := ReadDB.Stats(); stats.MaxOpenConnections != 4 {
+		t.Errorf("Expected 4 reader connections, got %d", stats.MaxOpenConnections)
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+
+	_, err := DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 169.0)")
+	if !IsUniqueViolation(err) {
+		t.Errorf("Expected duplicate date to be a unique violation, got %v", err)
+	}
+
+	_, err = DB.Exec("INSERT INTO weights (date) VALUES ('2026-01-02')")
+	if err == nil || IsUniqueViolation(err) {
+		t.Errorf("Expected NOT NULL failure not to be a unique violation, got %v", err)
+	}
+}
+
+func TestWeightError(t *testing.T) {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+
+	err := WeightError(DB.QueryRow("SELECT id FROM weights WHERE id = 999").Scan(new(int)))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	_, err = DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 169.0)")
+	if err = WeightError(err); !errors.Is(err, ErrDuplicateDate) {
+		t.Errorf("Expected ErrDuplicateDate, got %v", err)
+	}
+
+	// Only the date index means a duplicate date
+	DB.Exec("UPDATE weights SET uuid = 'a' WHERE id = 1")
+	_, err = DB.Exec("INSERT INTO weights (uuid, date, pounds) VALUES ('a', '2026-01-02', 169.0)")
+	if translated := WeightError(err); !IsUniqueViolation(err) || errors.Is(translated, ErrDuplicateDate) {
+		t.Errorf("Expected a uuid violation not to be a duplicate date, got %v", translated)
+	}
+
+	_, err = DB.Exec("INSERT INTO weights (date) VALUES ('2026-01-02')")
+	if err = WeightError(err); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation, got %v", err)
+	}
+
+	// Other errors pass through unchanged
+	_, err = DB.Exec("INSERT INTO missing_table VALUES (1)")
+	if translated := WeightError(err); translated != err {
+		t.Errorf("Expected unrelated error unchanged, got %v", translated)
+	}
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Dialect identifies the SQL database behind DB and ReadDB
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// dialect is set by InitDB and InitPostgres
+var dialect = SQLite
+
+// CurrentDialect returns the dialect of the open database
+func CurrentDialect() Dialect {
+	return dialect
+}
+
+// pgNow renders the current UTC time in the same "YYYY-MM-DD HH:MM:SS" text
+// form as SQLite's CURRENT_TIMESTAMP, so timestamps compare and parse the
+// same on both databases
+const pgNow = "to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS')"
+
+// Now returns an SQL expression for the current UTC timestamp as text
+func Now() string {
+	if dialect == Postgres {
+		return pgNow
+	}
+	return "CURRENT_TIMESTAMP"
+}
+
+// JSONArrayAgg returns the aggregate function that collects values into a
+// JSON array. Over no rows it returns "[]" on SQLite and NULL on Postgres.
+func JSONArrayAgg() string {
+	if dialect == Postgres {
+		return "json_agg"
+	}
+	return "json_group_array"
+}
+
+// JSONObject returns the function that builds a JSON object from alternating
+// keys and values
+func JSONObject() string {
+	if dialect == Postgres {
+		return "json_build_object"
+	}
+	return "json_object"
+}
+
+// IsUniqueViolation reports whether err was caused by a unique constraint or
+// index, judged by the driver's error code rather than its message
+func IsUniqueViolation(err error) bool {
+	return hasErrorCode(err, sqliteConstraintUnique, pgUniqueViolation)
+}
+
+// weightsDateIndex is the unique index keeping active entries' dates apart
+const weightsDateIndex = "idx_weights_date_active"
+
+// isDateViolation reports whether a unique violation on weights was caused
+// by the date index rather than another unique column, such as uuid.
+// Postgres names the violated index; SQLite only names its columns, in the
+// error message.
+func isDateViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName == weightsDateIndex
+	}
+	return strings.Contains(err.Error(), "weights.date")
+}
+
+// Constraint violation codes: SQLite extended result codes and Postgres
+// SQLSTATEs
+const (
+	sqliteConstraintUnique     = 2067
+	sqliteConstraintNotNull    = 1299
+	sqliteConstraintCheck      = 275
+	sqliteConstraintForeignKey = 787
+
+	pgUniqueViolation     = "23505"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgForeignKeyViolation = "23503"
+)
+
+// hasErrorCode reports whether err carries the given SQLite or Postgres code
+func hasErrorCode(err error, sqliteCode int, pgCode string) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgCode
+	}
+	code, ok := sqliteErrorCode(err)
+	return ok && code == sqliteCode
+}
+
+// rebind rewrites "?" placeholders to Postgres' "$1", "$2", ... so that the
+// handlers' SQL runs unchanged on both databases. Question marks inside
+// string literals, quoted identifiers, comments and dollar-quoted bodies
+// are left alone.
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		case ch == '\'' || ch == '"':
+			end := strings.IndexByte(query[i+1:], ch)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+			continue
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end
+			continue
+		case ch == '$' && strings.HasPrefix(query[i:], "$$"):
+			end := strings.Index(query[i+2:], "$$")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+			continue
+		}
+		b.WriteByte(ch)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,28 @@
+//go:build !purego
+
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDriver names the SQLite implementation compiled in. This build uses
+// mattn/go-sqlite3, which requires CGO; build with -tags purego to use the
+// pure-Go driver instead.
+const SQLiteDriver = "mattn/go-sqlite3"
+
+func newSQLiteDriver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// sqliteErrorCode returns the extended result code of a SQLite error
+func sqliteErrorCode(err error) (int, bool) {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return int(sqliteErr.ExtendedCode), true
+	}
+	return 0, false
+}
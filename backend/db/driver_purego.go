@@ -0,0 +1,27 @@
+//go:build purego
+
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"modernc.org/sqlite"
+)
+
+// SQLiteDriver names the SQLite implementation compiled in. This build uses
+// modernc.org/sqlite, a pure-Go translation of SQLite that needs no CGO.
+const SQLiteDriver = "modernc.org/sqlite"
+
+func newSQLiteDriver() driver.Driver {
+	return &sqlite.Driver{}
+}
+
+// sqliteErrorCode returns the extended result code of a SQLite error
+func sqliteErrorCode(err error) (int, bool) {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Domain errors returned by the storage layer in place of driver errors, so
+// callers can tell outcomes apart with errors.Is instead of matching driver
+// messages
+var (
+	// ErrNotFound means no row matched
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicateDate means an active weight entry already has the date
+	ErrDuplicateDate = errors.New("duplicate weight entry date")
+	// ErrDuplicateTag means a tag already has the name, ignoring case
+	ErrDuplicateTag = errors.New("duplicate tag name")
+	// ErrValidation means a value was rejected, by the caller or by a NOT
+	// NULL, CHECK or foreign key constraint
+	ErrValidation = errors.New("validation failed")
+	// ErrModified means the row changed since the version the caller read
+	ErrModified = errors.New("resource has been modified")
+)
+
+// Error is a domain error with a message for clients. It matches its Kind,
+// one of the errors above, with errors.Is and unwraps to the driver error
+// that caused it, if any.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" {
+		message = e.Kind.Error()
+	}
+	if e.Err != nil {
+		return message + ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WeightError translates an error from a statement on weights into a domain
+// error, returning other errors unchanged. Only a violation of the date index
+// is a duplicate date; other unique violations, such as on uuid, pass
+// through.
+func WeightError(err error) error {
+	if IsUniqueViolation(err) && !isDateViolation(err) {
+		return err
+	}
+	return translate(err, "Weight entry not found", &Error{
+		Kind:    ErrDuplicateDate,
+		Message: "Weight entry already exists for this date",
+		Err:     err,
+	})
+}
+
+// TagError translates an error from a statement on tags into a domain
+// error, returning other errors unchanged
+func TagError(err error) error {
+	return translate(err, "Tag not found", &Error{
+		Kind:    ErrDuplicateTag,
+		Message: "Tag already exists",
+		Err:     err,
+	})
+}
+
+// translate maps missing rows to ErrNotFound, unique violations to
+// duplicate and other constraint violations to ErrValidation
+func translate(err error, notFound string, duplicate *Error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return &Error{Kind: ErrNotFound, Message: notFound, Err: err}
+	case IsUniqueViolation(err):
+		return duplicate
+	case hasErrorCode(err, sqliteConstraintNotNull, pgNotNullViolation),
+		hasErrorCode(err, sqliteConstraintCheck, pgCheckViolation),
+		hasErrorCode(err, sqliteConstraintForeignKey, pgForeignKeyViolation):
+		return &Error{Kind: ErrValidation, Message: "Invalid value", Err: err}
+	}
+	return err
+}
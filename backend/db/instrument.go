@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// DriverName is the database/sql driver used by InitDB. It wraps the SQLite
+// driver selected at build time (see SQLiteDriver) so that registered
+// QueryHooks observe every statement.
+const DriverName = "sqlite3-instrumented"
+
+func init() {
+	sql.Register(DriverName, &instrumentedDriver{parent: newSQLiteDriver()})
+}
+
+// QueryHook is notified around every statement executed through DriverName.
+// BeforeQuery may return a derived context, which is passed to AfterQuery.
+type QueryHook interface {
+	BeforeQuery(ctx context.Context, operation, query string) context.Context
+	AfterQuery(ctx context.Context, operation, query string, err error)
+}
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []QueryHook
+)
+
+// AddQueryHook registers a hook for all subsequent statements
+func AddQueryHook(hook QueryHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// Operation returns the lower-cased leading keyword of a statement, such as
+// "select" or "insert", for use as a low-cardinality label
+func Operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	return strings.ToLower(fields[0])
+}
+
+// observe runs fn between the registered hooks
+func observe(ctx context.Context, query string, fn func(ctx context.Context) error) error {
+	hooksMu.RLock()
+	active := hooks
+	hooksMu.RUnlock()
+
+	if len(active) == 0 {
+		return fn(ctx)
+	}
+
+	operation := Operation(query)
+	contexts := make([]context.Context, len(active))
+	for i, hook := range active {
+		ctx = hook.BeforeQuery(ctx, operation, query)
+		contexts[i] = ctx
+	}
+
+	err := fn(ctx)
+
+	for i := len(active) - 1; i >= 0; i-- {
+		active[i].AfterQuery(contexts[i], operation, query, err)
+	}
+	return err
+}
+
+// connector opens instrumented connections to dsn, running each pragma on
+// every new connection since most pragmas are per-connection
+type connector struct {
+	driver  *instrumentedDriver
+	dsn     string
+	pragmas []string
+}
+
+func newConnector(dsn string, pragmas []string) *connector {
+	return &connector{
+		driver:  &instrumentedDriver{parent: newSQLiteDriver()},
+		dsn:     dsn,
+		pragmas: pragmas,
+	}
+}
+
+// newPostgresConnector opens instrumented pgx connections to the database
+// URL dsn, rewriting "?" placeholders for Postgres
+func newPostgresConnector(dsn string) *connector {
+	return &connector{
+		driver: &instrumentedDriver{parent: stdlib.GetDefaultDriver(), rebind: true},
+		dsn:    dsn,
+	}
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("driver does not support running pragmas")
+	}
+	for _, pragma := range c.pragmas {
+		if _, err := execer.ExecContext(ctx, "PRAGMA "+pragma, nil); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to set PRAGMA %s: %w", pragma, err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// instrumentedDriver wraps a driver so its connections report to hooks.
+// With rebind set, statements are rewritten with Postgres placeholders.
+type instrumentedDriver struct {
+	parent driver.Driver
+	rebind bool
+}
+
+func (d *instrumentedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, rebind: d.rebind}, nil
+}
+
+// instrumentedConn times statements run directly on the connection, which
+// is how database/sql executes Exec and Query calls for both drivers
+type instrumentedConn struct {
+	driver.Conn
+	rebind bool
+}
+
+// statement returns query as it should be sent to the driver
+func (c *instrumentedConn) statement(query string) string {
+	if c.rebind {
+		return rebind(query)
+	}
+	return query
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	query = c.statement(query)
+
+	var result driver.Result
+	err := observe(ctx, query, func(ctx context.Context) error {
+		var err error
+		result, err = execer.ExecContext(ctx, query, args)
+		return err
+	})
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	query = c.statement(query)
+
+	var rows driver.Rows
+	err := observe(ctx, query, func(ctx context.Context) error {
+		var err error
+		rows, err = queryer.QueryContext(ctx, query, args)
+		return err
+	})
+	return rows, err
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query = c.statement(query)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue lets drivers such as pgx accept argument types beyond the
+// database/sql defaults
+func (c *instrumentedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
@@ -0,0 +1,334 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BackupPrefix starts the file name of every backup made by Backup
+const BackupPrefix = "weight-tracker-"
+
+// errSQLiteOnly is returned by maintenance that only applies to SQLite files
+var errSQLiteOnly = errors.New("only supported for SQLite databases; use the PostgreSQL tools (pg_dump, amcheck) instead")
+
+// weightSnapshot is the JSON snapshot of a weights row recorded in the audit
+// log by system changes
+func weightSnapshot() string {
+	return `CAST(` + JSONObject() + `(
+		'id', id, 'date', date, 'pounds', pounds, 'note', note, 'version', version,
+		'created_at', created_at, 'updated_at', updated_at, 'deleted_at', deleted_at
+	) AS TEXT)`
+}
+
+// Backup writes a consistent copy of the open SQLite database to dir as
+// weight-tracker-YYYYmmdd-HHMMSS.db and returns its path. It is safe while
+// the database is in use.
+func Backup(dir string) (string, error) {
+	if dialect == Postgres {
+		return "", errSQLiteOnly
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	path := filepath.Join(dir, BackupPrefix+time.Now().Format("20060102-150405")+".db")
+	if _, err := DB.Exec("VACUUM INTO ?", path); err != nil {
+		return "", fmt.Errorf("failed to back up database: %w", err)
+	}
+	return path, nil
+}
+
+// Restore replaces the SQLite database at dbPath with a copy of the backup
+// at src, after checking the backup's integrity. It fails when the database
+// is open, in this process or any other.
+func Restore(src, dbPath string) error {
+	if err := checkBackup(src); err != nil {
+		return err
+	}
+
+	// Hold an exclusive lock on the database until it has been replaced, so
+	// that no server loses writes or reads a half-swapped file
+	unlock, err := lockDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Copy next to the target and rename over it, so a failed copy leaves
+	// the current database in place
+	tmp := dbPath + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+
+	// A leftover write-ahead log belongs to the replaced database
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to remove %s: %w", dbPath+suffix, err)
+		}
+	}
+
+	if err := os.Rename(tmp, dbPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+// lockDatabase takes an exclusive lock on the SQLite database at path,
+// failing at once if any other connection has it open, and returns a func
+// releasing it. A missing database needs no lock.
+func lockDatabase(path string) (func(), error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return func() {}, nil
+	}
+
+	// In exclusive locking mode the lock outlives the transaction taking it,
+	// for as long as the connection stays open; even idle connections to a
+	// WAL database keep it from being taken
+	target := sql.OpenDB(newConnector(path, []string{"busy_timeout = 0", "locking_mode = EXCLUSIVE"}))
+	target.SetMaxOpenConns(1)
+	for _, statement := range []string{"BEGIN EXCLUSIVE", "COMMIT"} {
+		if _, err := target.Exec(statement); err != nil {
+			target.Close()
+			return nil, fmt.Errorf("database %s is in use; stop the server first: %w", path, err)
+		}
+	}
+	return func() { target.Close() }, nil
+}
+
+// checkBackup verifies that path is an intact database with a schema this
+// build can migrate
+func checkBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	backup := sql.OpenDB(newConnector(path, []string{"query_only = ON"}))
+	defer backup.Close()
+
+	problems, err := integrityCheck(backup)
+	if err != nil {
+		return fmt.Errorf("failed to check backup: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("backup %s is corrupt: %s", path, problems[0])
+	}
+
+	var version int
+	if err := backup.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read backup schema version: %w", err)
+	}
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("backup schema version %d is newer than this build supports (%d)", version, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// copyFile copies src to dst and syncs it to disk
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Vacuum rebuilds the database to reclaim free space and refresh the query
+// planner's statistics
+func Vacuum() error {
+	statements := []string{"VACUUM", "PRAGMA optimize"}
+	if dialect == Postgres {
+		statements = []string{"VACUUM ANALYZE"}
+	}
+
+	for _, statement := range statements {
+		if _, err := DB.Exec(statement); err != nil {
+			return fmt.Errorf("failed to vacuum database: %w", err)
+		}
+	}
+	return nil
+}
+
+// IntegrityCheck checks the open SQLite database for corruption and foreign
+// key violations, returning a description of each problem found
+func IntegrityCheck() ([]string, error) {
+	if dialect == Postgres {
+		return nil, errSQLiteOnly
+	}
+	return integrityCheck(DB)
+}
+
+// integrityCheck runs SQLite's integrity and foreign key checks on conn
+func integrityCheck(conn *sql.DB) ([]string, error) {
+	rows, err := conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, err
+		}
+		// A healthy database reports a single "ok"
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fkRows, err := conn.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, err
+	}
+	defer fkRows.Close()
+
+	for fkRows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := fkRows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return nil, err
+		}
+		problems = append(problems, fmt.Sprintf("%s row %d references a missing %s row", table, rowid.Int64, parent))
+	}
+	return problems, fkRows.Err()
+}
+
+// SeedWeight is a weight entry added by Seed
+type SeedWeight struct {
+	Date   string
+	Pounds float64
+}
+
+// Seed adds weight entries, skipping dates that already have an active
+// entry, and returns the number added. Each addition is recorded in the
+// audit log with the "system" actor.
+func Seed(weights []SeedWeight) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to seed weights: %w", err)
+	}
+	defer tx.Rollback()
+
+	insert := `INSERT INTO weights (date, pounds, created_at, updated_at)
+		SELECT ?, ?, ` + Now() + `, ` + Now() + `
+		WHERE NOT EXISTS (SELECT 1 FROM weights WHERE date = ? AND deleted_at IS NULL)
+		RETURNING id`
+	audit := `INSERT INTO audit_log (entity, entity_id, action, new_value, actor)
+		SELECT 'weight', id, 'create', ` + weightSnapshot() + `, 'system' FROM weights WHERE id = ?`
+
+	added := 0
+	for _, w := range weights {
+		var id int
+		err := tx.QueryRow(insert, w.Date, w.Pounds, w.Date).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to seed weight for %s: %w", w.Date, err)
+		}
+		if _, err := tx.Exec(audit, id); err != nil {
+			return 0, fmt.Errorf("failed to record seeded weight: %w", err)
+		}
+		added++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to seed weights: %w", err)
+	}
+	return added, nil
+}
+
+// WipeResult counts what Wipe removed
+type WipeResult struct {
+	Weights     int64
+	Tags        int64
+	GoalCleared bool
+}
+
+// Wipe permanently removes every weight entry, including the trash, and
+// every tag, and clears the goal. The audit log is append-only and is kept;
+// the removals are recorded in it with the "system" actor.
+func Wipe() (WipeResult, error) {
+	var result WipeResult
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to wipe database: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO audit_log (entity, entity_id, action, old_value, actor)
+		SELECT 'weight', id, 'purge', ` + weightSnapshot() + `, 'system' FROM weights`)
+	if err != nil {
+		return result, fmt.Errorf("failed to record wipe: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO audit_log (entity, action, old_value, new_value, actor)
+		SELECT 'goal', 'update',
+			CAST(` + JSONObject() + `('pounds', CAST(value AS REAL), 'updated_at', updated_at) AS TEXT),
+			CAST(` + JSONObject() + `('pounds', NULL, 'updated_at', ` + Now() + `) AS TEXT),
+			'system'
+		FROM settings WHERE key = 'goal_weight' AND value IS NOT NULL`)
+	if err != nil {
+		return result, fmt.Errorf("failed to record wipe: %w", err)
+	}
+
+	statements := []struct {
+		query string
+		count *int64
+	}{
+		{"DELETE FROM weight_tags", nil},
+		{"DELETE FROM weights", &result.Weights},
+		{"DELETE FROM tags", &result.Tags},
+	}
+	for _, statement := range statements {
+		res, err := tx.Exec(statement.query)
+		if err != nil {
+			return result, fmt.Errorf("failed to wipe database: %w", err)
+		}
+		if statement.count != nil {
+			*statement.count, _ = res.RowsAffected()
+		}
+	}
+
+	res, err := tx.Exec(`UPDATE settings SET value = NULL, version = version + 1, updated_at = ` + Now() + `
+		WHERE key = 'goal_weight' AND value IS NOT NULL`)
+	if err != nil {
+		return result, fmt.Errorf("failed to clear goal: %w", err)
+	}
+	cleared, _ := res.RowsAffected()
+	result.GoalCleared = cleared > 0
+
+	if err := tx.Commit(); err != nil {
+		return WipeResult{}, fmt.Errorf("failed to wipe database: %w", err)
+	}
+	return result, nil
+}
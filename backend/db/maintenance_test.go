@@ -0,0 +1,219 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "weights.db")
+
+	if err := InitDB(dbPath, DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+
+	backup, err := Backup(filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if name := filepath.Base(backup); !strings.HasPrefix(name, BackupPrefix) || !strings.HasSuffix(name, ".db") {
+		t.Errorf("Unexpected backup name %q", name)
+	}
+
+	// Changes after the backup are undone by restoring it
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-02', 171.0)")
+	CloseDB()
+
+	if err := Restore(backup, dbPath); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if _, err := os.Stat(dbPath + ".restore"); !os.IsNotExist(err) {
+		t.Error("Expected the temporary copy to be renamed")
+	}
+
+	if err := InitDB(dbPath, DefaultOptions()); err != nil {
+		t.Fatalf("InitDB after restore failed: %v", err)
+	}
+	defer CloseDB()
+
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM weights").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 entry after restore, got %d", count)
+	}
+}
+
+func TestRestore_RefusesOpenDatabase(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "weights.db")
+
+	if err := InitDB(dbPath, DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	backup, err := Backup(filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+
+	// An idle open connection, as a running server holds, blocks a restore
+	if err := Restore(backup, dbPath); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("Expected restoring over an open database to fail, got %v", err)
+	}
+
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM weights").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected the open database untouched, got %d entries", count)
+	}
+	if _, err := os.Stat(dbPath + "-wal"); err != nil {
+		t.Errorf("Expected the write-ahead log kept, got %v", err)
+	}
+
+	CloseDB()
+	if err := Restore(backup, dbPath); err != nil {
+		t.Errorf("Expected restoring once closed to succeed, got %v", err)
+	}
+}
+
+func TestRestore_RejectsInvalidBackup(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "weights.db")
+	os.WriteFile(dbPath, []byte("current"), 0o644)
+
+	garbage := filepath.Join(dir, "garbage.db")
+	os.WriteFile(garbage, []byte("not a database, just some text that is long enough"), 0o644)
+
+	for _, src := range []string{garbage, filepath.Join(dir, "missing.db")} {
+		if err := Restore(src, dbPath); err == nil {
+			t.Errorf("Expected restoring %s to fail", filepath.Base(src))
+		}
+	}
+
+	// The current database is left alone
+	if data, _ := os.ReadFile(dbPath); string(data) != "current" {
+		t.Errorf("Expected the database to be unchanged, got %q", data)
+	}
+}
+
+func TestIntegrityCheck(t *testing.T) {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	problems, err := IntegrityCheck()
+	if err != nil {
+		t.Fatalf("IntegrityCheck failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+
+	// A tag link to a missing entry is reported
+	DB.Exec("PRAGMA foreign_keys = OFF")
+	DB.Exec("INSERT INTO tags (name) VALUES ('holiday')")
+	DB.Exec("INSERT INTO weight_tags (weight_id, tag_id) VALUES (99, 1)")
+
+	problems, err = IntegrityCheck()
+	if err != nil {
+		t.Fatalf("IntegrityCheck failed: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0], "weight_tags") {
+		t.Errorf("Expected one weight_tags problem, got %v", problems)
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	if err := InitDB(filepath.Join(t.TempDir(), "weights.db"), DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	if err := Vacuum(); err != nil {
+		t.Errorf("Vacuum failed: %v", err)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-02', 180.0)")
+
+	added, err := Seed([]SeedWeight{
+		{Date: "2026-01-01", Pounds: 170.1},
+		{Date: "2026-01-02", Pounds: 170.2},
+		{Date: "2026-01-03", Pounds: 170.3},
+	})
+	if err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("Expected 2 entries added, got %d", added)
+	}
+
+	// Existing entries are kept
+	var pounds float64
+	DB.QueryRow("SELECT pounds FROM weights WHERE date = '2026-01-02'").Scan(&pounds)
+	if pounds != 180.0 {
+		t.Errorf("Expected the existing entry to be kept, got %v", pounds)
+	}
+
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE action = 'create' AND actor = 'system'").Scan(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 create audit entries, got %d", count)
+	}
+}
+
+func TestWipe(t *testing.T) {
+	if err := InitDB(":memory:", DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer CloseDB()
+
+	DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+	DB.Exec("INSERT INTO weights (date, pounds, deleted_at) VALUES ('2026-01-02', 171.0, CURRENT_TIMESTAMP)")
+	DB.Exec("INSERT INTO tags (name) VALUES ('holiday')")
+	DB.Exec("INSERT INTO weight_tags (weight_id, tag_id) VALUES (1, 1)")
+	DB.Exec("UPDATE settings SET value = '160' WHERE key = 'goal_weight'")
+
+	result, err := Wipe()
+	if err != nil {
+		t.Fatalf("Wipe failed: %v", err)
+	}
+	if result != (WipeResult{Weights: 2, Tags: 1, GoalCleared: true}) {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM weights").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no entries, got %d", count)
+	}
+
+	var goal *string
+	DB.QueryRow("SELECT value FROM settings WHERE key = 'goal_weight'").Scan(&goal)
+	if goal != nil {
+		t.Errorf("Expected the goal to be cleared, got %q", *goal)
+	}
+
+	// The removals are recorded
+	DB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE actor = 'system'").Scan(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 audit entries, got %d", count)
+	}
+
+	// Wiping an empty database changes nothing
+	result, err = Wipe()
+	if err != nil || result != (WipeResult{}) {
+		t.Errorf("Expected an empty wipe, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+)
+
+// InitPostgres opens a pool of at most maxConns connections to the
+// PostgreSQL database at databaseURL, a postgres:// URL, and creates or
+// migrates the schema. Postgres handles concurrent writers itself, so DB and
+// ReadDB share the pool.
+func InitPostgres(databaseURL string, maxConns int) error {
+	slog.Info("Initializing database", "url", redactURL(databaseURL), "max_conns", maxConns)
+
+	pool := sql.OpenDB(newPostgresConnector(databaseURL))
+	pool.SetMaxOpenConns(maxConns)
+	pool.SetMaxIdleConns(maxConns)
+
+	if err := pool.Ping(); err != nil {
+		pool.Close()
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	DB = pool
+	ReadDB = pool
+	dialect = Postgres
+
+	if err := Migrate(); err != nil {
+		return err
+	}
+
+	slog.Info("Database initialized successfully")
+	return nil
+}
+
+// postgresBase is the Postgres equivalent of the base schema created by
+// createTables. Timestamps are stored as UTC text, like SQLite's
+// CURRENT_TIMESTAMP, so the API returns them in the same form.
+var postgresBase = `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER NOT NULL
+	);
+	INSERT INTO schema_version (version)
+		SELECT 0 WHERE NOT EXISTS (SELECT 1 FROM schema_version);
+
+	CREATE TABLE IF NOT EXISTS weights (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		date TEXT NOT NULL UNIQUE,
+		pounds DOUBLE PRECISION NOT NULL,
+		created_at TEXT NOT NULL DEFAULT ` + pgNow + `,
+		updated_at TEXT NOT NULL DEFAULT ` + pgNow + `
+	);
+	CREATE INDEX IF NOT EXISTS idx_weights_date ON weights(date DESC);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT,
+		updated_at TEXT NOT NULL DEFAULT ` + pgNow + `
+	);
+	INSERT INTO settings (key, value) VALUES ('goal_weight', NULL) ON CONFLICT DO NOTHING;`
+
+// postgresMigrations mirror migrations entry for entry, so a schema version
+// means the same on both databases. Append to both lists together.
+var postgresMigrations = []string{
+	// 1: row versions for optimistic concurrency (ETags)
+	`ALTER TABLE weights ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE settings ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+
+	// 2: soft deletion; the date must only be unique among active rows
+	`ALTER TABLE weights DROP CONSTRAINT weights_date_key;
+	ALTER TABLE weights ADD COLUMN deleted_at TEXT;
+	CREATE UNIQUE INDEX idx_weights_date_active ON weights(date) WHERE deleted_at IS NULL;
+	CREATE INDEX idx_weights_deleted_at ON weights(deleted_at) WHERE deleted_at IS NOT NULL;`,
+
+	// 3: append-only audit log of every change to weights and the goal
+	`CREATE TABLE audit_log (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		entity TEXT NOT NULL,
+		entity_id BIGINT,
+		action TEXT NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		actor TEXT NOT NULL,
+		source_ip TEXT,
+		user_agent TEXT,
+		created_at TEXT NOT NULL DEFAULT ` + pgNow + `
+	);
+	CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id, id DESC);
+	CREATE FUNCTION audit_log_append_only() RETURNS trigger LANGUAGE plpgsql AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_log is append-only';
+	END
+	$$;
+	CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+		FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();`,
+
+	// 4: free-text notes and many-to-many tags on weight entries; citext
+	// gives tag names SQLite's NOCASE uniqueness and ordering
+	`ALTER TABLE weights ADD COLUMN note TEXT;
+	CREATE EXTENSION IF NOT EXISTS citext;
+	CREATE TABLE tags (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		name CITEXT NOT NULL UNIQUE,
+		exclude_from_stats BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TEXT NOT NULL DEFAULT ` + pgNow + `
+	);
+	CREATE TABLE weight_tags (
+		weight_id BIGINT NOT NULL REFERENCES weights(id) ON DELETE CASCADE,
+		tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (weight_id, tag_id)
+	);
+	CREATE INDEX idx_weight_tags_tag ON weight_tags(tag_id);`,
+
+	// 5: outgoing webhook subscriptions and their delivery log
+	`CREATE TABLE webhooks (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TEXT NOT NULL DEFAULT ` + pgNow + `,
+		updated_at TEXT NOT NULL DEFAULT ` + pgNow + `
+	);
+	CREATE TABLE webhook_deliveries (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event TEXT NOT NULL,
+		event_id TEXT,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_status INTEGER,
+		error TEXT,
+		created_at TEXT NOT NULL DEFAULT ` + pgNow + `,
+		updated_at TEXT NOT NULL DEFAULT ` + pgNow + `
+	);
+	CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id DESC);
+	CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(status) WHERE status = 'pending';`,
+
+	// 6: offline sync; every change to a weight entry takes the next value
+	// of a global change sequence, hard deletions leave a tombstone, and
+	// each field records when it last changed for conflict resolution. The
+	// sequence is a locked row rather than a SEQUENCE so that writers take
+	// values in commit order.
+	`CREATE TABLE sync_state (seq BIGINT NOT NULL);
+	INSERT INTO sync_state (seq) VALUES (0);
+	ALTER TABLE weights
+		ADD COLUMN uuid TEXT NOT NULL DEFAULT gen_random_uuid()::text,
+		ADD COLUMN change_seq BIGINT NOT NULL DEFAULT 0,
+		ADD COLUMN date_changed_at TEXT,
+		ADD COLUMN pounds_changed_at TEXT,
+		ADD COLUMN note_changed_at TEXT,
+		ADD COLUMN tags_changed_at TEXT,
+		ADD COLUMN deleted_changed_at TEXT;
+	CREATE UNIQUE INDEX idx_weights_uuid ON weights(uuid);
+	CREATE INDEX idx_weights_change_seq ON weights(change_seq);
+	CREATE TABLE weight_tombstones (
+		uuid TEXT PRIMARY KEY,
+		change_seq BIGINT NOT NULL
+	);
+	CREATE INDEX idx_weight_tombstones_change_seq ON weight_tombstones(change_seq);
+	CREATE FUNCTION weights_sync() RETURNS trigger LANGUAGE plpgsql AS $$
+	DECLARE
+		next_seq BIGINT;
+	BEGIN
+		UPDATE sync_state SET seq = seq + 1 RETURNING seq INTO next_seq;
+		NEW.change_seq := next_seq;
+		RETURN NEW;
+	END
+	$$;
+	CREATE TRIGGER weights_sync BEFORE INSERT OR UPDATE ON weights
+		FOR EACH ROW EXECUTE FUNCTION weights_sync();
+	CREATE FUNCTION weights_tombstone() RETURNS trigger LANGUAGE plpgsql AS $$
+	BEGIN
+		UPDATE sync_state SET seq = seq + 1;
+		INSERT INTO weight_tombstones (uuid, change_seq) SELECT OLD.uuid, seq FROM sync_state;
+		RETURN OLD;
+	END
+	$$;
+	CREATE TRIGGER weights_tombstone AFTER DELETE ON weights
+		FOR EACH ROW EXECUTE FUNCTION weights_tombstone();`,
+}
+
+// migrationLockID keys the advisory lock held while migrating, so replicas
+// starting together don't apply the same migration twice
+const migrationLockID = 7_277_355_010
+
+// migratePostgres creates the base schema and applies pending migrations
+// while holding an advisory lock
+func migratePostgres() error {
+	ctx := context.Background()
+
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(?)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to lock schema: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock(?)", migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, postgresBase); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, "SELECT version FROM schema_version").Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := current; i < len(postgresMigrations); i++ {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, postgresMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migrations: migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE schema_version SET version = ?", i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migrations: migration %d: %w", i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to apply migrations: migration %d: %w", i+1, err)
+		}
+
+		slog.Info("Applied database migration", "version", i+1)
+	}
+
+	return nil
+}
+
+// redactURL hides the password in a database URL for logging
+func redactURL(databaseURL string) string {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM weights WHERE id = ? AND version = ?", "SELECT * FROM weights WHERE id = $1 AND version = $2"},
+		{"UPDATE tags SET name = '?' WHERE id = ?", "UPDATE tags SET name = '?' WHERE id = $1"},
+		{"SELECT 'it''s ?', ?", "SELECT 'it''s ?', $1"},
+		{`SELECT "odd?name" FROM t WHERE a = ?`, `SELECT "odd?name" FROM t WHERE a = $1`},
+		{"SELECT ? -- why?\nFROM t WHERE b = ?", "SELECT $1 -- why?\nFROM t WHERE b = $2"},
+		{"DO $$ BEGIN PERFORM '?'; END $$; SELECT ?", "DO $$ BEGIN PERFORM '?'; END $$; SELECT $1"},
+	}
+
+	for _, tt := range tests {
+		if got := rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPostgresMigrationsMirrorSQLite(t *testing.T) {
+	if len(postgresMigrations) != len(migrations) {
+		t.Errorf("Expected %d Postgres migrations to match SQLite, got %d", len(migrations), len(postgresMigrations))
+	}
+}
+
+// openTestPostgres initializes an emptied PostgreSQL database named by
+// TEST_DATABASE_URL, skipping the test when it isn't set
+func openTestPostgres(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	conn, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+		t.Fatalf("Failed to reset test database: %v", err)
+	}
+
+	if err := InitPostgres(databaseURL, 4); err != nil {
+		t.Fatalf("InitPostgres failed: %v", err)
+	}
+	t.Cleanup(func() {
+		CloseDB()
+		dialect = SQLite
+	})
+}
+
+func TestInitPostgres(t *testing.T) {
+	openTestPostgres(t)
+
+	version, err := SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion failed: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Expected schema version %d, got %d", LatestSchemaVersion(), version)
+	}
+
+	// Migrating again is a no-op
+	if err := Migrate(); err != nil {
+		t.Errorf("Second Migrate failed: %v", err)
+	}
+
+	var count int
+	DB.QueryRow("SELECT COUNT(*) FROM settings WHERE key = 'goal_weight'").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 goal_weight setting, got %d", count)
+	}
+}
+
+func TestPostgres_Constraints(t *testing.T) {
+	openTestPostgres(t)
+
+	if _, err := DB.Exec("INSERT INTO weights (date, pounds) VALUES (?, ?)", "2026-01-01", 170.0); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	_, err := DB.Exec("INSERT INTO weights (date, pounds) VALUES (?, ?)", "2026-01-01", 169.0)
+	if !errors.Is(WeightError(err), ErrDuplicateDate) {
+		t.Errorf("Expected duplicate date to be ErrDuplicateDate, got %v", err)
+	}
+
+	// A duplicate uuid is not a duplicate date
+	DB.Exec("UPDATE weights SET uuid = 'a'")
+	_, err = DB.Exec("INSERT INTO weights (uuid, date, pounds) VALUES ('a', '2026-01-02', 169.0)")
+	if !IsUniqueViolation(err) || errors.Is(WeightError(err), ErrDuplicateDate) {
+		t.Errorf("Expected a uuid violation not to be a duplicate date, got %v", err)
+	}
+
+	// Trashed entries don't hold on to their date
+	DB.Exec("UPDATE weights SET deleted_at = '2020-01-01 00:00:00'")
+	if _, err := DB.Exec("INSERT INTO weights (date, pounds) VALUES (?, ?)", "2026-01-01", 169.0); err != nil {
+		t.Errorf("Expected insert over a trashed date to succeed, got %v", err)
+	}
+
+	// Tag names are unique regardless of case
+	DB.Exec("INSERT INTO tags (name) VALUES ('Travel')")
+	_, err = DB.Exec("INSERT INTO tags (name) VALUES ('travel')")
+	if !IsUniqueViolation(err) {
+		t.Errorf("Expected case-insensitive tag uniqueness, got %v", err)
+	}
+
+	DB.Exec("INSERT INTO audit_log (entity, action, actor) VALUES ('goal', 'update', 'test')")
+	if _, err := DB.Exec("DELETE FROM audit_log"); err == nil {
+		t.Error("Expected audit_log to reject deletes")
+	}
+
+	purged, err := PurgeTrash(time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeTrash failed: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged entry, got %d", purged)
+	}
+}
@@ -24,3 +24,139 @@ CREATE TABLE IF NOT EXISTS settings (
 
 -- Initialize goal_weight setting
 INSERT OR IGNORE INTO settings (key, value) VALUES ('goal_weight', NULL);
+
+-- Migrations
+-- Applied in order by db.Migrate on top of the base schema above; the
+-- current schema version is stored in PRAGMA user_version.
+
+-- 1: Row versions for optimistic concurrency (ETags)
+ALTER TABLE weights ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE settings ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- 2: Soft deletion. The weights table is rebuilt without the UNIQUE
+-- constraint on date, which is replaced by a partial index over active rows.
+CREATE TABLE weights_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date TEXT NOT NULL,
+    pounds REAL NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TEXT
+);
+INSERT INTO weights_new (id, date, pounds, created_at, updated_at, version)
+    SELECT id, date, pounds, created_at, updated_at, version FROM weights;
+DROP TABLE weights;
+ALTER TABLE weights_new RENAME TO weights;
+CREATE INDEX idx_weights_date ON weights(date DESC);
+CREATE UNIQUE INDEX idx_weights_date_active ON weights(date) WHERE deleted_at IS NULL;
+CREATE INDEX idx_weights_deleted_at ON weights(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- 3: Append-only audit log of every change to weights and the goal
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity TEXT NOT NULL,          -- 'weight' or 'goal'
+    entity_id INTEGER,             -- weights.id, NULL for the goal
+    action TEXT NOT NULL,          -- create, update, delete, restore, purge
+    old_value TEXT,                -- JSON snapshot before the change
+    new_value TEXT,                -- JSON snapshot after the change
+    actor TEXT NOT NULL,           -- X-Actor header, 'anonymous' or 'system'
+    source_ip TEXT,
+    user_agent TEXT,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id, id DESC);
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+-- 4: Free-text notes and many-to-many tags on weight entries
+ALTER TABLE weights ADD COLUMN note TEXT;
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    exclude_from_stats INTEGER NOT NULL DEFAULT 0,  -- leave tagged entries out of stats/trends
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE weight_tags (
+    weight_id INTEGER NOT NULL REFERENCES weights(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (weight_id, tag_id)
+);
+CREATE INDEX idx_weight_tags_tag ON weight_tags(tag_id);
+
+-- 5: Outgoing webhook subscriptions and their delivery log
+CREATE TABLE webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    events TEXT NOT NULL,            -- comma-separated event types
+    secret TEXT NOT NULL,            -- HMAC-SHA256 signing key, never returned by the API
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    event_id TEXT,                   -- ID of the change event, NULL for tests
+    payload TEXT NOT NULL,           -- JSON body sent on every attempt
+    status TEXT NOT NULL DEFAULT 'pending',  -- pending, succeeded or failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,         -- HTTP status of the last attempt
+    error TEXT,                      -- why the last attempt failed
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id DESC);
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(status) WHERE status = 'pending';
+
+-- 6: Offline sync; every change to a weight entry takes the next value of a
+-- global change sequence, hard deletions leave a tombstone, and each field
+-- records when it last changed for last-writer-wins conflict resolution
+CREATE TABLE sync_state (seq INTEGER NOT NULL);  -- single row, the latest change sequence value
+INSERT INTO sync_state (seq) VALUES (0);
+ALTER TABLE weights ADD COLUMN uuid TEXT;        -- client-generated or random, stable across devices
+ALTER TABLE weights ADD COLUMN change_seq INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE weights ADD COLUMN date_changed_at TEXT;     -- RFC 3339; NULL means updated_at
+ALTER TABLE weights ADD COLUMN pounds_changed_at TEXT;
+ALTER TABLE weights ADD COLUMN note_changed_at TEXT;
+ALTER TABLE weights ADD COLUMN tags_changed_at TEXT;
+ALTER TABLE weights ADD COLUMN deleted_changed_at TEXT;
+UPDATE weights SET uuid = lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+    substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) ||
+    substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)));
+CREATE UNIQUE INDEX idx_weights_uuid ON weights(uuid);
+CREATE INDEX idx_weights_change_seq ON weights(change_seq);
+CREATE TABLE weight_tombstones (
+    uuid TEXT PRIMARY KEY,           -- a permanently deleted weight entry
+    change_seq INTEGER NOT NULL
+);
+CREATE INDEX idx_weight_tombstones_change_seq ON weight_tombstones(change_seq);
+-- Triggers number every insert and update, fill in missing UUIDs and
+-- record tombstones
+CREATE TRIGGER weights_sync_insert AFTER INSERT ON weights
+BEGIN
+    UPDATE sync_state SET seq = seq + 1;
+    -- COALESCE with a random (version 4) UUID
+    UPDATE weights SET change_seq = (SELECT seq FROM sync_state), uuid = COALESCE(NEW.uuid,
+        lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+        substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) ||
+        substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))))
+        WHERE id = NEW.id;
+END;
+CREATE TRIGGER weights_sync_update AFTER UPDATE ON weights WHEN NEW.change_seq = OLD.change_seq
+BEGIN
+    UPDATE sync_state SET seq = seq + 1;
+    UPDATE weights SET change_seq = (SELECT seq FROM sync_state) WHERE id = NEW.id;
+END;
+CREATE TRIGGER weights_sync_delete AFTER DELETE ON weights
+BEGIN
+    UPDATE sync_state SET seq = seq + 1;
+    INSERT INTO weight_tombstones (uuid, change_seq) VALUES (OLD.uuid, (SELECT seq FROM sync_state));
+END;
@@ -0,0 +1,208 @@
+// Package events is an in-process publish/subscribe hub for change
+// notifications. It keeps a short history so that a subscriber that
+// reconnects can resume where it left off.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types published for changes
+const (
+	WeightCreated  = "weight.created"
+	WeightUpdated  = "weight.updated"
+	WeightDeleted  = "weight.deleted"
+	WeightRestored = "weight.restored"
+	GoalUpdated    = "goal.updated"
+	// GoalReached is published when a new latest weight entry reaches the
+	// goal from the other side of it
+	GoalReached = "goal.reached"
+
+	// Reset tells a resuming subscriber that it missed events, because they
+	// are no longer in the history or were published before a restart, and
+	// should reload everything
+	Reset = "reset"
+)
+
+var (
+	// ErrSlow ends a subscription whose buffer filled up; the subscriber can
+	// resume from its last event
+	ErrSlow = errors.New("subscriber too slow")
+	// ErrClosed ends every subscription when the broker is closed
+	ErrClosed = errors.New("broker closed")
+)
+
+// Event is a published change. ID is unique for the life of the process and
+// orders events; Data is JSON.
+type Event struct {
+	ID   string
+	Type string
+	Data json.RawMessage
+}
+
+// Broker fans events out to subscribers
+type Broker struct {
+	// epoch distinguishes this process's event IDs from a previous one's
+	epoch string
+	// bufferSize is how many undelivered events a subscriber may have
+	// before it is dropped as slow
+	bufferSize int
+
+	mu          sync.Mutex
+	seq         uint64
+	history     []Event
+	historySize int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBroker returns a broker keeping the last historySize events for
+// resuming subscribers, each of which may fall bufferSize events behind
+func NewBroker(historySize, bufferSize int) *Broker {
+	return &Broker{
+		epoch:       strconv.FormatInt(time.Now().UnixMilli(), 36),
+		bufferSize:  bufferSize,
+		historySize: historySize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives events on C until it is closed, by the subscriber
+// or by the broker
+type Subscription struct {
+	// C delivers events in order; it is closed when the subscription ends
+	C <-chan Event
+
+	ch     chan Event
+	broker *Broker
+	err    error
+}
+
+// Err returns why the broker ended the subscription, once C is closed
+func (s *Subscription) Err() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s, nil)
+}
+
+// Publish sends an event with data encoded as JSON to every subscriber,
+// dropping those that have fallen too far behind
+func (b *Broker) Publish(eventType string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode event", "type", eventType, "error", err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	event := Event{ID: b.id(b.seq), Type: eventType, Data: encoded}
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for s := range b.subscribers {
+		select {
+		case s.ch <- event:
+		default:
+			b.remove(s, ErrSlow)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events published from now on
+func (b *Broker) Subscribe() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.add()
+}
+
+// Resume subscribes a subscriber whose last event was lastID. It returns
+// the events published since, or a single Reset event when some of them are
+// no longer known.
+func (b *Broker) Resume(lastID string) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.add()
+	if seq, ok := b.parseID(lastID); ok && seq <= b.seq {
+		// The history holds events seq+1 onwards only if the oldest kept
+		// event is no later than that
+		oldest := b.seq + 1 - uint64(len(b.history))
+		if seq+1 >= oldest {
+			missed := make([]Event, len(b.history)-int(seq+1-oldest))
+			copy(missed, b.history[seq+1-oldest:])
+			return s, missed
+		}
+	}
+	return s, []Event{{ID: b.id(b.seq), Type: Reset, Data: json.RawMessage("{}")}}
+}
+
+// Close ends every subscription and stops accepting new ones
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s, ErrClosed)
+	}
+}
+
+// add registers a subscription; a closed broker's ends immediately
+func (b *Broker) add() *Subscription {
+	ch := make(chan Event, b.bufferSize)
+	s := &Subscription{C: ch, ch: ch, broker: b}
+	if b.closed {
+		s.err = ErrClosed
+		close(ch)
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// remove ends a subscription if it is still registered
+func (b *Broker) remove(s *Subscription, err error) {
+	if _, ok := b.subscribers[s]; !ok {
+		return
+	}
+	delete(b.subscribers, s)
+	s.err = err
+	close(s.ch)
+}
+
+// id formats an event ID as epoch-sequence
+func (b *Broker) id(seq uint64) string {
+	return fmt.Sprintf("%s-%d", b.epoch, seq)
+}
+
+// parseID returns the sequence number of an ID issued by this broker
+func (b *Broker) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
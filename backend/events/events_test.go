@@ -0,0 +1,149 @@
+package events
+
+import (
+	"errors"
+	"testing"
+)
+
+// receive returns the subscription's pending events
+func receive(s *Subscription) []Event {
+	var received []Event
+	for {
+		select {
+		case event, ok := <-s.C:
+			if !ok {
+				return received
+			}
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+// types lists the events' types
+func types(events []Event) []string {
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = event.Type
+	}
+	return names
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	b := NewBroker(10, 10)
+	b.Publish(WeightCreated, map[string]int{"id": 1})
+
+	first, second := b.Subscribe(), b.Subscribe()
+	defer first.Close()
+	b.Publish(WeightUpdated, map[string]int{"id": 1})
+	b.Publish(WeightDeleted, map[string]int{"id": 1})
+
+	for _, s := range []*Subscription{first, second} {
+		received := receive(s)
+		if len(received) != 2 || received[0].Type != WeightUpdated || received[1].Type != WeightDeleted {
+			t.Fatalf("Expected the two later events in order, got %v", types(received))
+		}
+		if string(received[0].Data) != `{"id":1}` {
+			t.Errorf("Unexpected data %s", received[0].Data)
+		}
+		if received[0].ID >= received[1].ID {
+			t.Errorf("Expected increasing IDs, got %s then %s", received[0].ID, received[1].ID)
+		}
+	}
+
+	// A closed subscription receives nothing more
+	second.Close()
+	b.Publish(GoalUpdated, nil)
+	if _, ok := <-second.C; ok {
+		t.Error("Expected a closed channel")
+	}
+	if err := second.Err(); err != nil {
+		t.Errorf("Expected no error for a subscriber's own close, got %v", err)
+	}
+}
+
+func TestResume(t *testing.T) {
+	tests := []struct {
+		name   string
+		lastID func(published []Event) string
+		want   []string
+	}{
+		{"in history", func(p []Event) string { return p[2].ID }, []string{WeightCreated, WeightCreated}},
+		{"up to date", func(p []Event) string { return p[4].ID }, []string{}},
+		{"oldest kept", func(p []Event) string { return p[1].ID }, []string{WeightCreated, WeightCreated, WeightCreated}},
+		{"too old", func(p []Event) string { return p[0].ID }, []string{Reset}},
+		{"other process", func([]Event) string { return "abc-3" }, []string{Reset}},
+		{"malformed", func([]Event) string { return "3" }, []string{Reset}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The history keeps the last 3 of 5 events
+			b := NewBroker(3, 10)
+			s := b.Subscribe()
+			for i := 0; i < 5; i++ {
+				b.Publish(WeightCreated, i)
+			}
+			published := receive(s)
+			s.Close()
+
+			s, missed := b.Resume(tt.lastID(published))
+			defer s.Close()
+			if got := types(missed); len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			if len(missed) > 0 && missed[len(missed)-1].ID != published[4].ID {
+				t.Errorf("Expected to end at the latest ID %s, got %s", published[4].ID, missed[len(missed)-1].ID)
+			}
+
+			// Later events follow on the subscription
+			b.Publish(GoalUpdated, nil)
+			if received := receive(s); len(received) != 1 || received[0].Type != GoalUpdated {
+				t.Errorf("Expected the next event, got %v", types(received))
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	b := NewBroker(10, 2)
+	slow, fast := b.Subscribe(), b.Subscribe()
+	defer fast.Close()
+
+	for i := 0; i < 3; i++ {
+		b.Publish(WeightCreated, i)
+		receive(fast)
+	}
+
+	if received := receive(slow); len(received) != 2 {
+		t.Errorf("Expected the 2 buffered events, got %d", len(received))
+	}
+	if _, ok := <-slow.C; ok {
+		t.Error("Expected the slow subscription to be closed")
+	}
+	if !errors.Is(slow.Err(), ErrSlow) {
+		t.Errorf("Expected ErrSlow, got %v", slow.Err())
+	}
+
+	// The fast subscriber is unaffected
+	b.Publish(WeightCreated, 3)
+	if received := receive(fast); len(received) != 1 {
+		t.Errorf("Expected the fast subscriber to keep receiving, got %d", len(received))
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := NewBroker(10, 10)
+	s := b.Subscribe()
+	b.Close()
+
+	if _, ok := <-s.C; ok || !errors.Is(s.Err(), ErrClosed) {
+		t.Errorf("Expected the subscription to end with ErrClosed, got %v", s.Err())
+	}
+
+	late := b.Subscribe()
+	if _, ok := <-late.C; ok || !errors.Is(late.Err(), ErrClosed) {
+		t.Errorf("Expected a closed subscription after Close, got %v", late.Err())
+	}
+	b.Publish(WeightCreated, 1)
+}
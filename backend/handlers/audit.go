@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// changeSource identifies who made a change, for the audit log
+type changeSource struct {
+	actor     string
+	ip        string
+	userAgent string
+}
+
+// sourceOf returns the source of the changes made by a request. The actor
+// is taken from the X-Actor request header.
+func sourceOf(c *gin.Context) changeSource {
+	actor := c.GetHeader("X-Actor")
+	if actor == "" {
+		actor = "anonymous"
+	}
+	return changeSource{actor: actor, ip: c.ClientIP(), userAgent: c.Request.UserAgent()}
+}
+
+// recordAudit appends an entry to the audit log within tx. entityID 0
+// records no id (the goal). Either value may be nil, for creations and
+// deletions.
+func recordAudit(ctx context.Context, tx *sql.Tx, source changeSource, entity string, entityID int, action string, oldValue, newValue interface{}) error {
+	oldJSON, err := auditValue(oldValue)
+	if err != nil {
+		return err
+	}
+	newJSON, err := auditValue(newValue)
+	if err != nil {
+		return err
+	}
+
+	var id interface{}
+	if entityID != 0 {
+		id = entityID
+	}
+
+	query := `INSERT INTO audit_log (entity, entity_id, action, old_value, new_value, actor, source_ip, user_agent)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, entity, id, action, oldJSON, newJSON, source.actor, source.ip, source.userAgent)
+	return err
+}
+
+// auditValue serializes a recorded value, keeping nil as SQL NULL
+func auditValue(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// GetHistory retrieves the global audit log, optionally filtered by entity
+// and action
+func GetHistory(c *gin.Context) {
+	where := " WHERE 1 = 1"
+	args := []interface{}{}
+
+	if entity := c.Query("entity"); entity != "" {
+		where += " AND entity = ?"
+		args = append(args, entity)
+	}
+	if action := c.Query("action"); action != "" {
+		where += " AND action = ?"
+		args = append(args, action)
+	}
+
+	respondWithHistory(c, where, args)
+}
+
+// GetWeightHistory retrieves the audit log of a single weight entry,
+// including after it has been deleted
+func GetWeightHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortInvalidID(c, err, "Invalid weight ID")
+		return
+	}
+
+	respondWithHistory(c, " WHERE entity = 'weight' AND entity_id = ?", []interface{}{id})
+}
+
+// GetGoalHistory retrieves the audit log of the goal weight
+func GetGoalHistory(c *gin.Context) {
+	respondWithHistory(c, " WHERE entity = 'goal'", []interface{}{})
+}
+
+// respondWithHistory writes the page of audit entries selected by where and
+// the limit/offset query parameters
+func respondWithHistory(c *gin.Context, where string, args []interface{}) {
+	ctx := c.Request.Context()
+
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	var total int
+	if err := db.ReadDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		abortWithError(c, err, "Failed to retrieve history")
+		return
+	}
+
+	query := `SELECT id, entity, entity_id, action, old_value, new_value, actor, source_ip, user_agent, created_at
+	          FROM audit_log` + where + " ORDER BY id DESC LIMIT ? OFFSET ?"
+	rows, err := db.ReadDB.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve history")
+		return
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var entityID sql.NullInt64
+		var oldValue, newValue sql.NullString
+
+		err := rows.Scan(&e.ID, &e.Entity, &entityID, &e.Action, &oldValue, &newValue,
+			&e.Actor, &e.SourceIP, &e.UserAgent, &e.CreatedAt)
+		if err != nil {
+			abortWithError(c, err, "Failed to scan history entry")
+			return
+		}
+
+		if entityID.Valid {
+			id := int(entityID.Int64)
+			e.EntityID = &id
+		}
+		if oldValue.Valid {
+			e.OldValue = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			e.NewValue = json.RawMessage(newValue.String)
+		}
+		entries = append(entries, e)
+	}
+
+	c.JSON(http.StatusOK, models.AuditLogResponse{
+		Entries: entries,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	})
+}
+
+// parsePage reads the limit and offset query parameters. It aborts with a
+// 400 and returns false when either is invalid.
+func parsePage(c *gin.Context) (int, int, bool) {
+	limit, offset := defaultHistoryLimit, 0
+
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxHistoryLimit {
+			abortWithFields(c, invalid(err), "Invalid request", []models.FieldError{{
+				Field: "limit", Constraint: "range", Param: "1-" + strconv.Itoa(maxHistoryLimit),
+				Message: "must be an integer between 1 and " + strconv.Itoa(maxHistoryLimit),
+			}})
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+
+	if value := c.Query("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			abortWithFields(c, invalid(err), "Invalid request", []models.FieldError{{
+				Field: "offset", Constraint: "min", Param: "0", Message: "must be a non-negative integer",
+			}})
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+
+	return limit, offset, true
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+)
+
+func setupAuditRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.POST("/weights", CreateWeight)
+	router.PATCH("/weights/:id", PatchWeight)
+	router.DELETE("/weights/:id", DeleteWeight)
+	router.PUT("/goal", UpdateGoal)
+	router.GET("/history", GetHistory)
+	router.GET("/weights/:id/history", GetWeightHistory)
+	router.GET("/goal/history", GetGoalHistory)
+	return router
+}
+
+func getHistory(t *testing.T, router *gin.Engine, path string) models.AuditLogResponse {
+	req, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 from %s, got %d. Body: %s", path, w.Code, w.Body.String())
+	}
+
+	var response models.AuditLogResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response
+}
+
+func TestWeightHistory_RecordsChanges(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupAuditRouter()
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/weights", `{"date": "2026-01-15", "pounds": 170.5}`},
+		{"PATCH", "/weights/1", `{"pounds": 169.0}`},
+		{"DELETE", "/weights/1", ""},
+	}
+	for _, r := range requests {
+		req, _ := http.NewRequest(r.method, r.path, bytes.NewBufferString(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "phone")
+		req.Header.Set("User-Agent", "test-agent")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code >= 300 {
+			t.Fatalf("%s %s failed with status %d", r.method, r.path, w.Code)
+		}
+	}
+
+	history := getHistory(t, router, "/weights/1/history")
+
+	if history.Total != 3 {
+		t.Fatalf("Expected 3 history entries, got %d", history.Total)
+	}
+
+	// Newest first
+	actions := []string{"delete", "update", "create"}
+	for i, e := range history.Entries {
+		if e.Action != actions[i] {
+			t.Errorf("Expected action %s at position %d, got %s", actions[i], i, e.Action)
+		}
+		if e.Actor != "phone" {
+			t.Errorf("Expected actor phone, got %s", e.Actor)
+		}
+		if e.UserAgent == nil || *e.UserAgent != "test-agent" {
+			t.Errorf("Expected user agent to be recorded, got %v", e.UserAgent)
+		}
+	}
+
+	var oldValue, newValue models.Weight
+	json.Unmarshal(history.Entries[1].OldValue, &oldValue)
+	json.Unmarshal(history.Entries[1].NewValue, &newValue)
+	if oldValue.Pounds != 170.5 || newValue.Pounds != 169.0 {
+		t.Errorf("Expected update from 170.5 to 169.0, got %f to %f", oldValue.Pounds, newValue.Pounds)
+	}
+
+	if string(history.Entries[0].NewValue) != "null" {
+		t.Errorf("Expected null new value for delete, got %s", history.Entries[0].NewValue)
+	}
+}
+
+func TestGoalHistory_RecordsChanges(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupAuditRouter()
+
+	req, _ := http.NewRequest("PUT", "/goal", bytes.NewBufferString(`{"pounds": 154.0}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	history := getHistory(t, router, "/goal/history")
+
+	if history.Total != 1 {
+		t.Fatalf("Expected 1 history entry, got %d", history.Total)
+	}
+	if history.Entries[0].EntityID != nil {
+		t.Errorf("Expected no entity id for goal, got %d", *history.Entries[0].EntityID)
+	}
+	if history.Entries[0].Actor != "anonymous" {
+		t.Errorf("Expected anonymous actor, got %s", history.Entries[0].Actor)
+	}
+}
+
+func TestHistory_Pagination(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupAuditRouter()
+
+	for _, date := range []string{"2026-01-01", "2026-01-02", "2026-01-03"} {
+		req, _ := http.NewRequest("POST", "/weights", bytes.NewBufferString(`{"date": "`+date+`", "pounds": 170}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	history := getHistory(t, router, "/history?entity=weight&limit=2&offset=1")
+
+	if history.Total != 3 {
+		t.Errorf("Expected total 3, got %d", history.Total)
+	}
+	if len(history.Entries) != 2 {
+		t.Fatalf("Expected 2 entries on page, got %d", len(history.Entries))
+	}
+	if *history.Entries[0].EntityID != 2 {
+		t.Errorf("Expected second newest entry first, got entity %d", *history.Entries[0].EntityID)
+	}
+
+	req, _ := http.NewRequest("GET", "/history?limit=0", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid limit, got %d", w.Code)
+	}
+}
+
+func TestAuditLog_AppendOnly(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO audit_log (entity, action, actor) VALUES ('goal', 'update', 'test')")
+
+	if _, err := db.DB.Exec("UPDATE audit_log SET actor = 'someone else'"); err == nil {
+		t.Error("Expected update of audit log to be rejected")
+	}
+	if _, err := db.DB.Exec("DELETE FROM audit_log"); err == nil {
+		t.Error("Expected delete from audit log to be rejected")
+	}
+}
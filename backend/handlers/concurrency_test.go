@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+)
+
+// TestConcurrentCreateAndList hammers CreateWeight and GetWeights in
+// parallel against a file database in WAL mode. No request may fail with
+// "database is locked" or any other server error.
+func TestConcurrentCreateAndList(t *testing.T) {
+	if err := db.InitDB(filepath.Join(t.TempDir(), "wt.db"), db.DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer db.CloseDB()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/weights", GetWeights)
+	router.POST("/weights", CreateWeight)
+
+	const writers, readers, perWorker = 8, 8, 25
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	failures := make(chan string, (writers+readers)*perWorker)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				date := start.AddDate(0, 0, w*perWorker+i).Format("2006-01-02")
+				body := fmt.Sprintf(`{"date": %q, "pounds": 170.5, "tags": ["t%d"]}`, date, w)
+				req, _ := http.NewRequest("POST", "/weights", bytes.NewBufferString(body))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != http.StatusCreated {
+					failures <- fmt.Sprintf("create %s: %d %s", date, rec.Code, rec.Body.String())
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				req, _ := http.NewRequest("GET", "/weights", nil)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					failures <- fmt.Sprintf("list: %d %s", rec.Code, rec.Body.String())
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(failures)
+
+	for failure := range failures {
+		t.Error(failure)
+	}
+
+	var count int
+	db.DB.QueryRow("SELECT COUNT(*) FROM weights").Scan(&count)
+	if count != writers*perWorker {
+		t.Errorf("Expected %d weight entries, got %d", writers*perWorker, count)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package handlers
+
+import "errors"
+
+// freeDiskSpace is not implemented on this platform
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/logging"
+	"github.com/sddev/weight-tracker/models"
+	"github.com/sddev/weight-tracker/tracing"
+)
+
+// problemContentType is the RFC 9457 media type for problem documents
+const problemContentType = "application/problem+json"
+
+// domainStatuses maps the db package's domain errors to response statuses,
+// error codes and the message used when neither the error nor the handler
+// gives one
+var domainStatuses = []struct {
+	err     error
+	status  int
+	code    string
+	message string
+}{
+	{db.ErrValidation, http.StatusBadRequest, models.ErrorCodeValidationFailed, "Invalid request"},
+	{db.ErrNotFound, http.StatusNotFound, models.ErrorCodeNotFound, "Resource not found"},
+	{db.ErrDuplicateDate, http.StatusConflict, models.ErrorCodeDuplicateDate, "Weight entry already exists for this date"},
+	{db.ErrDuplicateTag, http.StatusConflict, models.ErrorCodeDuplicateTag, "Tag already exists"},
+	{db.ErrModified, http.StatusPreconditionFailed, models.ErrorCodePreconditionFailed, "Resource has been modified"},
+}
+
+// errorMeta carries the handler's side of an error response alongside an
+// error attached with abort. An empty code means the domain error's code.
+type errorMeta struct {
+	code    string
+	message string
+	fields  []models.FieldError
+	details map[string]interface{}
+}
+
+// ErrorHandler writes the error response for the last error a handler
+// attached with abort, unless a response was already written. Domain errors
+// map to 400, 404, 409 or 412; anything else is a 500 whose cause only
+// reaches the logs.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Written() || len(c.Errors) == 0 {
+			return
+		}
+
+		last := c.Errors.Last()
+		meta, _ := last.Meta.(errorMeta)
+		status, response := errorResponse(last.Err, meta)
+		respondError(c, status, response)
+	}
+}
+
+// errorResponse builds the response status and body for err with the
+// handler's meta
+func errorResponse(err error, meta errorMeta) (int, models.ErrorResponse) {
+	status := http.StatusInternalServerError
+	response := models.ErrorResponse{
+		Code:  models.ErrorCodeInternal,
+		Error: meta.message,
+	}
+
+	for _, domain := range domainStatuses {
+		if !errors.Is(err, domain.err) {
+			continue
+		}
+		status = domain.status
+		response.Code = domain.code
+		if meta.code != "" {
+			response.Code = meta.code
+		}
+		response.Fields = meta.fields
+		response.Details = meta.details
+
+		// The storage layer's message is more specific than the handler's
+		var domainErr *db.Error
+		if errors.As(err, &domainErr) && domainErr.Message != "" {
+			response.Error = domainErr.Message
+		} else if response.Error == "" {
+			response.Error = domain.message
+		}
+		break
+	}
+
+	if response.Error == "" {
+		response.Error = "Internal server error"
+	}
+	return status, response
+}
+
+// NoRoute responds to requests for unknown routes
+func NoRoute(c *gin.Context) {
+	respondError(c, http.StatusNotFound, models.ErrorResponse{
+		Code:  models.ErrorCodeRouteNotFound,
+		Error: "Route not found",
+	})
+}
+
+// Recover responds to a panic in a handler with an internal error, for use
+// with gin.CustomRecovery
+func Recover(c *gin.Context, recovered interface{}) {
+	c.Error(fmt.Errorf("panic: %v", recovered))
+	respondError(c, http.StatusInternalServerError, models.ErrorResponse{
+		Code:  models.ErrorCodeInternal,
+		Error: "Internal server error",
+	})
+	c.Abort()
+}
+
+// abort stops the handler chain and leaves err, with meta, for ErrorHandler
+func abort(c *gin.Context, err error, meta errorMeta) {
+	c.Error(err).SetMeta(meta)
+	c.Abort()
+}
+
+// abortWithError aborts with err. message is the response text unless err
+// is a domain error with its own.
+func abortWithError(c *gin.Context, err error, message string) {
+	abort(c, err, errorMeta{message: message})
+}
+
+// abortWithFields aborts with a validation error listing the invalid fields
+func abortWithFields(c *gin.Context, err error, message string, fields []models.FieldError) {
+	abort(c, err, errorMeta{message: message, fields: fields})
+}
+
+// abortWithDetails aborts with err and extra details for the client
+func abortWithDetails(c *gin.Context, err error, message string, details map[string]interface{}) {
+	abort(c, err, errorMeta{message: message, details: details})
+}
+
+// operationError is an error returned by an operation shared by the HTTP
+// handlers and live sync, with the meta a handler would attach with abort
+type operationError struct {
+	err  error
+	meta errorMeta
+}
+
+func (e *operationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *operationError) Unwrap() error {
+	return e.err
+}
+
+// failed returns err from an operation, with message as the response text
+// unless err is a domain error with its own
+func failed(err error, message string) error {
+	return &operationError{err: err, meta: errorMeta{message: message}}
+}
+
+// failedFields returns a validation error listing the invalid fields
+func failedFields(err error, message string, fields []models.FieldError) error {
+	return &operationError{err: err, meta: errorMeta{message: message, fields: fields}}
+}
+
+// operationResponse builds the response status and body for an error
+// returned by an operation
+func operationResponse(err error) (int, models.ErrorResponse) {
+	var opErr *operationError
+	if errors.As(err, &opErr) {
+		return errorResponse(opErr.err, opErr.meta)
+	}
+	return errorResponse(err, errorMeta{})
+}
+
+// abortWithOperation aborts with an error returned by an operation
+func abortWithOperation(c *gin.Context, err error) {
+	var opErr *operationError
+	if errors.As(err, &opErr) {
+		abort(c, opErr.err, opErr.meta)
+		return
+	}
+	abort(c, err, errorMeta{})
+}
+
+// abortInvalidID aborts with an invalid_id error for an unparseable path ID
+func abortInvalidID(c *gin.Context, err error, message string) {
+	abort(c, invalid(err), errorMeta{code: models.ErrorCodeInvalidID, message: message})
+}
+
+// invalid marks cause, from parsing or validating a request, as a validation
+// error
+func invalid(cause error) error {
+	return &db.Error{Kind: db.ErrValidation, Err: cause}
+}
+
+// respondError writes an error response tagged with the request and trace
+// IDs, as a problem document if the client accepts one
+func respondError(c *gin.Context, status int, response models.ErrorResponse) {
+	response.RequestID = logging.GetRequestID(c)
+	response.TraceID = tracing.TraceID(c.Request.Context())
+
+	if !acceptsProblem(c.GetHeader("Accept")) {
+		c.JSON(status, response)
+		return
+	}
+
+	// gin keeps a Content-Type that is already set
+	c.Header("Content-Type", problemContentType)
+	c.JSON(status, models.ProblemDetails{
+		Type:      "urn:weight-tracker:problem:" + response.Code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    response.Error,
+		Instance:  c.Request.URL.Path,
+		Code:      response.Code,
+		Fields:    response.Fields,
+		Details:   response.Details,
+		RequestID: response.RequestID,
+		TraceID:   response.TraceID,
+	})
+}
+
+// acceptsProblem reports whether an Accept header lists the problem+json
+// media type
+func acceptsProblem(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == problemContentType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/logging"
+	"github.com/sddev/weight-tracker/models"
+	"github.com/sddev/weight-tracker/tracing"
+)
+
+func TestErrorResponse_IncludesRequestID(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(logging.RequestID())
+	router.Use(ErrorHandler())
+	router.GET("/weights/:id", GetWeight)
+
+	req, _ := http.NewRequest("GET", "/weights/999", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-404")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+
+	var response models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.RequestID != "req-404" {
+		t.Errorf("Expected request_id req-404, got %q", response.RequestID)
+	}
+}
+
+func TestInternalError_AttachesCause(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	if _, err := db.DB.Exec("DROP TABLE weight_tags"); err != nil {
+		t.Fatalf("Failed to drop table: %v", err)
+	}
+
+	var errs []string
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		errs = c.Errors.Errors()
+	})
+	router.Use(ErrorHandler())
+	router.GET("/weights", GetWeights)
+
+	req, _ := http.NewRequest("GET", "/weights", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+
+	var response models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Error != "Failed to retrieve weights" {
+		t.Errorf("Expected public error message only, got %q", response.Error)
+	}
+	if len(errs) != 1 || errs[0] == "" {
+		t.Errorf("Expected the SQL error attached to the context, got %v", errs)
+	}
+}
+
+func TestErrorResponse_IncludesTraceID(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	if _, err := tracing.Setup(context.Background(), "none", nil); err != nil {
+		t.Fatalf("tracing.Setup failed: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.Middleware())
+	router.Use(ErrorHandler())
+	router.GET("/weights/:id", GetWeight)
+
+	req, _ := http.NewRequest("GET", "/weights/999", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected trace_id from traceparent, got %q", response.TraceID)
+	}
+}
+
+func TestErrorHandler_MapsDomainErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		message string
+		status  int
+		code    string
+		want    string
+	}{
+		{"validation", invalid(nil), "Invalid weight ID", http.StatusBadRequest, models.ErrorCodeValidationFailed, "Invalid weight ID"},
+		{"not found", db.ErrNotFound, "", http.StatusNotFound, models.ErrorCodeNotFound, "Resource not found"},
+		{"storage message wins", db.WeightError(sql.ErrNoRows), "Failed to retrieve weight entry", http.StatusNotFound, models.ErrorCodeNotFound, "Weight entry not found"},
+		{"duplicate date", db.ErrDuplicateDate, "", http.StatusConflict, models.ErrorCodeDuplicateDate, "Weight entry already exists for this date"},
+		{"unrelated storage error", db.TagError(errors.New("disk full")), "Failed to create tag", http.StatusInternalServerError, models.ErrorCodeInternal, "Failed to create tag"},
+		{"modified", db.ErrModified, "", http.StatusPreconditionFailed, models.ErrorCodePreconditionFailed, "Resource has been modified"},
+		{"internal", errors.New("disk I/O error"), "", http.StatusInternalServerError, models.ErrorCodeInternal, "Internal server error"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		router := gin.New()
+		router.Use(ErrorHandler())
+		router.GET("/", func(c *gin.Context) {
+			abortWithError(c, tt.err, tt.message)
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, w.Code)
+		}
+		var response models.ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if response.Error != tt.want {
+			t.Errorf("%s: expected error %q, got %q", tt.name, tt.want, response.Error)
+		}
+		if response.Code != tt.code {
+			t.Errorf("%s: expected code %q, got %q", tt.name, tt.code, response.Code)
+		}
+	}
+}
+
+func TestErrorHandler_KeepsWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/", func(c *gin.Context) {
+		c.Error(errors.New("logged only"))
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"ok":true}` {
+		t.Errorf("Expected the handler's response to be kept, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdateWeight_DuplicateDate(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-02', 169.0)")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.PUT("/weights/:id", UpdateWeight)
+
+	body := strings.NewReader(`{"date": "2026-01-01", "pounds": 168.0}`)
+	req, _ := http.NewRequest("PUT", "/weights/2", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestErrorHandler_ProblemJSON(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/weights/:id", GetWeight)
+
+	req, _ := http.NewRequest("GET", "/weights/999", nil)
+	req.Header.Set("Accept", "application/json;q=0.5, application/problem+json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+		t.Errorf("Expected problem+json content type, got %q", ct)
+	}
+
+	var problem models.ProblemDetails
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	if problem.Type != "urn:weight-tracker:problem:not_found" || problem.Code != models.ErrorCodeNotFound {
+		t.Errorf("Expected not_found problem type and code, got %q %q", problem.Type, problem.Code)
+	}
+	if problem.Title != "Not Found" || problem.Status != http.StatusNotFound {
+		t.Errorf("Expected title Not Found and status 404, got %q %d", problem.Title, problem.Status)
+	}
+	if problem.Detail != "Weight entry not found" || problem.Instance != "/weights/999" {
+		t.Errorf("Expected detail and instance, got %q %q", problem.Detail, problem.Instance)
+	}
+}
+
+func TestErrorHandler_PlainJSONByDefault(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/", func(c *gin.Context) {
+		abortWithError(c, db.ErrNotFound, "")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected JSON content type, got %q", ct)
+	}
+}
+
+func TestNoRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.NoRoute(NoRoute)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/nope", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+	var response models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Code != models.ErrorCodeRouteNotFound {
+		t.Errorf("Expected code route_not_found, got %q", response.Code)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(), gin.CustomRecoveryWithWriter(io.Discard, Recover))
+	router.GET("/", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+	var response models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Code != models.ErrorCodeInternal || response.Error != "Internal server error" {
+		t.Errorf("Expected internal_error response, got %+v", response)
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+)
+
+// versionETag formats the strong entity tag for a row version
+func versionETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// contentETag derives a weak entity tag from a serialized response body
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag
+// using the weak comparison of RFC 9110: the weak prefix is ignored on both
+// sides. The header may be "*" or a comma-separated list of entity tags.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatchesStrong reports whether an If-Match header value matches etag
+// using the strong comparison of RFC 9110: a weak entity tag on either side
+// never matches.
+func etagMatchesStrong(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces the If-Match precondition against the current
+// version of a resource. It aborts with a 412 and returns false when the
+// header is present and does not match; a request without If-Match passes.
+func checkIfMatch(c *gin.Context, version int) bool {
+	if err := ifMatchError(c.GetHeader("If-Match"), version); err != nil {
+		abortWithOperation(c, err)
+		return false
+	}
+	return true
+}
+
+// ifMatchError returns the 412 error for an If-Match precondition that does
+// not match version, or nil when it matches or is empty
+func ifMatchError(ifMatch string, version int) error {
+	if ifMatch == "" || etagMatchesStrong(ifMatch, versionETag(version)) {
+		return nil
+	}
+	return &operationError{err: db.ErrModified, meta: errorMeta{
+		message: "Resource has been modified",
+		details: map[string]interface{}{"etag": versionETag(version)},
+	}}
+}
+
+// respondWithETag writes body as JSON with the given entity tag, or an empty
+// 304 Not Modified when the request's If-None-Match already matches it. An
+// empty etag is derived from the serialized body.
+func respondWithETag(c *gin.Context, status int, etag string, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		abortWithError(c, err, "Failed to encode response")
+		return
+	}
+
+	if etag == "" {
+		etag = contentETag(data)
+	}
+	c.Header("ETag", etag)
+
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(status, "application/json; charset=utf-8", data)
+}
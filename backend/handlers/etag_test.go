@@ -0,0 +1,48 @@
+package handlers
+
+import "testing"
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		want   bool
+	}{
+		{`"3"`, `"3"`, true},
+		{`"2"`, `"3"`, false},
+		{`*`, `"3"`, true},
+		{`"1", "3"`, `"3"`, true},
+		{`W/"abc"`, `W/"abc"`, true},
+		{`"abc"`, `W/"abc"`, true},
+		{`W/"abd"`, `W/"abc"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, tt.etag); got != tt.want {
+			t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+		}
+	}
+}
+
+func TestEtagMatchesStrong(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		want   bool
+	}{
+		{`"3"`, `"3"`, true},
+		{`"2"`, `"3"`, false},
+		{`*`, `"3"`, true},
+		{`"1", "3"`, `"3"`, true},
+		{`W/"3"`, `"3"`, false},
+		{`W/"1", W/"3"`, `"3"`, false},
+		{`"abc"`, `W/"abc"`, false},
+		{`W/"abc"`, `W/"abc"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatchesStrong(tt.header, tt.etag); got != tt.want {
+			t.Errorf("etagMatchesStrong(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+		}
+	}
+}
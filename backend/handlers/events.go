@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/events"
+)
+
+const (
+	// eventHistory is how many recent events a reconnecting client can
+	// resume from
+	eventHistory = 1000
+	// eventBuffer is how far a client may fall behind before it is
+	// disconnected, to resume when it reconnects
+	eventBuffer = 64
+	// eventRetry is how long browsers wait before reconnecting
+	eventRetry = 3 * time.Second
+)
+
+// heartbeatInterval is how often an idle stream sends a comment, so that
+// proxies don't close it and dead clients are noticed
+var heartbeatInterval = 15 * time.Second
+
+// broker carries change events from the handlers to event streams
+var broker = events.NewBroker(eventHistory, eventBuffer)
+
+// sessions counts the event streams and live sync sessions still running.
+// The server stops tracking a WebSocket once it is hijacked, so shutdown
+// waits on this instead. idle is closed whenever active drops to zero.
+var sessions struct {
+	sync.Mutex
+	active int
+	idle   chan struct{}
+}
+
+// startSession counts an event stream or live sync session as running
+func startSession() {
+	sessions.Lock()
+	defer sessions.Unlock()
+	if sessions.active == 0 {
+		sessions.idle = make(chan struct{})
+	}
+	sessions.active++
+}
+
+// endSession counts an event stream or live sync session as finished
+func endSession() {
+	sessions.Lock()
+	defer sessions.Unlock()
+	sessions.active--
+	if sessions.active == 0 {
+		close(sessions.idle)
+	}
+}
+
+// CloseEvents ends every event stream and live sync session, so that a
+// graceful shutdown need not wait for clients to disconnect
+func CloseEvents() {
+	broker.Close()
+	closeLiveSessions()
+}
+
+// WaitEvents waits for the event streams and live sync sessions ended by
+// CloseEvents to finish, or for ctx to be done
+func WaitEvents(ctx context.Context) error {
+	sessions.Lock()
+	if sessions.active == 0 {
+		sessions.Unlock()
+		return nil
+	}
+	idle := sessions.idle
+	sessions.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StreamEvents streams weight and goal changes as server-sent events. A
+// client reconnecting with Last-Event-ID first receives the events it
+// missed, or a reset event if they are no longer known.
+func StreamEvents(c *gin.Context) {
+	startSession()
+	defer endSession()
+
+	var sub *events.Subscription
+	var missed []events.Event
+	if lastID := c.GetHeader("Last-Event-ID"); lastID != "" {
+		sub, missed = broker.Resume(lastID)
+	} else {
+		sub = broker.Subscribe()
+	}
+	defer sub.Close()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// Stop nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", eventRetry.Milliseconds())
+	for _, event := range missed {
+		writeEvent(c.Writer, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			// The broker dropped a slow client or is shutting down; the
+			// client reconnects and resumes
+			if !ok {
+				return
+			}
+			writeEvent(c.Writer, event)
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeEvent writes an event in the text/event-stream format
+func writeEvent(w io.Writer, event events.Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is a parsed server-sent event; comments have only a comment
+type sseEvent struct {
+	id, event, data, comment string
+}
+
+// eventStream reads server-sent events from a response
+type eventStream struct {
+	events chan sseEvent
+	cancel context.CancelFunc
+}
+
+// openEventStream connects to the server's event stream with alternating
+// header names and values
+func openEventStream(t *testing.T, server *httptest.Server, headers ...string) *eventStream {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/v1/events", nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		cancel()
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	stream := &eventStream{events: make(chan sseEvent, 16), cancel: cancel}
+	go func() {
+		defer resp.Body.Close()
+		defer close(stream.events)
+		var event sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				if event != (sseEvent{}) {
+					stream.events <- event
+				}
+				event = sseEvent{}
+				continue
+			}
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "":
+				event.comment = value
+			case "id":
+				event.id = value
+			case "event":
+				event.event = value
+			case "data":
+				event.data = value
+			}
+		}
+	}()
+	t.Cleanup(cancel)
+	return stream
+}
+
+// next returns the next event, skipping heartbeats unless wanted
+func (s *eventStream) next(t *testing.T, heartbeats bool) sseEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				t.Fatal("Event stream ended")
+			}
+			if event.comment != "" && !heartbeats {
+				continue
+			}
+			return event
+		case <-timeout:
+			t.Fatal("Timed out waiting for an event")
+		}
+	}
+}
+
+func newEventServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(apiRouter())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// send makes an API request and expects status
+func send(t *testing.T, server *httptest.Server, method, path, body string, status int) {
+	t.Helper()
+	req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s: expected status %d, got %d", method, path, status, resp.StatusCode)
+	}
+}
+
+func TestStreamEvents_Changes(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	server := newEventServer(t)
+
+	stream := openEventStream(t, server)
+
+	send(t, server, "POST", "/api/v1/weights", `{"date": "2026-01-01", "pounds": 170}`, http.StatusCreated)
+	send(t, server, "PATCH", "/api/v1/weights/1", `{"pounds": 169}`, http.StatusOK)
+	send(t, server, "DELETE", "/api/v1/weights/1", "", http.StatusNoContent)
+	send(t, server, "POST", "/api/v1/weights/1/restore", "", http.StatusOK)
+	send(t, server, "PUT", "/api/v1/goal", `{"pounds": 160}`, http.StatusOK)
+	// Failed changes publish nothing
+	send(t, server, "POST", "/api/v1/weights", `{"date": "2026-01-01", "pounds": 170}`, http.StatusConflict)
+
+	want := []struct{ event, data string }{
+		{"weight.created", `"pounds":170`},
+		{"weight.updated", `"pounds":169`},
+		{"weight.deleted", `{"id":1}`},
+		{"weight.restored", `"date":"2026-01-01"`},
+		{"goal.updated", `"pounds":160`},
+	}
+	var ids []string
+	for _, w := range want {
+		event := stream.next(t, false)
+		if event.event != w.event || !strings.Contains(event.data, w.data) {
+			t.Fatalf("Expected %s with %s, got %+v", w.event, w.data, event)
+		}
+		ids = append(ids, event.id)
+	}
+
+	// A client reconnecting with the ID of the last event it saw first gets
+	// the ones it missed
+	resumed := openEventStream(t, server, "Last-Event-ID", ids[2])
+	for _, w := range want[3:] {
+		if event := resumed.next(t, false); event.event != w.event {
+			t.Errorf("Expected replayed %s, got %+v", w.event, event)
+		}
+	}
+
+	// An unknown ID gets a reset instead
+	reset := openEventStream(t, server, "Last-Event-ID", "unknown-1")
+	if event := reset.next(t, false); event.event != "reset" || event.id != ids[4] {
+		t.Errorf("Expected a reset at %s, got %+v", ids[4], event)
+	}
+}
+
+func TestStreamEvents_Heartbeat(t *testing.T) {
+	defer func(interval time.Duration) { heartbeatInterval = interval }(heartbeatInterval)
+	heartbeatInterval = 10 * time.Millisecond
+	server := newEventServer(t)
+
+	stream := openEventStream(t, server)
+	if event := stream.next(t, true); event.comment != "heartbeat" {
+		t.Errorf("Expected a heartbeat, got %+v", event)
+	}
+}
@@ -1,29 +1,29 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/events"
 	"github.com/sddev/weight-tracker/models"
 )
 
-// GetGoal retrieves the goal weight setting
-func GetGoal(c *gin.Context) {
-	var goal models.Goal
+// goalSelect selects every column scanned by scanGoal
+const goalSelect = "SELECT value, updated_at, version FROM settings WHERE key = 'goal_weight'"
+
+// scanGoal scans a row selected with goalSelect into goal and returns the
+// row version
+func scanGoal(row rowScanner, goal *models.Goal) (int, error) {
 	var value sql.NullString
 	var updatedAt sql.NullString
+	var version int
 
-	query := "SELECT value, updated_at FROM settings WHERE key = 'goal_weight'"
-	err := db.DB.QueryRow(query).Scan(&value, &updatedAt)
-
-	if err != nil && err != sql.ErrNoRows {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to retrieve goal weight",
-		})
-		return
+	if err := row.Scan(&value, &updatedAt, &version); err != nil {
+		return 0, err
 	}
 
 	// Parse the value if it's not NULL
@@ -37,55 +37,154 @@ func GetGoal(c *gin.Context) {
 		goal.UpdatedAt = &updatedAt.String
 	}
 
-	c.JSON(http.StatusOK, goal)
+	return version, nil
+}
+
+// GetGoal retrieves the goal weight setting
+func GetGoal(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var goal models.Goal
+	version, err := scanGoal(db.ReadDB.QueryRowContext(ctx, goalSelect), &goal)
+
+	if err != nil && err != sql.ErrNoRows {
+		abortWithError(c, err, "Failed to retrieve goal weight")
+		return
+	}
+
+	respondWithETag(c, http.StatusOK, versionETag(version), goal)
 }
 
 // UpdateGoal updates the goal weight setting
 func UpdateGoal(c *gin.Context) {
 	var input models.GoalInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Details: map[string]interface{}{"validation": err.Error()},
-		})
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	goal, version, err := updateGoal(c.Request.Context(), sourceOf(c), c.GetHeader("If-Match"), input)
+	if err != nil {
+		abortWithOperation(c, err)
 		return
 	}
 
+	c.Header("ETag", versionETag(version))
+	c.JSON(http.StatusOK, goal)
+}
+
+// updateGoal sets the goal weight, provided ifMatch, if not empty, matches
+// its current version, and returns the goal with its new version
+func updateGoal(ctx context.Context, source changeSource, ifMatch string, input models.GoalInput) (models.Goal, int, error) {
+	// The value column is text; bind it as such so every database stores
+	// the same representation
 	var value interface{}
 	if input.Pounds != nil {
-		value = *input.Pounds
+		value = strconv.FormatFloat(*input.Pounds, 'f', -1, 64)
 	} else {
 		value = nil
 	}
 
-	query := `UPDATE settings SET value = ?, updated_at = CURRENT_TIMESTAMP WHERE key = 'goal_weight'`
-	_, err := db.DB.Exec(query, value)
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Goal{}, 0, failed(err, "Failed to update goal weight")
+	}
+	defer tx.Rollback()
 
+	var old models.Goal
+	version, err := scanGoal(tx.QueryRowContext(ctx, goalSelect), &old)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to update goal weight",
-		})
-		return
+		return models.Goal{}, 0, failed(err, "Failed to retrieve goal weight")
+	}
+
+	if err := ifMatchError(ifMatch, version); err != nil {
+		return models.Goal{}, 0, err
+	}
+
+	query := `UPDATE settings SET value = ?, version = version + 1, updated_at = ` + db.Now() + `
+	          WHERE key = 'goal_weight' AND version = ?`
+	result, err := tx.ExecContext(ctx, query, value, version)
+
+	if err != nil {
+		return models.Goal{}, 0, failed(err, "Failed to update goal weight")
+	}
+
+	// Another request updated the goal between reading and writing it
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return models.Goal{}, 0, failed(db.ErrModified, "Resource has been modified")
 	}
 
 	// Retrieve the updated goal
 	var goal models.Goal
-	var valueStr sql.NullString
-	var updatedAt sql.NullString
+	version, err = scanGoal(tx.QueryRowContext(ctx, goalSelect), &goal)
 
-	query = "SELECT value, updated_at FROM settings WHERE key = 'goal_weight'"
-	err = db.DB.QueryRow(query).Scan(&valueStr, &updatedAt)
+	if err != nil {
+		return models.Goal{}, 0, failed(err, "Failed to retrieve updated goal weight")
+	}
 
-	if err == nil {
-		if valueStr.Valid && valueStr.String != "" && valueStr.String != "NULL" {
-			if pounds, err := strconv.ParseFloat(valueStr.String, 64); err == nil {
-				goal.Pounds = &pounds
-			}
-		}
-		if updatedAt.Valid {
-			goal.UpdatedAt = &updatedAt.String
-		}
+	if err := recordAudit(ctx, tx, source, "goal", 0, "update", old, goal); err != nil {
+		return models.Goal{}, 0, failed(err, "Failed to record change")
 	}
 
-	c.JSON(http.StatusOK, goal)
+	if err := tx.Commit(); err != nil {
+		return models.Goal{}, 0, failed(err, "Failed to update goal weight")
+	}
+	broker.Publish(events.GoalUpdated, goal)
+
+	return goal, version, nil
+}
+
+// latestWeight is the weight entry with the latest date
+type latestWeight struct {
+	id     int
+	pounds float64
+}
+
+// selectLatestWeight returns the latest active weight entry within tx, or
+// nil when there is none
+func selectLatestWeight(ctx context.Context, tx *sql.Tx) (*latestWeight, error) {
+	var latest latestWeight
+	err := tx.QueryRowContext(ctx, "SELECT id, pounds FROM weights WHERE deleted_at IS NULL ORDER BY date DESC LIMIT 1").
+		Scan(&latest.id, &latest.pounds)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &latest, nil
+}
+
+// goalReached reports whether a change to the weight entry with the given id
+// reached the goal: the entry is now the latest and is at or past the goal,
+// in the direction of the goal from the first entry, and the latest entry
+// before the change was not. It returns the goal that was reached.
+func goalReached(ctx context.Context, tx *sql.Tx, id int, before *latestWeight) (float64, bool, error) {
+	if before == nil {
+		return 0, false, nil
+	}
+
+	after, err := selectLatestWeight(ctx, tx)
+	if err != nil || after == nil || after.id != id {
+		return 0, false, err
+	}
+
+	var goal models.Goal
+	if _, err := scanGoal(tx.QueryRowContext(ctx, goalSelect), &goal); err != nil || goal.Pounds == nil {
+		return 0, false, err
+	}
+
+	var start float64
+	err = tx.QueryRowContext(ctx, "SELECT pounds FROM weights WHERE deleted_at IS NULL ORDER BY date LIMIT 1").Scan(&start)
+	if err != nil {
+		return 0, false, err
+	}
+
+	target := *goal.Pounds
+	switch {
+	case start > target:
+		return target, before.pounds > target && after.pounds <= target, nil
+	case start < target:
+		return target, before.pounds < target && after.pounds >= target, nil
+	}
+	return target, false, nil
 }
@@ -17,6 +17,7 @@ func TestGetGoal_NoGoalSet(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.GET("/goal", GetGoal)
 
 	req, _ := http.NewRequest("GET", "/goal", nil)
@@ -43,6 +44,7 @@ func TestUpdateGoal_Success(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.PUT("/goal", UpdateGoal)
 
 	pounds := 154.0
@@ -80,6 +82,7 @@ func TestUpdateGoal_ClearGoal(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.PUT("/goal", UpdateGoal)
 
 	// First set a goal
@@ -119,6 +122,7 @@ func TestUpdateGoal_InvalidValue(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.PUT("/goal", UpdateGoal)
 
 	pounds := -10.0
@@ -143,6 +147,7 @@ func TestGetGoal_AfterSet(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.PUT("/goal", UpdateGoal)
 	router.GET("/goal", GetGoal)
 
@@ -181,3 +186,47 @@ func TestGetGoal_AfterSet(t *testing.T) {
 		t.Error("Expected non-nil updated_at")
 	}
 }
+
+func TestUpdateGoal_IfMatchStale(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/goal", GetGoal)
+	router.PUT("/goal", UpdateGoal)
+
+	req1, _ := http.NewRequest("GET", "/goal", nil)
+	w1 := httptest.NewRecorder()
+	router.ServeHTTP(w1, req1)
+
+	etag := w1.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header on goal")
+	}
+
+	pounds := 154.0
+	body, _ := json.Marshal(models.GoalInput{Pounds: &pounds})
+
+	req2, _ := http.NewRequest("PUT", "/goal", bytes.NewBuffer(body))
+	req2.Header.Set("Content-Type", "application/json")
+	req2.Header.Set("If-Match", etag)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
+	if w2.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w2.Code, w2.Body.String())
+	}
+
+	// Replaying with the original ETag must not overwrite the newer value
+	req3, _ := http.NewRequest("PUT", "/goal", bytes.NewBuffer(body))
+	req3.Header.Set("Content-Type", "application/json")
+	req3.Header.Set("If-Match", etag)
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+
+	if w3.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412, got %d", w3.Code)
+	}
+}
@@ -1,20 +1,93 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sddev/weight-tracker/db"
 	"github.com/sddev/weight-tracker/models"
+	"github.com/sddev/weight-tracker/version"
 )
 
-// HealthCheck handles the health check endpoint
+const (
+	// minFreeDiskBytes is the free space on the data volume below which the
+	// disk check warns
+	minFreeDiskBytes = 100 << 20
+
+	// maxBackupAge is the age of the newest backup beyond which the backup
+	// check warns
+	maxBackupAge = 7 * 24 * time.Hour
+)
+
+// checkTimeout bounds each health check, so a locked or unreachable
+// database fails the check instead of hanging the request
+var checkTimeout = 2 * time.Second
+
+// startTime approximates when the server started, for reporting uptime
+var startTime = time.Now()
+
+// backupDir is searched for the newest backup by the verbose health check
+var backupDir string
+
+// SetBackupDir sets the directory holding database backups. When empty the
+// backup check is left out.
+func SetBackupDir(dir string) {
+	backupDir = dir
+}
+
+// checkFunc runs one health check, returning its status ("pass" or "warn")
+// and details; an error fails the check
+type checkFunc func(ctx context.Context) (string, map[string]interface{}, error)
+
+// Livez reports that the process is running and serving requests. It has no
+// dependencies so that a slow database never gets the container restarted.
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, models.HealthResponse{
+		Status:    "alive",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// Readyz reports whether the server can handle traffic: the database must
+// be reachable and fully migrated
+func Readyz(c *gin.Context) {
+	checks, failed, _ := runChecks(c.Request.Context(), map[string]checkFunc{
+		"database": checkDatabase,
+		"schema":   checkSchema,
+	})
+
+	status, code := "ready", http.StatusOK
+	if failed {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, models.HealthResponse{
+		Status:    status,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Checks:    checks,
+	})
+}
+
+// HealthCheck handles the health check endpoint. With verbose=1 it also
+// reports build information, uptime and the result of every storage check;
+// warnings degrade the status without failing the request.
 func HealthCheck(c *gin.Context) {
+	ctx := c.Request.Context()
+
 	dbStatus := "connected"
 
 	// Test database connection
-	if err := db.DB.Ping(); err != nil {
+	pingCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	err := db.DB.PingContext(pingCtx)
+	cancel()
+	if err != nil {
+		c.Error(err)
 		dbStatus = "disconnected"
 		c.JSON(http.StatusInternalServerError, models.HealthResponse{
 			Status:    "unhealthy",
@@ -24,9 +97,213 @@ func HealthCheck(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.HealthResponse{
-		Status:    "healthy",
-		Database:  dbStatus,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	if verbose := c.Query("verbose"); verbose != "1" && verbose != "true" {
+		c.JSON(http.StatusOK, models.HealthResponse{
+			Status:    "healthy",
+			Database:  dbStatus,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	checks, failed, warned := runChecks(ctx, storageChecks(ctx))
+
+	status, code := "healthy", http.StatusOK
+	switch {
+	case failed:
+		status, code = "unhealthy", http.StatusInternalServerError
+	case warned:
+		status = "degraded"
+	}
+
+	c.JSON(code, models.HealthResponse{
+		Status:        status,
+		Database:      dbStatus,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		Version:       version.Version,
+		Commit:        version.Commit,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+		Checks:        checks,
 	})
 }
+
+// runChecks runs each check within checkTimeout, timing it, and reports
+// whether any failed or warned
+func runChecks(ctx context.Context, checks map[string]checkFunc) (map[string]models.HealthCheck, bool, bool) {
+	results := make(map[string]models.HealthCheck, len(checks))
+	failed, warned := false, false
+
+	for name, check := range checks {
+		start := time.Now()
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		status, details, err := check(checkCtx)
+		cancel()
+		result := models.HealthCheck{
+			Status:    status,
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			Details:   details,
+		}
+		if err != nil {
+			result.Status = "fail"
+			result.Error = err.Error()
+		}
+
+		switch result.Status {
+		case "fail":
+			failed = true
+		case "warn":
+			warned = true
+		}
+		results[name] = result
+	}
+
+	return results, failed, warned
+}
+
+// storageChecks returns the checks reported by the verbose health check.
+// The disk and WAL checks are left out for in-memory databases, and the
+// backup check when no backup directory is configured.
+func storageChecks(ctx context.Context) map[string]checkFunc {
+	checks := map[string]checkFunc{
+		"database":      checkDatabase,
+		"schema":        checkSchema,
+		"database_size": checkDatabaseSize,
+	}
+
+	pathCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	path, err := db.FilePathContext(pathCtx)
+	cancel()
+	if err != nil || path != "" {
+		checks["wal"] = func(ctx context.Context) (string, map[string]interface{}, error) {
+			return checkWAL(path, err)
+		}
+		checks["disk"] = func(ctx context.Context) (string, map[string]interface{}, error) {
+			return checkDisk(path, err)
+		}
+	}
+
+	if backupDir != "" {
+		checks["backup"] = checkBackup
+	}
+
+	return checks
+}
+
+func checkDatabase(ctx context.Context) (string, map[string]interface{}, error) {
+	if err := db.DB.PingContext(ctx); err != nil {
+		return "fail", nil, err
+	}
+	return "pass", nil, db.ReadDB.PingContext(ctx)
+}
+
+func checkSchema(ctx context.Context) (string, map[string]interface{}, error) {
+	current, err := db.SchemaVersionContext(ctx)
+	if err != nil {
+		return "fail", nil, err
+	}
+
+	latest := db.LatestSchemaVersion()
+	details := map[string]interface{}{"version": current, "expected": latest}
+	if current != latest {
+		return "fail", details, errors.New("schema version does not match this build")
+	}
+	return "pass", details, nil
+}
+
+func checkDatabaseSize(ctx context.Context) (string, map[string]interface{}, error) {
+	size, err := db.SizeContext(ctx)
+	if err != nil {
+		return "fail", nil, err
+	}
+	return "pass", map[string]interface{}{"bytes": size}, nil
+}
+
+// checkWAL reports the size of the write-ahead log next to the database
+// file at path; a missing log counts as empty
+func checkWAL(path string, pathErr error) (string, map[string]interface{}, error) {
+	if pathErr != nil {
+		return "fail", nil, pathErr
+	}
+
+	var size int64
+	info, err := os.Stat(path + "-wal")
+	switch {
+	case err == nil:
+		size = info.Size()
+	case !errors.Is(err, fs.ErrNotExist):
+		return "fail", nil, err
+	}
+	return "pass", map[string]interface{}{"bytes": size}, nil
+}
+
+// checkDisk reports the free space on the volume holding the database file
+// at path, warning when it runs low
+func checkDisk(path string, pathErr error) (string, map[string]interface{}, error) {
+	if pathErr != nil {
+		return "fail", nil, pathErr
+	}
+
+	dir := filepath.Dir(path)
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return "fail", nil, err
+	}
+
+	details := map[string]interface{}{"path": dir, "free_bytes": free}
+	if free < minFreeDiskBytes {
+		return "warn", details, nil
+	}
+	return "pass", details, nil
+}
+
+// checkBackup reports the age of the newest backup, warning when there is
+// none or it is older than maxBackupAge
+func checkBackup(ctx context.Context) (string, map[string]interface{}, error) {
+	path, modTime, err := latestBackup(backupDir)
+	if err != nil {
+		return "fail", nil, err
+	}
+	if path == "" {
+		return "warn", map[string]interface{}{"dir": backupDir, "message": "no backups found"}, nil
+	}
+
+	age := time.Since(modTime)
+	details := map[string]interface{}{
+		"path":        path,
+		"created_at":  modTime.UTC().Format(time.RFC3339),
+		"age_seconds": int64(age.Seconds()),
+	}
+	if age > maxBackupAge {
+		return "warn", details, nil
+	}
+	return "pass", details, nil
+}
+
+// latestBackup returns the most recently modified *.db file in dir, or ""
+// when there is none. A missing directory has no backups.
+func latestBackup(dir string) (string, time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var latest string
+	var latestTime time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".db" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if info.ModTime().After(latestTime) {
+			latest, latestTime = filepath.Join(dir, entry.Name()), info.ModTime()
+		}
+	}
+
+	return latest, latestTime, nil
+}
@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
 	"github.com/sddev/weight-tracker/models"
 )
 
@@ -74,3 +78,151 @@ func TestHealthCheck_DatabaseDown(t *testing.T) {
 		t.Errorf("Expected database 'disconnected', got '%s'", response.Database)
 	}
 }
+
+func TestLivez(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/livez", Livez)
+
+	req, _ := http.NewRequest("GET", "/livez", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/readyz", Readyz)
+
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	// A database behind this build's schema is not ready
+	if db.CurrentDialect() == db.Postgres {
+		db.DB.Exec("UPDATE schema_version SET version = 1")
+	} else {
+		db.DB.Exec("PRAGMA user_version = 1")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 for outdated schema, got %d", w.Code)
+	}
+
+	var response models.HealthResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Checks["schema"].Status != "fail" || response.Checks["database"].Status != "pass" {
+		t.Errorf("Expected only the schema check to fail, got %+v", response.Checks)
+	}
+}
+
+func TestHealthCheck_Verbose(t *testing.T) {
+	dir := t.TempDir()
+	if err := db.InitDB(filepath.Join(dir, "wt.db"), db.DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer db.CloseDB()
+
+	backups := filepath.Join(dir, "backups")
+	os.Mkdir(backups, 0o755)
+	old := filepath.Join(backups, "weight-tracker-old.db")
+	os.WriteFile(old, []byte("backup"), 0o600)
+	stale := time.Now().Add(-10 * 24 * time.Hour)
+	os.Chtimes(old, stale, stale)
+
+	SetBackupDir(backups)
+	defer SetBackupDir("")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/health", HealthCheck)
+
+	req, _ := http.NewRequest("GET", "/health?verbose=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response models.HealthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	// The stale backup only degrades the status
+	if response.Status != "degraded" {
+		t.Errorf("Expected status 'degraded', got '%s'", response.Status)
+	}
+	if response.Version == "" || response.Commit == "" {
+		t.Errorf("Expected build information, got version %q commit %q", response.Version, response.Commit)
+	}
+
+	for _, name := range []string{"database", "schema", "database_size", "wal", "disk"} {
+		if check, ok := response.Checks[name]; !ok || check.Status != "pass" {
+			t.Errorf("Expected check %s to pass, got %+v", name, check)
+		}
+	}
+	if response.Checks["backup"].Status != "warn" {
+		t.Errorf("Expected backup check to warn, got %+v", response.Checks["backup"])
+	}
+	if response.Checks["schema"].Details["version"] != float64(db.LatestSchemaVersion()) {
+		t.Errorf("Expected schema version %d, got %v", db.LatestSchemaVersion(), response.Checks["schema"].Details["version"])
+	}
+}
+
+func TestReadyz_LockedDatabaseTimesOut(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	if db.CurrentDialect() == db.Postgres {
+		t.Skip("only SQLite writes through a single connection")
+	}
+
+	defer func(timeout time.Duration) { checkTimeout = timeout }(checkTimeout)
+	checkTimeout = 50 * time.Millisecond
+
+	// Hold the only write connection, as a stuck writer would
+	tx, err := db.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/readyz", Readyz)
+	router.GET("/health", HealthCheck)
+
+	for _, path := range []string{"/readyz", "/health?verbose=1"} {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			req, _ := http.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			done <- w
+		}()
+
+		select {
+		case w := <-done:
+			if w.Code != http.StatusServiceUnavailable && w.Code != http.StatusInternalServerError {
+				t.Errorf("%s: expected the check to fail, got %d", path, w.Code)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: expected the check to time out", path)
+		}
+	}
+}
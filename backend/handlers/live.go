@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+	"github.com/sddev/weight-tracker/events"
+	"github.com/sddev/weight-tracker/models"
+)
+
+const (
+	// liveMaxFrame is the largest frame a live sync client may send
+	liveMaxFrame = 64 << 10
+	// liveWriteTimeout is how long a frame may take to send before the
+	// client is considered gone
+	liveWriteTimeout = 10 * time.Second
+)
+
+// upgrader only accepts the origins allowed by originAllowed. LiveSync
+// answers others itself; the check here is a backstop.
+var upgrader = websocket.Upgrader{
+	CheckOrigin: originAllowed,
+}
+
+// allowedOrigins are the origins, besides the server's own, that may open a
+// live sync connection
+var allowedOrigins []string
+
+// SetAllowedOrigins sets the origins that may open a live sync connection:
+// the CORS origins. "*" allows any origin.
+func SetAllowedOrigins(origins []string) {
+	allowedOrigins = origins
+}
+
+// originAllowed reports whether a WebSocket handshake may proceed. Browsers
+// don't apply CORS to WebSockets, so without this check any page the user
+// visits could change their data. Requests without an Origin header don't
+// come from a browser page and are allowed.
+func originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// liveShutdown is closed by CloseEvents to end every live sync session
+	liveShutdown     = make(chan struct{})
+	liveShutdownOnce sync.Once
+)
+
+// liveOperations lists the request types that change data
+var liveOperations = map[string]bool{"create": true, "update": true, "delete": true}
+
+// LiveSync upgrades to a WebSocket over which the client can subscribe to
+// change events and submit changes. Changes are applied by the operations
+// behind the REST handlers, so they are validated and audited exactly as
+// over HTTP, against the client that opened the WebSocket. Like the REST
+// API it serves the one dataset; there are no users to scope sessions to.
+func LiveSync(c *gin.Context) {
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		abortWithError(c, invalid(errors.New("not a websocket handshake")), "Expected a WebSocket upgrade request")
+		return
+	}
+
+	if !originAllowed(c.Request) {
+		respondError(c, http.StatusForbidden, models.ErrorResponse{
+			Code:  models.ErrorCodeOriginNotAllowed,
+			Error: "Origin not allowed",
+		})
+		return
+	}
+
+	startSession()
+	defer endSession()
+
+	// Upgrade replies with an error itself if the handshake is bad
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+
+	s := &liveSession{
+		conn:   conn,
+		ctx:    c.Request.Context(),
+		source: sourceOf(c),
+		send:   make(chan models.LiveFrame, eventBuffer),
+		done:   make(chan struct{}),
+	}
+	s.run()
+}
+
+// liveSession is one live sync connection. The handler goroutine reads
+// frames, a writer goroutine owns writes and each subscription has a
+// goroutine forwarding its events.
+type liveSession struct {
+	conn   *websocket.Conn
+	ctx    context.Context
+	source changeSource
+	send   chan models.LiveFrame
+	done   chan struct{}
+	sub    *events.Subscription
+	wg     sync.WaitGroup
+}
+
+// run serves the session until the client goes or the server shuts down
+func (s *liveSession) run() {
+	s.wg.Add(1)
+	go s.write()
+
+	defer func() {
+		close(s.done)
+		s.unsubscribe()
+		s.conn.Close()
+		s.wg.Wait()
+	}()
+
+	// Pings are sent every heartbeatInterval; a client that misses two is
+	// gone
+	pongWait := 2 * heartbeatInterval
+	s.conn.SetReadLimit(liveMaxFrame)
+	s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		messageType, message, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.TextMessage {
+			s.queue(liveError("", http.StatusBadRequest, models.ErrorCodeMalformedBody, "Frames must be JSON text"))
+			continue
+		}
+
+		var request models.LiveRequest
+		if err := json.Unmarshal(message, &request); err != nil {
+			s.queue(liveError("", http.StatusBadRequest, models.ErrorCodeMalformedBody, "Frame is not valid JSON"))
+			continue
+		}
+		s.handle(request)
+	}
+}
+
+// handle answers one request
+func (s *liveSession) handle(request models.LiveRequest) {
+	switch {
+	case request.Type == "subscribe":
+		s.unsubscribe()
+		// Acknowledge before any event is forwarded
+		s.queue(models.LiveFrame{Type: "ack", ID: request.ID, Status: http.StatusOK})
+		s.subscribe(request.LastEventID)
+	case request.Type == "unsubscribe":
+		s.unsubscribe()
+		s.queue(models.LiveFrame{Type: "ack", ID: request.ID, Status: http.StatusOK})
+	case liveOperations[request.Type]:
+		s.queue(s.apply(request))
+	default:
+		s.queue(liveFieldError(request.ID, models.FieldError{
+			Field: "type", Constraint: "oneof", Param: "subscribe unsubscribe create update delete",
+			Message: "type must be one of subscribe, unsubscribe, create, update, delete",
+		}))
+	}
+}
+
+// unsubscribe stops forwarding change events
+func (s *liveSession) unsubscribe() {
+	if s.sub != nil {
+		s.sub.Close()
+		s.sub = nil
+	}
+}
+
+// subscribe starts forwarding change events, after those missed since
+// lastEventID if given
+func (s *liveSession) subscribe(lastEventID string) {
+	var missed []events.Event
+	if lastEventID != "" {
+		s.sub, missed = broker.Resume(lastEventID)
+	} else {
+		s.sub = broker.Subscribe()
+	}
+
+	s.wg.Add(1)
+	go s.forward(s.sub, missed)
+}
+
+// forward sends a subscription's events to the client
+func (s *liveSession) forward(sub *events.Subscription, missed []events.Event) {
+	defer s.wg.Done()
+
+	for _, event := range missed {
+		if !s.queue(eventFrame(event)) {
+			return
+		}
+	}
+	for event := range sub.C {
+		if !s.queue(eventFrame(event)) {
+			return
+		}
+	}
+
+	// The broker ends subscriptions of slow clients and on shutdown; the
+	// client can reconnect and resume
+	switch sub.Err() {
+	case events.ErrSlow:
+		s.close(websocket.CloseTryAgainLater, "too slow, resume from the last event")
+	case events.ErrClosed:
+		s.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// apply runs a change through the operation shared with the REST handlers
+func (s *liveSession) apply(request models.LiveRequest) models.LiveFrame {
+	var ifMatch string
+	if request.Version > 0 {
+		ifMatch = versionETag(request.Version)
+	}
+
+	var (
+		status = http.StatusOK
+		result interface{}
+		err    error
+	)
+	switch {
+	case request.Entity == "goal" && request.Type == "update":
+		var input models.GoalInput
+		if err = bindBody(request.Data, &input); err == nil {
+			result, _, err = updateGoal(s.ctx, s.source, ifMatch, input)
+		}
+	case request.Entity == "goal":
+		return liveFieldError(request.ID, models.FieldError{
+			Field: "type", Constraint: "oneof", Param: "update", Message: "the goal can only be updated",
+		})
+	case request.Entity != "" && request.Entity != "weight":
+		return liveFieldError(request.ID, models.FieldError{
+			Field: "entity", Constraint: "oneof", Param: "weight goal", Message: "entity must be weight or goal",
+		})
+	case request.Type == "create":
+		var input models.WeightInput
+		if err = bindBody(request.Data, &input); err == nil {
+			status = http.StatusCreated
+			result, err = createWeight(s.ctx, s.source, input)
+		}
+	case request.EntityID <= 0:
+		return liveFieldError(request.ID, models.FieldError{
+			Field: "entity_id", Constraint: "required", Message: "entity_id is required",
+		})
+	case request.Type == "update":
+		var patch map[string]json.RawMessage
+		if err = binding.JSON.BindBody(request.Data, &patch); err != nil {
+			err = malformedBody(err)
+		} else {
+			result, err = patchWeight(s.ctx, s.source, request.EntityID, ifMatch, patch)
+		}
+	default:
+		status = http.StatusNoContent
+		err = deleteWeight(s.ctx, s.source, request.EntityID, ifMatch)
+	}
+
+	if err != nil {
+		status, response := operationResponse(err)
+		if status >= http.StatusInternalServerError {
+			slog.Error("Live sync operation failed", "type", request.Type, "entity", request.Entity, "error", err)
+		}
+		return models.LiveFrame{Type: "error", ID: request.ID, Status: status, Error: &response}
+	}
+
+	frame := models.LiveFrame{Type: "ack", ID: request.ID, Status: status}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return liveError(request.ID, http.StatusInternalServerError, models.ErrorCodeInternal, "Internal server error")
+		}
+		frame.Data = data
+	}
+	return frame
+}
+
+// queue hands a frame to the writer, reporting false once the session has
+// ended
+func (s *liveSession) queue(frame models.LiveFrame) bool {
+	select {
+	case s.send <- frame:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// write sends queued frames and pings until the session ends
+func (s *liveSession) write() {
+	defer s.wg.Done()
+
+	ping := time.NewTicker(heartbeatInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case frame := <-s.send:
+			s.conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			err = s.conn.WriteJSON(frame)
+		case <-ping.C:
+			err = s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteTimeout))
+		case <-liveShutdown:
+			s.close(websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-s.done:
+			return
+		}
+		if err != nil {
+			// Unblock the reader; the session then ends
+			s.conn.Close()
+			return
+		}
+	}
+}
+
+// close sends a close frame and closes the connection, which ends the
+// session
+func (s *liveSession) close(code int, reason string) {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(liveWriteTimeout))
+	s.conn.Close()
+}
+
+// closeLiveSessions ends every live sync session
+func closeLiveSessions() {
+	liveShutdownOnce.Do(func() { close(liveShutdown) })
+}
+
+// eventFrame wraps a change event for live sync clients
+func eventFrame(event events.Event) models.LiveFrame {
+	return models.LiveFrame{Type: "event", Event: event.Type, EventID: event.ID, Data: event.Data}
+}
+
+// liveError builds an error frame
+func liveError(id string, status int, code, message string) models.LiveFrame {
+	return models.LiveFrame{Type: "error", ID: id, Status: status, Error: &models.ErrorResponse{Code: code, Error: message}}
+}
+
+// liveFieldError builds an error frame for an invalid request field
+func liveFieldError(id string, field models.FieldError) models.LiveFrame {
+	frame := liveError(id, http.StatusBadRequest, models.ErrorCodeValidationFailed, "Invalid request")
+	frame.Error.Fields = []models.FieldError{field}
+	return frame
+}
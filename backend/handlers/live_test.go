@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// dialLive opens a live sync connection to the server
+func dialLive(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"X-Actor": {"phone"}})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// liveRequest sends a frame and returns the next one that isn't an event
+func liveRequest(t *testing.T, conn *websocket.Conn, frame string) models.LiveFrame {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+		t.Fatalf("Failed to send %s: %v", frame, err)
+	}
+	for {
+		reply := nextFrame(t, conn)
+		if reply.Type != "event" {
+			return reply
+		}
+	}
+}
+
+// nextFrame reads a frame from the server
+func nextFrame(t *testing.T, conn *websocket.Conn) models.LiveFrame {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var frame models.LiveFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("Failed to read a frame: %v", err)
+	}
+	return frame
+}
+
+func TestLiveSync_Operations(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	server := newEventServer(t)
+	conn := dialLive(t, server)
+
+	ack := liveRequest(t, conn, `{"type": "create", "id": "1", "entity": "weight", "data": {"date": "2026-01-01", "pounds": 170}}`)
+	if ack.Type != "ack" || ack.ID != "1" || ack.Status != http.StatusCreated {
+		t.Fatalf("Expected a 201 ack, got %+v", ack)
+	}
+	var weight models.Weight
+	if err := json.Unmarshal(ack.Data, &weight); err != nil || weight.ID != 1 || weight.Pounds != 170 {
+		t.Fatalf("Expected the created weight, got %s", ack.Data)
+	}
+
+	tests := []struct {
+		name   string
+		frame  string
+		typ    string
+		status int
+		code   string
+	}{
+		{"update", `{"type": "update", "id": "2", "entity": "weight", "entity_id": 1, "version": 1, "data": {"pounds": 169}}`, "ack", http.StatusOK, ""},
+		{"stale version", `{"type": "update", "id": "3", "entity": "weight", "entity_id": 1, "version": 1, "data": {"pounds": 168}}`, "error", http.StatusPreconditionFailed, models.ErrorCodePreconditionFailed},
+		{"invalid data", `{"type": "update", "id": "4", "entity": "weight", "entity_id": 1, "data": {"pounds": 0}}`, "error", http.StatusBadRequest, models.ErrorCodeValidationFailed},
+		{"duplicate date", `{"type": "create", "id": "5", "data": {"date": "2026-01-01", "pounds": 170}}`, "error", http.StatusConflict, models.ErrorCodeDuplicateDate},
+		{"malformed data", `{"type": "create", "id": "6", "data": "170"}`, "error", http.StatusBadRequest, models.ErrorCodeMalformedBody},
+		{"missing entity id", `{"type": "delete", "id": "7", "entity": "weight"}`, "error", http.StatusBadRequest, models.ErrorCodeValidationFailed},
+		{"unknown entity", `{"type": "create", "id": "8", "entity": "tag"}`, "error", http.StatusBadRequest, models.ErrorCodeValidationFailed},
+		{"unknown type", `{"type": "upsert", "id": "9"}`, "error", http.StatusBadRequest, models.ErrorCodeValidationFailed},
+		{"goal", `{"type": "update", "id": "10", "entity": "goal", "data": {"pounds": 160}}`, "ack", http.StatusOK, ""},
+		{"delete", `{"type": "delete", "id": "11", "entity": "weight", "entity_id": 1}`, "ack", http.StatusNoContent, ""},
+		{"not found", `{"type": "delete", "id": "12", "entity": "weight", "entity_id": 1}`, "error", http.StatusNotFound, models.ErrorCodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := liveRequest(t, conn, tt.frame)
+			if reply.Type != tt.typ || reply.Status != tt.status {
+				t.Fatalf("Expected %s %d, got %+v", tt.typ, tt.status, reply)
+			}
+			if tt.code != "" && (reply.Error == nil || reply.Error.Code != tt.code) {
+				t.Errorf("Expected code %s, got %+v", tt.code, reply.Error)
+			}
+		})
+	}
+
+	// Changes are audited as made by the connection's client
+	var actor string
+	if err := db.DB.QueryRow("SELECT actor FROM audit_log WHERE entity = 'weight' AND action = 'update'").Scan(&actor); err != nil || actor != "phone" {
+		t.Errorf("Expected the update to be recorded against phone, got %q (%v)", actor, err)
+	}
+}
+
+func TestLiveSync_MalformedFrames(t *testing.T) {
+	server := newEventServer(t)
+	conn := dialLive(t, server)
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if reply := nextFrame(t, conn); reply.Type != "error" || reply.Error.Code != models.ErrorCodeMalformedBody {
+		t.Errorf("Expected a malformed_body error for a binary frame, got %+v", reply)
+	}
+	if reply := liveRequest(t, conn, `{"type": `); reply.Type != "error" || reply.Error.Code != models.ErrorCodeMalformedBody {
+		t.Errorf("Expected a malformed_body error for invalid JSON, got %+v", reply)
+	}
+
+	// The connection stays usable
+	if reply := liveRequest(t, conn, `{"type": "unsubscribe", "id": "a"}`); reply.Type != "ack" || reply.ID != "a" {
+		t.Errorf("Expected an ack, got %+v", reply)
+	}
+}
+
+func TestLiveSync_Subscribe(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	server := newEventServer(t)
+
+	subscriber := dialLive(t, server)
+	if ack := liveRequest(t, subscriber, `{"type": "subscribe", "id": "s"}`); ack.Type != "ack" || ack.ID != "s" {
+		t.Fatalf("Expected a subscribe ack, got %+v", ack)
+	}
+
+	// Changes over REST and over another connection are both delivered
+	send(t, server, "POST", "/api/v1/weights", `{"date": "2026-01-01", "pounds": 170}`, http.StatusCreated)
+	writer := dialLive(t, server)
+	liveRequest(t, writer, `{"type": "update", "entity_id": 1, "data": {"pounds": 169}}`)
+	send(t, server, "PUT", "/api/v1/goal", `{"pounds": 160}`, http.StatusOK)
+
+	want := []struct{ event, data string }{
+		{"weight.created", `"pounds":170`},
+		{"weight.updated", `"pounds":169`},
+		{"goal.updated", `"pounds":160`},
+	}
+	var ids []string
+	for _, w := range want {
+		frame := nextFrame(t, subscriber)
+		if frame.Type != "event" || frame.Event != w.event || !strings.Contains(string(frame.Data), w.data) {
+			t.Fatalf("Expected %s with %s, got %+v", w.event, w.data, frame)
+		}
+		ids = append(ids, frame.EventID)
+	}
+
+	// Resubscribing from an earlier event replays the later ones
+	resumed := dialLive(t, server)
+	if ack := liveRequest(t, resumed, `{"type": "subscribe", "last_event_id": "`+ids[0]+`"}`); ack.Type != "ack" {
+		t.Fatalf("Expected a subscribe ack, got %+v", ack)
+	}
+	for _, w := range want[1:] {
+		if frame := nextFrame(t, resumed); frame.Event != w.event {
+			t.Errorf("Expected replayed %s, got %+v", w.event, frame)
+		}
+	}
+
+	// After unsubscribing no more events arrive
+	liveRequest(t, subscriber, `{"type": "unsubscribe"}`)
+	send(t, server, "DELETE", "/api/v1/weights/1", "", http.StatusNoContent)
+	subscriber.WriteMessage(websocket.TextMessage, []byte(`{"type": "unsubscribe", "id": "u"}`))
+	if reply := nextFrame(t, subscriber); reply.Type != "ack" || reply.ID != "u" {
+		t.Errorf("Expected only the ack, got %+v", reply)
+	}
+}
+
+func TestLiveSync_Ping(t *testing.T) {
+	defer func(interval time.Duration) { heartbeatInterval = interval }(heartbeatInterval)
+	heartbeatInterval = 10 * time.Millisecond
+	server := newEventServer(t)
+	conn := dialLive(t, server)
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go conn.ReadMessage()
+
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a ping")
+	}
+}
+
+func TestLiveSync_NotUpgrade(t *testing.T) {
+	server := newEventServer(t)
+	resp, err := http.Get(server.URL + "/api/v1/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var response models.ErrorResponse
+	json.NewDecoder(resp.Body).Decode(&response)
+	if resp.StatusCode != http.StatusBadRequest || response.Code != models.ErrorCodeValidationFailed {
+		t.Errorf("Expected a 400 validation error, got %d %+v", resp.StatusCode, response)
+	}
+}
+
+func TestLiveSync_Origin(t *testing.T) {
+	server := newEventServer(t)
+	saved := allowedOrigins
+	SetAllowedOrigins([]string{"http://app.example"})
+	t.Cleanup(func() { SetAllowedOrigins(saved) })
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws"
+	tests := []struct {
+		name   string
+		origin string
+		status int
+	}{
+		{"no origin", "", http.StatusSwitchingProtocols},
+		{"same origin", server.URL, http.StatusSwitchingProtocols},
+		{"allowed origin", "http://app.example", http.StatusSwitchingProtocols},
+		{"foreign origin", "http://evil.example", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if conn != nil {
+				conn.Close()
+			}
+			if resp == nil {
+				t.Fatalf("Expected a response, got %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("Expected %d, got %d", tt.status, resp.StatusCode)
+			}
+			if tt.status != http.StatusForbidden {
+				return
+			}
+
+			var response models.ErrorResponse
+			json.NewDecoder(resp.Body).Decode(&response)
+			if response.Code != models.ErrorCodeOriginNotAllowed {
+				t.Errorf("Expected code %s, got %+v", models.ErrorCodeOriginNotAllowed, response)
+			}
+		})
+	}
+}
+
+func TestWaitEvents_WaitsForLiveSessions(t *testing.T) {
+	server := newEventServer(t)
+	conn := dialLive(t, server)
+	liveRequest(t, conn, `{"type":"subscribe","id":"1"}`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := WaitEvents(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected WaitEvents to wait for the open session, got %v", err)
+	}
+
+	conn.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := WaitEvents(ctx); err != nil {
+		t.Errorf("Expected WaitEvents to return once the session ended, got %v", err)
+	}
+}
@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/models"
+	"github.com/sddev/weight-tracker/version"
+)
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+	openAPIErr  error
+)
+
+// OpenAPI serves the OpenAPI 3 document describing every route
+func OpenAPI(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPIJSON, openAPIErr = json.Marshal(openAPIDocument())
+	})
+	if openAPIErr != nil {
+		abortWithError(c, openAPIErr, "Failed to encode API description")
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPIJSON)
+}
+
+// object is a JSON object in the OpenAPI document
+type object = map[string]interface{}
+
+// errorResponses names the shared error responses by status
+var errorResponses = map[int]string{
+	http.StatusBadRequest:          "BadRequest",
+	http.StatusForbidden:           "Forbidden",
+	http.StatusNotFound:            "NotFound",
+	http.StatusConflict:            "Conflict",
+	http.StatusPreconditionFailed:  "PreconditionFailed",
+	http.StatusInternalServerError: "InternalError",
+}
+
+// openAPIDocument builds the OpenAPI document from the route table, with
+// schemas derived from the models' JSON and binding tags
+func openAPIDocument() object {
+	schemas := schemaSet{}
+
+	responses := object{}
+	for status, name := range errorResponses {
+		responses[name] = object{
+			"description": http.StatusText(status),
+			"content": object{
+				"application/json": object{"schema": schemas.of(reflect.TypeOf(models.ErrorResponse{}))},
+				problemContentType: object{"schema": schemas.of(reflect.TypeOf(models.ProblemDetails{}))},
+			},
+		}
+	}
+
+	paths := object{}
+	for _, r := range apiRoutes() {
+		path := openAPIPath(r.path)
+		item, ok := paths[path].(object)
+		if !ok {
+			item = object{}
+			paths[path] = item
+		}
+		item[strings.ToLower(r.method)] = operation(r, schemas)
+	}
+
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":       "Weight Tracker API",
+			"description": "Track body weight entries, tags, statistics and a goal weight.",
+			"version":     version.Version,
+		},
+		"paths": paths,
+		"components": object{
+			"schemas":   schemas,
+			"responses": responses,
+		},
+	}
+}
+
+// openAPIPath converts a gin route pattern ("/weights/:id") to an OpenAPI
+// path template ("/weights/{id}")
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// operation describes one route
+func operation(r route, schemas schemaSet) object {
+	params := []interface{}{}
+	hasID := false
+	for _, segment := range strings.Split(r.path, "/") {
+		if strings.HasPrefix(segment, ":") {
+			hasID = true
+			params = append(params, object{
+				"name": segment[1:], "in": "path", "required": true,
+				"schema": object{"type": "integer"},
+			})
+		}
+	}
+	for _, q := range r.query {
+		schema := paramSchema(q.schema)
+		if q.repeated {
+			schema = object{"type": "array", "items": schema}
+		}
+		params = append(params, object{
+			"name": q.name, "in": "query", "description": q.description, "schema": schema,
+		})
+	}
+
+	for _, h := range r.header {
+		params = append(params, object{
+			"name": h.name, "in": "header", "description": h.description, "schema": paramSchema(h.schema),
+		})
+	}
+
+	responses := object{}
+	for status, model := range r.responses {
+		responses[strconv.Itoa(status)] = successResponse(status, model, r.conditional != "", schemas)
+	}
+
+	errs := append([]int{http.StatusInternalServerError}, r.errors...)
+	if hasID || r.body != nil {
+		errs = append(errs, http.StatusBadRequest)
+	}
+
+	switch r.conditional {
+	case "read":
+		params = append(params, object{
+			"name": "If-None-Match", "in": "header", "schema": object{"type": "string"},
+			"description": "Respond 304 Not Modified if the ETag still matches",
+		})
+		responses[strconv.Itoa(http.StatusNotModified)] = object{"description": "Not Modified"}
+	case "write":
+		params = append(params, object{
+			"name": "If-Match", "in": "header", "schema": object{"type": "string"},
+			"description": "Apply the change only if the resource is still at this ETag",
+		})
+		errs = append(errs, http.StatusPreconditionFailed)
+	}
+
+	for _, status := range errs {
+		// A route's own description of a status wins, as for /health's 500
+		if _, ok := responses[strconv.Itoa(status)]; !ok {
+			responses[strconv.Itoa(status)] = object{"$ref": "#/components/responses/" + errorResponses[status]}
+		}
+	}
+
+	op := object{
+		"operationId": r.id,
+		"tags":        []string{r.tag},
+		"summary":     r.summary,
+		"responses":   responses,
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	switch body := r.body.(type) {
+	case nil:
+	case mergePatch:
+		schema := schemas.patch(reflect.TypeOf(body.of))
+		op["requestBody"] = object{
+			"required": true,
+			"content": object{
+				"application/merge-patch+json": object{"schema": schema},
+				"application/json":             object{"schema": schema},
+			},
+		}
+	default:
+		op["requestBody"] = object{
+			"required": true,
+			"content":  object{"application/json": object{"schema": schemas.of(reflect.TypeOf(body))}},
+		}
+	}
+
+	return op
+}
+
+// successResponse describes a success status and its body, if any
+func successResponse(status int, model interface{}, etag bool, schemas schemaSet) object {
+	response := object{"description": http.StatusText(status)}
+
+	var content object
+	switch model := model.(type) {
+	case nil:
+		return response
+	case textBody:
+		content = object{string(model): object{"schema": object{"type": "string"}}}
+	case map[string]interface{}:
+		content = object{"application/json": object{"schema": object{"type": "object"}}}
+	default:
+		content = object{"application/json": object{"schema": schemas.of(reflect.TypeOf(model))}}
+	}
+	response["content"] = content
+
+	if etag {
+		response["headers"] = object{
+			"ETag": object{"schema": object{"type": "string"}, "description": "Entity tag for conditional requests"},
+		}
+	}
+	return response
+}
+
+// paramSchema returns the schema of a query parameter type
+func paramSchema(name string) object {
+	if name == "date" {
+		return object{"type": "string", "format": "date"}
+	}
+	return object{"type": name}
+}
+
+// schemaSet collects the component schemas of the models, keyed by type name
+type schemaSet object
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// of returns the schema of t, adding named structs to the set and
+// referring to them
+func (s schemaSet) of(t reflect.Type) object {
+	switch {
+	case t == rawMessageType:
+		return object{"nullable": true, "description": "Any JSON value"}
+	case t.Kind() == reflect.Struct:
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = nil // reserve the name against recursion
+			s[t.Name()] = s.object(t, false)
+		}
+		return object{"$ref": "#/components/schemas/" + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.of(t.Elem())
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return object{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return object{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return object{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": s.of(t.Elem())}
+	}
+	return object{}
+}
+
+// patch returns the schema of a merge patch of the input model t, naming it
+// after the model ("WeightInput" becomes "WeightPatch")
+func (s schemaSet) patch(t reflect.Type) object {
+	name := strings.TrimSuffix(t.Name(), "Input") + "Patch"
+	if _, ok := s[name]; !ok {
+		s[name] = s.object(t, true)
+	}
+	return object{"$ref": "#/components/schemas/" + name}
+}
+
+// object builds the schema of a struct from its fields' JSON names and
+// binding constraints. Fields of input models (with binding tags) are
+// required only when bound as required; fields of response models are
+// required unless omitempty. A patch requires nothing, rejects unknown
+// members and lets lists be cleared with null.
+func (s schemaSet) object(t reflect.Type, patch bool) object {
+	input := false
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("binding"); ok {
+			input = true
+		}
+	}
+
+	properties := object{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := s.of(field.Type)
+		if field.Type.Kind() == reflect.String && (name == "date" || strings.HasSuffix(name, "_date")) {
+			schema["format"] = "date"
+		}
+		bindingRequired := applyBinding(schema, field.Tag.Get("binding"))
+		if patch && field.Type.Kind() == reflect.Slice {
+			schema["nullable"] = true
+		}
+		properties[name] = schema
+
+		switch {
+		case patch:
+		case input && bindingRequired:
+			required = append(required, name)
+		case !input && !strings.Contains(options, "omitempty"):
+			required = append(required, name)
+		}
+	}
+
+	schema := object{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	if patch {
+		schema["additionalProperties"] = false
+	}
+	return schema
+}
+
+// applyBinding adds a field's validator constraints to its schema and
+// reports whether the field is required. Constraints after "dive" apply to
+// the items of a list.
+func applyBinding(schema object, binding string) bool {
+	required := false
+	target, inItems := schema, false
+
+	for _, rule := range strings.Split(binding, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "dive":
+			items, ok := schema["items"].(object)
+			if !ok {
+				return required
+			}
+			target, inItems = items, true
+		case "required":
+			if !inItems {
+				required = true
+			} else if target["type"] == "string" {
+				target["minLength"] = 1
+			}
+		case "gt":
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				target["minimum"] = n
+				target["exclusiveMinimum"] = true
+			}
+		case "min":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			switch target["type"] {
+			case "string":
+				target["minLength"] = n
+			case "array":
+				target["minItems"] = n
+			default:
+				target["minimum"] = n
+			}
+		case "oneof":
+			target["enum"] = strings.Fields(param)
+		case "url":
+			target["format"] = "uri"
+		case "uuid":
+			target["format"] = "uuid"
+		case "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			switch target["type"] {
+			case "string":
+				target["maxLength"] = n
+			case "array":
+				target["maxItems"] = n
+			default:
+				target["maximum"] = n
+			}
+		}
+	}
+	return required
+}
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+)
+
+// apiTester sends requests through every registered route and checks each
+// response against the OpenAPI document the server publishes
+type apiTester struct {
+	t       *testing.T
+	router  *gin.Engine
+	doc     map[string]interface{}
+	route   string
+	covered map[string]bool
+	// ctx, if set, is the context of the requests sent
+	ctx context.Context
+}
+
+func newAPITester(t *testing.T) *apiTester {
+	a := &apiTester{t: t, covered: map[string]bool{}}
+
+	gin.SetMode(gin.TestMode)
+	a.router = gin.New()
+	a.router.Use(func(c *gin.Context) {
+		c.Next()
+		a.route = c.FullPath()
+	})
+	a.router.Use(ErrorHandler())
+	a.router.NoRoute(NoRoute)
+	RegisterRoutes(a.router, func(c *gin.Context) {
+		c.String(http.StatusOK, "# HELP up\n")
+	})
+
+	w := a.do("GET", "/api/v1/openapi.json", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &a.doc); err != nil {
+		t.Fatalf("Failed to parse OpenAPI document: %v", err)
+	}
+	return a
+}
+
+// do sends a request with alternating header names and values
+func (a *apiTester) do(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	if a.ctx != nil {
+		req = req.WithContext(a.ctx)
+	}
+
+	a.route = ""
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// check sends a request, expects status and validates the response against
+// the documented response of the matched operation
+func (a *apiTester) check(status int, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+
+	w := a.do(method, path, body, headers...)
+	if w.Code != status {
+		a.t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, status, w.Code, w.Body.String())
+	}
+	if a.doc == nil {
+		return w
+	}
+
+	op, ok := a.lookup("paths", openAPIPath(a.route), strings.ToLower(method)).(map[string]interface{})
+	if !ok {
+		a.t.Fatalf("%s %s: route %q is not documented", method, path, a.route)
+	}
+	a.covered[method+" "+a.route] = true
+
+	response, ok := a.resolve(a.lookup(op, "responses", strconv.Itoa(w.Code))).(map[string]interface{})
+	if !ok {
+		a.t.Fatalf("%s %s: status %d is not documented", method, path, w.Code)
+	}
+
+	content, _ := response["content"].(map[string]interface{})
+	if content == nil {
+		if w.Body.Len() != 0 {
+			a.t.Errorf("%s %s: expected no body for %d, got %s", method, path, w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	schema, ok := a.lookup(content, mediaType, "schema").(map[string]interface{})
+	if !ok {
+		a.t.Fatalf("%s %s: content type %q is not documented for %d", method, path, mediaType, w.Code)
+	}
+	if !strings.HasSuffix(mediaType, "json") {
+		return w
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &value); err != nil {
+		a.t.Fatalf("%s %s: invalid JSON body: %v", method, path, err)
+	}
+	for _, problem := range a.validate(schema, value, "body") {
+		a.t.Errorf("%s %s (%d): %s", method, path, w.Code, problem)
+	}
+	return w
+}
+
+// lookup walks nested objects of the document, starting at the root when
+// the first key is a string
+func (a *apiTester) lookup(keys ...interface{}) interface{} {
+	var node interface{} = a.doc
+	if start, ok := keys[0].(map[string]interface{}); ok {
+		node, keys = start, keys[1:]
+	}
+	for _, key := range keys {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = m[key.(string)]
+	}
+	return node
+}
+
+// resolve follows a local $ref
+func (a *apiTester) resolve(node interface{}) interface{} {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return node
+	}
+	ref, ok := m["$ref"].(string)
+	if !ok {
+		return node
+	}
+	keys := []interface{}{}
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		keys = append(keys, key)
+	}
+	return a.resolve(a.lookup(keys...))
+}
+
+// validate checks value against the subset of OpenAPI 3.0 schema keywords
+// the document uses. Objects without additionalProperties are treated as
+// closed, so undocumented response members fail too.
+func (a *apiTester) validate(schemaNode interface{}, value interface{}, at string) []string {
+	schema, _ := a.resolve(schemaNode).(map[string]interface{})
+	if schema == nil {
+		return []string{at + ": unresolvable schema"}
+	}
+
+	if value == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []string{at + ": null is not allowed"}
+	}
+
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected object, got %T", value)
+			break
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				fail("missing required member %q", name)
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name]; ok {
+				problems = append(problems, a.validate(property, obj[name], at+"."+name)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case map[string]interface{}:
+				problems = append(problems, a.validate(extra, obj[name], at+"."+name)...)
+			case nil:
+				if properties != nil {
+					fail("undocumented member %q", name)
+				}
+			case bool:
+				if !extra {
+					fail("undocumented member %q", name)
+				}
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("expected array, got %T", value)
+			break
+		}
+		for i, item := range items {
+			problems = append(problems, a.validate(schema["items"], item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			fail("expected string, got %T", value)
+			break
+		}
+		if schema["format"] == "date" {
+			if _, err := time.Parse("2006-01-02", s); err != nil {
+				fail("expected a date, got %q", s)
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			fail("expected integer, got %v", value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail("expected number, got %T", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean, got %T", value)
+		}
+	}
+
+	return problems
+}
+
+func TestOpenAPI_ResponsesMatchSchema(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	a := newAPITester(t)
+
+	// Health, metrics and the document itself
+	a.check(http.StatusOK, "GET", "/livez", "")
+	a.check(http.StatusOK, "GET", "/readyz", "")
+	a.check(http.StatusOK, "GET", "/health?verbose=1", "")
+	a.check(http.StatusOK, "GET", "/metrics", "")
+	a.check(http.StatusOK, "GET", "/api/v1/openapi.json", "")
+
+	// Tags
+	a.check(http.StatusCreated, "POST", "/api/v1/tags", `{"name": "holiday"}`)
+	a.check(http.StatusConflict, "POST", "/api/v1/tags", `{"name": "Holiday"}`)
+	a.check(http.StatusBadRequest, "POST", "/api/v1/tags", `{"name": ""}`)
+	a.check(http.StatusOK, "PUT", "/api/v1/tags/1", `{"name": "travel", "exclude_from_stats": true}`)
+	a.check(http.StatusNotFound, "PUT", "/api/v1/tags/99", `{"name": "other"}`)
+	a.check(http.StatusOK, "GET", "/api/v1/tags", "")
+
+	// Weights
+	a.check(http.StatusCreated, "POST", "/api/v1/weights", `{"date": "2026-01-01", "pounds": 170, "note": "start", "tags": ["travel"]}`)
+	a.check(http.StatusCreated, "POST", "/api/v1/weights", `{"date": "2026-01-08", "pounds": 168.5}`)
+	a.check(http.StatusConflict, "POST", "/api/v1/weights", `{"date": "2026-01-08", "pounds": 168}`)
+	a.check(http.StatusBadRequest, "POST", "/api/v1/weights", `{"pounds": -1}`)
+	a.check(http.StatusBadRequest, "POST", "/api/v1/weights", `not json`)
+	list := a.check(http.StatusOK, "GET", "/api/v1/weights?start_date=2026-01-01&tag=travel", "")
+	a.check(http.StatusNotModified, "GET", "/api/v1/weights?start_date=2026-01-01&tag=travel", "", "If-None-Match", list.Header().Get("ETag"))
+	a.check(http.StatusOK, "GET", "/api/v1/weights/1", "")
+	a.check(http.StatusNotFound, "GET", "/api/v1/weights/99", "")
+	a.check(http.StatusNotFound, "GET", "/api/v1/weights/99", "", "Accept", "application/problem+json")
+	a.check(http.StatusBadRequest, "GET", "/api/v1/weights/abc", "")
+	a.check(http.StatusOK, "PUT", "/api/v1/weights/2", `{"date": "2026-01-08", "pounds": 168}`, "If-Match", `"1"`)
+	a.check(http.StatusPreconditionFailed, "PUT", "/api/v1/weights/2", `{"date": "2026-01-08", "pounds": 167}`, "If-Match", `"1"`)
+	a.check(http.StatusOK, "PATCH", "/api/v1/weights/2", `{"note": "after lunch", "tags": null}`)
+	a.check(http.StatusBadRequest, "PATCH", "/api/v1/weights/2", `{"pounds": 0, "id": 3}`)
+	a.check(http.StatusNoContent, "DELETE", "/api/v1/weights/2", "")
+	a.check(http.StatusOK, "GET", "/api/v1/weights/trash", "")
+	a.check(http.StatusOK, "POST", "/api/v1/weights/2/restore", "")
+	a.check(http.StatusNotFound, "POST", "/api/v1/weights/2/restore", "")
+
+	// Stats and goal
+	a.check(http.StatusOK, "GET", "/api/v1/stats?include_excluded=true", "")
+	a.check(http.StatusOK, "GET", "/api/v1/goal", "")
+	a.check(http.StatusOK, "PUT", "/api/v1/goal", `{"pounds": 160}`)
+	a.check(http.StatusBadRequest, "PUT", "/api/v1/goal", `{"pounds": "low"}`)
+
+	// The event stream ends once the client has gone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.ctx = ctx
+	a.check(http.StatusOK, "GET", "/api/v1/events", "")
+	a.ctx = nil
+	// Upgrades are covered by the live sync tests
+	a.check(http.StatusBadRequest, "GET", "/api/v1/ws", "")
+
+	// Webhooks
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+	a.check(http.StatusCreated, "POST", "/api/v1/webhooks", `{"url": "`+receiver.URL+`", "events": ["weight.created"], "secret": "0123456789abcdef"}`)
+	a.check(http.StatusBadRequest, "POST", "/api/v1/webhooks", `{"url": "example.com", "events": ["weight.created"]}`)
+	a.check(http.StatusOK, "GET", "/api/v1/webhooks", "")
+	a.check(http.StatusOK, "GET", "/api/v1/webhooks/1", "")
+	a.check(http.StatusNotFound, "GET", "/api/v1/webhooks/2", "")
+	a.check(http.StatusOK, "PUT", "/api/v1/webhooks/1", `{"url": "`+receiver.URL+`", "events": ["goal.reached"], "active": false}`)
+	a.check(http.StatusOK, "POST", "/api/v1/webhooks/1/test", "")
+	a.check(http.StatusOK, "GET", "/api/v1/webhooks/1/deliveries?limit=10", "")
+	a.check(http.StatusNotFound, "GET", "/api/v1/webhooks/2/deliveries", "")
+	a.check(http.StatusNoContent, "DELETE", "/api/v1/webhooks/1", "")
+
+	// Offline sync
+	a.check(http.StatusOK, "POST", "/api/v1/sync", `{"changes": [
+		{"uuid": "0b0f7c4e-3f5a-4c1e-9d7a-2f1e8b6c5a40", "updated_at": "2026-01-05T08:00:00Z", "date": "2026-01-05", "pounds": 171, "tags": ["morning"]},
+		{"uuid": "not-a-uuid", "updated_at": "yesterday"}
+	]}`)
+	a.check(http.StatusOK, "POST", "/api/v1/sync", `{"sync_token": "1", "changes": [
+		{"uuid": "0b0f7c4e-3f5a-4c1e-9d7a-2f1e8b6c5a40", "updated_at": "2026-01-04T08:00:00Z", "pounds": 170}
+	]}`)
+	a.check(http.StatusBadRequest, "POST", "/api/v1/sync", `{"sync_token": "later"}`)
+
+	// History
+	a.check(http.StatusOK, "GET", "/api/v1/history?entity=weight&limit=10", "")
+	a.check(http.StatusBadRequest, "GET", "/api/v1/history?limit=0", "")
+	a.check(http.StatusOK, "GET", "/api/v1/weights/1/history", "")
+	a.check(http.StatusOK, "GET", "/api/v1/goal/history", "")
+
+	a.check(http.StatusNoContent, "DELETE", "/api/v1/tags/1", "")
+
+	// Server errors
+	if _, err := db.DB.Exec("DROP TABLE weight_tags"); err != nil {
+		t.Fatalf("Failed to drop table: %v", err)
+	}
+	a.check(http.StatusInternalServerError, "GET", "/api/v1/weights", "")
+	db.DB.Close()
+	a.check(http.StatusInternalServerError, "GET", "/health", "")
+
+	for _, r := range a.router.Routes() {
+		if !a.covered[r.Method+" "+r.Path] {
+			t.Errorf("No response checked for %s %s", r.Method, r.Path)
+		}
+	}
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	a := newAPITester(t)
+
+	if a.doc["openapi"] != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %v", a.doc["openapi"])
+	}
+
+	paths, _ := a.doc["paths"].(map[string]interface{})
+	operations := 0
+	for _, item := range paths {
+		operations += len(item.(map[string]interface{}))
+	}
+	if routes := a.router.Routes(); operations != len(routes) {
+		t.Errorf("Expected %d documented operations, got %d", len(routes), operations)
+	}
+
+	for _, r := range a.router.Routes() {
+		op, ok := a.lookup("paths", openAPIPath(r.Path), strings.ToLower(r.Method)).(map[string]interface{})
+		if !ok {
+			t.Errorf("%s %s is not documented", r.Method, r.Path)
+			continue
+		}
+		if _, ok := a.lookup(op, "responses", "500").(map[string]interface{}); !ok {
+			t.Errorf("%s %s does not document 500", r.Method, r.Path)
+		}
+	}
+
+	// Every $ref must resolve
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch node := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := node["$ref"].(string); ok && a.resolve(node) == nil {
+				t.Errorf("Unresolved reference %s", ref)
+			}
+			for _, child := range node {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(a.doc)
+}
+
+func TestOpenAPI_InputConstraints(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	a := newAPITester(t)
+
+	input := a.resolve(a.lookup("components", "schemas", "WeightInput")).(map[string]interface{})
+	if got := fmt.Sprint(input["required"]); got != "[date pounds]" {
+		t.Errorf("Expected date and pounds required, got %s", got)
+	}
+
+	pounds := a.lookup(input, "properties", "pounds").(map[string]interface{})
+	if pounds["minimum"] != 0.0 || pounds["exclusiveMinimum"] != true {
+		t.Errorf("Expected pounds > 0, got %v", pounds)
+	}
+	tags := a.lookup(input, "properties", "tags").(map[string]interface{})
+	if tags["maxItems"] != 20.0 || a.lookup(tags, "items", "maxLength") != 50.0 {
+		t.Errorf("Expected at most 20 tags of at most 50 characters, got %v", tags)
+	}
+
+	webhook := a.resolve(a.lookup("components", "schemas", "WebhookInput")).(map[string]interface{})
+	events := a.lookup(webhook, "properties", "events").(map[string]interface{})
+	if events["minItems"] != 1.0 || len(a.lookup(events, "items", "enum").([]interface{})) != 5 {
+		t.Errorf("Expected at least one of 5 event types, got %v", events)
+	}
+	if a.lookup(webhook, "properties", "url", "format") != "uri" || a.lookup(webhook, "properties", "secret", "minLength") != 16.0 {
+		t.Errorf("Expected a URI and a secret of at least 16 characters, got %v", webhook["properties"])
+	}
+
+	change := a.resolve(a.lookup("components", "schemas", "SyncChange")).(map[string]interface{})
+	if a.lookup(change, "properties", "uuid", "format") != "uuid" || len(change["required"].([]interface{})) != 2 {
+		t.Errorf("Expected a required UUID and updated_at, got %v", change)
+	}
+
+	patch := a.resolve(a.lookup("components", "schemas", "WeightPatch")).(map[string]interface{})
+	if patch["required"] != nil || patch["additionalProperties"] != false {
+		t.Errorf("Expected an optional, closed patch schema, got %v", patch)
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// route is an HTTP endpoint together with the description published in the
+// OpenAPI document, so that every registered route is documented
+type route struct {
+	method  string
+	path    string
+	handler gin.HandlerFunc
+	id      string
+	tag     string
+	summary string
+	query   []queryParam
+	header  []queryParam
+	// body is a zero value of the request model, or a mergePatch of one
+	body interface{}
+	// responses maps success statuses to a zero value of the response
+	// model; nil means no body
+	responses map[int]interface{}
+	// errors lists the error statuses beyond 500, which every route has
+	errors []int
+	// conditional is "read" for GETs honouring If-None-Match and "write"
+	// for changes honouring If-Match
+	conditional string
+}
+
+// queryParam documents a query string or header parameter
+type queryParam struct {
+	name        string
+	schema      string
+	repeated    bool
+	description string
+}
+
+// mergePatch marks a JSON Merge Patch body: any subset of the model's
+// fields, where null clears a field
+type mergePatch struct {
+	of interface{}
+}
+
+// textBody is a non-JSON response body of the given media type
+type textBody string
+
+var dateRange = []queryParam{
+	{name: "start_date", schema: "date", description: "Only entries on or after this date"},
+	{name: "end_date", schema: "date", description: "Only entries on or before this date"},
+}
+
+var pageParams = []queryParam{
+	{name: "limit", schema: "integer", description: "Page size, 1-200 (default 50)"},
+	{name: "offset", schema: "integer", description: "Entries to skip (default 0)"},
+}
+
+// apiRoutes lists every endpoint of the server. A nil handler is supplied by
+// the caller of RegisterRoutes.
+func apiRoutes() []route {
+	return []route{
+		// Health checks
+		{method: "GET", path: "/livez", handler: Livez, id: "livez", tag: "health",
+			summary:   "Liveness probe",
+			responses: map[int]interface{}{http.StatusOK: models.HealthResponse{}}},
+		{method: "GET", path: "/readyz", handler: Readyz, id: "readyz", tag: "health",
+			summary:   "Readiness probe",
+			responses: map[int]interface{}{http.StatusOK: models.HealthResponse{}, http.StatusServiceUnavailable: models.HealthResponse{}}},
+		{method: "GET", path: "/health", handler: HealthCheck, id: "health", tag: "health",
+			summary: "Health check with database status",
+			query: []queryParam{
+				{name: "verbose", schema: "string", description: "1 or true adds build, uptime and detailed checks"},
+			},
+			responses: map[int]interface{}{http.StatusOK: models.HealthResponse{}, http.StatusInternalServerError: models.HealthResponse{}}},
+
+		// Prometheus metrics
+		{method: "GET", path: "/metrics", id: "metrics", tag: "health",
+			summary:   "Prometheus metrics",
+			responses: map[int]interface{}{http.StatusOK: textBody("text/plain")}},
+
+		// API description
+		{method: "GET", path: "/api/v1/openapi.json", handler: OpenAPI, id: "getOpenAPI", tag: "meta",
+			summary:   "This OpenAPI document",
+			responses: map[int]interface{}{http.StatusOK: map[string]interface{}{}}},
+
+		// Weights
+		{method: "GET", path: "/api/v1/weights", handler: GetWeights, id: "listWeights", tag: "weights",
+			summary: "List weight entries, newest first",
+			query: append(dateRange[:len(dateRange):len(dateRange)],
+				queryParam{name: "tag", schema: "string", repeated: true, description: "Only entries with any of these tags"},
+				queryParam{name: "exclude_tag", schema: "string", repeated: true, description: "Leave out entries with any of these tags"},
+			),
+			responses:   map[int]interface{}{http.StatusOK: models.WeightsResponse{}},
+			conditional: "read"},
+		{method: "GET", path: "/api/v1/weights/:id", handler: GetWeight, id: "getWeight", tag: "weights",
+			summary:     "Get a weight entry",
+			responses:   map[int]interface{}{http.StatusOK: models.Weight{}},
+			errors:      []int{http.StatusNotFound},
+			conditional: "read"},
+		{method: "POST", path: "/api/v1/weights", handler: CreateWeight, id: "createWeight", tag: "weights",
+			summary:   "Create a weight entry",
+			body:      models.WeightInput{},
+			responses: map[int]interface{}{http.StatusCreated: models.Weight{}},
+			errors:    []int{http.StatusBadRequest, http.StatusConflict}},
+		{method: "PUT", path: "/api/v1/weights/:id", handler: UpdateWeight, id: "updateWeight", tag: "weights",
+			summary:     "Replace a weight entry",
+			body:        models.WeightInput{},
+			responses:   map[int]interface{}{http.StatusOK: models.Weight{}},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+			conditional: "write"},
+		{method: "PATCH", path: "/api/v1/weights/:id", handler: PatchWeight, id: "patchWeight", tag: "weights",
+			summary:     "Partially update a weight entry",
+			body:        mergePatch{models.WeightInput{}},
+			responses:   map[int]interface{}{http.StatusOK: models.Weight{}},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+			conditional: "write"},
+		{method: "DELETE", path: "/api/v1/weights/:id", handler: DeleteWeight, id: "deleteWeight", tag: "weights",
+			summary:     "Move a weight entry to the trash",
+			responses:   map[int]interface{}{http.StatusNoContent: nil},
+			errors:      []int{http.StatusNotFound},
+			conditional: "write"},
+
+		// Trash
+		{method: "GET", path: "/api/v1/weights/trash", handler: GetTrash, id: "listTrash", tag: "weights",
+			summary:   "List trashed weight entries",
+			responses: map[int]interface{}{http.StatusOK: models.WeightsResponse{}}},
+		{method: "POST", path: "/api/v1/weights/:id/restore", handler: RestoreWeight, id: "restoreWeight", tag: "weights",
+			summary:   "Restore a trashed weight entry",
+			responses: map[int]interface{}{http.StatusOK: models.Weight{}},
+			errors:    []int{http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed}},
+
+		// Tags
+		{method: "GET", path: "/api/v1/tags", handler: GetTags, id: "listTags", tag: "tags",
+			summary:   "List tags with usage counts",
+			responses: map[int]interface{}{http.StatusOK: models.TagsResponse{}}},
+		{method: "POST", path: "/api/v1/tags", handler: CreateTag, id: "createTag", tag: "tags",
+			summary:   "Create a tag",
+			body:      models.TagInput{},
+			responses: map[int]interface{}{http.StatusCreated: models.Tag{}},
+			errors:    []int{http.StatusBadRequest, http.StatusConflict}},
+		{method: "PUT", path: "/api/v1/tags/:id", handler: UpdateTag, id: "updateTag", tag: "tags",
+			summary:   "Rename a tag or toggle exclude_from_stats",
+			body:      models.TagInput{},
+			responses: map[int]interface{}{http.StatusOK: models.Tag{}},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{method: "DELETE", path: "/api/v1/tags/:id", handler: DeleteTag, id: "deleteTag", tag: "tags",
+			summary:   "Delete a tag and detach it from entries",
+			responses: map[int]interface{}{http.StatusNoContent: nil},
+			errors:    []int{http.StatusNotFound}},
+
+		// Stats
+		{method: "GET", path: "/api/v1/stats", handler: GetStats, id: "getStats", tag: "stats",
+			summary: "Summary statistics and weekly trend",
+			query: append(dateRange[:len(dateRange):len(dateRange)],
+				queryParam{name: "exclude_tag", schema: "string", repeated: true, description: "Also leave out entries with any of these tags"},
+				queryParam{name: "include_excluded", schema: "boolean", description: "Include entries with exclude_from_stats tags"},
+			),
+			responses: map[int]interface{}{http.StatusOK: models.StatsResponse{}}},
+
+		// History
+		{method: "GET", path: "/api/v1/history", handler: GetHistory, id: "listHistory", tag: "history",
+			summary: "Audit log of all changes, newest first",
+			query: append([]queryParam{
+				{name: "entity", schema: "string", description: "weight or goal"},
+				{name: "action", schema: "string", description: "create, update, delete or restore"},
+			}, pageParams...),
+			responses: map[int]interface{}{http.StatusOK: models.AuditLogResponse{}},
+			errors:    []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/v1/weights/:id/history", handler: GetWeightHistory, id: "listWeightHistory", tag: "history",
+			summary:   "Audit log of a weight entry",
+			query:     pageParams,
+			responses: map[int]interface{}{http.StatusOK: models.AuditLogResponse{}},
+			errors:    []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/v1/goal/history", handler: GetGoalHistory, id: "listGoalHistory", tag: "history",
+			summary:   "Audit log of the goal weight",
+			query:     pageParams,
+			responses: map[int]interface{}{http.StatusOK: models.AuditLogResponse{}},
+			errors:    []int{http.StatusBadRequest}},
+
+		// Goal
+		{method: "GET", path: "/api/v1/goal", handler: GetGoal, id: "getGoal", tag: "goal",
+			summary:     "Get the goal weight",
+			responses:   map[int]interface{}{http.StatusOK: models.Goal{}},
+			conditional: "read"},
+		{method: "PUT", path: "/api/v1/goal", handler: UpdateGoal, id: "updateGoal", tag: "goal",
+			summary:     "Set or clear the goal weight",
+			body:        models.GoalInput{},
+			responses:   map[int]interface{}{http.StatusOK: models.Goal{}},
+			errors:      []int{http.StatusBadRequest},
+			conditional: "write"},
+
+		// Change notifications
+		{method: "GET", path: "/api/v1/events", handler: StreamEvents, id: "streamEvents", tag: "events",
+			summary: "Server-sent events for weight and goal changes",
+			header: []queryParam{
+				{name: "Last-Event-ID", schema: "string", description: "Resume after this event, replaying those missed"},
+			},
+			responses: map[int]interface{}{http.StatusOK: textBody("text/event-stream")}},
+		{method: "GET", path: "/api/v1/ws", handler: LiveSync, id: "liveSync", tag: "events",
+			summary:   "WebSocket to subscribe to changes and submit them",
+			responses: map[int]interface{}{http.StatusSwitchingProtocols: nil},
+			errors:    []int{http.StatusBadRequest, http.StatusForbidden}},
+
+		// Offline sync
+		{method: "POST", path: "/api/v1/sync", handler: Sync, id: "sync", tag: "sync",
+			summary:   "Apply offline changes and get every change since a sync token",
+			body:      models.SyncRequest{},
+			responses: map[int]interface{}{http.StatusOK: models.SyncResponse{}},
+			errors:    []int{http.StatusBadRequest, http.StatusConflict, http.StatusPreconditionFailed}},
+
+		// Webhooks
+		{method: "GET", path: "/api/v1/webhooks", handler: GetWebhooks, id: "listWebhooks", tag: "webhooks",
+			summary:   "List webhooks",
+			responses: map[int]interface{}{http.StatusOK: models.WebhooksResponse{}}},
+		{method: "POST", path: "/api/v1/webhooks", handler: CreateWebhook, id: "createWebhook", tag: "webhooks",
+			summary:   "Subscribe a URL to change events",
+			body:      models.WebhookInput{},
+			responses: map[int]interface{}{http.StatusCreated: models.Webhook{}},
+			errors:    []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/v1/webhooks/:id", handler: GetWebhook, id: "getWebhook", tag: "webhooks",
+			summary:   "Get a webhook",
+			responses: map[int]interface{}{http.StatusOK: models.Webhook{}},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound}},
+		{method: "PUT", path: "/api/v1/webhooks/:id", handler: UpdateWebhook, id: "updateWebhook", tag: "webhooks",
+			summary:   "Replace a webhook, keeping its secret unless one is given",
+			body:      models.WebhookInput{},
+			responses: map[int]interface{}{http.StatusOK: models.Webhook{}},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound}},
+		{method: "DELETE", path: "/api/v1/webhooks/:id", handler: DeleteWebhook, id: "deleteWebhook", tag: "webhooks",
+			summary:   "Delete a webhook and its delivery log",
+			responses: map[int]interface{}{http.StatusNoContent: nil},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound}},
+		{method: "GET", path: "/api/v1/webhooks/:id/deliveries", handler: GetWebhookDeliveries, id: "listWebhookDeliveries", tag: "webhooks",
+			summary:   "Delivery log of a webhook, newest first",
+			query:     pageParams,
+			responses: map[int]interface{}{http.StatusOK: models.WebhookDeliveriesResponse{}},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound}},
+		{method: "POST", path: "/api/v1/webhooks/:id/test", handler: TestWebhook, id: "testWebhook", tag: "webhooks",
+			summary:   "Send a ping to a webhook and return the delivery",
+			responses: map[int]interface{}{http.StatusOK: models.WebhookDelivery{}},
+			errors:    []int{http.StatusBadRequest, http.StatusNotFound}},
+	}
+}
+
+// RegisterRoutes adds every endpoint to router, serving /metrics with
+// metrics
+func RegisterRoutes(router gin.IRoutes, metrics gin.HandlerFunc) {
+	for _, r := range apiRoutes() {
+		handler := r.handler
+		if handler == nil {
+			handler = metrics
+		}
+		router.Handle(r.method, r.path, handler)
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// excludedFromStats is a subquery, correlated on weights.id, matching entries
+// carrying a tag flagged to be left out of stats and trends
+const excludedFromStats = `SELECT 1 FROM weight_tags wt JOIN tags t ON t.id = wt.tag_id
+	WHERE wt.weight_id = weights.id AND t.exclude_from_stats = TRUE`
+
+// GetStats computes summary statistics and the weekly trend over weight
+// entries in an optional date range. Entries with a tag flagged
+// exclude_from_stats, or with any exclude_tag given, are left out unless
+// include_excluded=true.
+func GetStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	where := " WHERE deleted_at IS NULL"
+	args := []interface{}{}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		where += " AND date >= ?"
+		args = append(args, startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		where += " AND date <= ?"
+		args = append(args, endDate)
+	}
+
+	var total int
+	if err := db.ReadDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM weights"+where, args...).Scan(&total); err != nil {
+		abortWithError(c, err, "Failed to compute stats")
+		return
+	}
+
+	if c.Query("include_excluded") != "true" {
+		where += " AND NOT EXISTS (" + excludedFromStats + ")"
+	}
+	if excluded := c.QueryArray("exclude_tag"); len(excluded) > 0 {
+		where += " AND NOT EXISTS (" + taggedWith(excluded) + ")"
+		args = append(args, stringArgs(excluded)...)
+	}
+
+	rows, err := db.ReadDB.QueryContext(ctx, "SELECT date, pounds FROM weights"+where+" ORDER BY date ASC", args...)
+	if err != nil {
+		abortWithError(c, err, "Failed to compute stats")
+		return
+	}
+	defer rows.Close()
+
+	var dates []string
+	var pounds []float64
+	for rows.Next() {
+		var date string
+		var p float64
+		if err := rows.Scan(&date, &p); err != nil {
+			abortWithError(c, err, "Failed to scan weight entry")
+			return
+		}
+		dates = append(dates, date)
+		pounds = append(pounds, p)
+	}
+
+	stats := computeStats(dates, pounds)
+	stats.ExcludedCount = total - stats.Count
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// computeStats summarizes weight entries given in ascending date order
+func computeStats(dates []string, pounds []float64) models.StatsResponse {
+	stats := models.StatsResponse{Count: len(pounds)}
+	if len(pounds) == 0 {
+		return stats
+	}
+
+	first, last := pounds[0], pounds[len(pounds)-1]
+	min, max, sum := first, first, 0.0
+	for _, p := range pounds {
+		min = math.Min(min, p)
+		max = math.Max(max, p)
+		sum += p
+	}
+	average := round2(sum / float64(len(pounds)))
+	change := round2(last - first)
+
+	stats.FirstDate = &dates[0]
+	stats.LastDate = &dates[len(dates)-1]
+	stats.StartPounds = &first
+	stats.LatestPounds = &last
+	stats.MinPounds = &min
+	stats.MaxPounds = &max
+	stats.AveragePounds = &average
+	stats.ChangePounds = &change
+	stats.TrendPoundsPerWeek = trendPerWeek(dates, pounds)
+
+	return stats
+}
+
+// trendPerWeek fits a least-squares line through the entries and returns its
+// slope in pounds per week, or nil when fewer than two distinct days exist
+func trendPerWeek(dates []string, pounds []float64) *float64 {
+	start, err := time.Parse("2006-01-02", dates[0])
+	if err != nil {
+		return nil
+	}
+
+	n := float64(len(pounds))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, date := range dates {
+		d, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil
+		}
+		x := d.Sub(start).Hours() / 24
+		sumX += x
+		sumY += pounds[i]
+		sumXY += x * pounds[i]
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return nil
+	}
+
+	slope := round2((n*sumXY - sumX*sumY) / denominator * 7)
+	return &slope
+}
+
+// round2 rounds to two decimal places
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+	"github.com/sddev/weight-tracker/synth"
+)
+
+func TestGetStats_Empty(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/stats", GetStats)
+
+	req, _ := http.NewRequest("GET", "/stats", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var stats models.StatsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if stats.Count != 0 || stats.AveragePounds != nil {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+}
+
+func TestGetStats_ExcludesFlaggedTags(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-08', 169.0)")
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-10', 200.0)")
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-15', 168.0)")
+	db.DB.Exec("INSERT INTO tags (name, exclude_from_stats) VALUES ('unreliable', TRUE)")
+	db.DB.Exec("INSERT INTO weight_tags (weight_id, tag_id) VALUES (3, 1)")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/stats", GetStats)
+
+	req, _ := http.NewRequest("GET", "/stats", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var stats models.StatsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if stats.Count != 3 || stats.ExcludedCount != 1 {
+		t.Errorf("Expected 3 included and 1 excluded, got %d and %d", stats.Count, stats.ExcludedCount)
+	}
+	if *stats.MaxPounds != 170.0 {
+		t.Errorf("Expected max 170.0, got %f", *stats.MaxPounds)
+	}
+	if *stats.ChangePounds != -2.0 {
+		t.Errorf("Expected change -2.0, got %f", *stats.ChangePounds)
+	}
+	if stats.TrendPoundsPerWeek == nil || *stats.TrendPoundsPerWeek >= 0 {
+		t.Errorf("Expected a downward trend, got %v", stats.TrendPoundsPerWeek)
+	}
+
+	// Including flagged entries brings the outlier back
+	req, _ = http.NewRequest("GET", "/stats?include_excluded=true", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	if stats.Count != 4 || *stats.MaxPounds != 200.0 {
+		t.Errorf("Expected 4 entries with max 200.0, got %d and %f", stats.Count, *stats.MaxPounds)
+	}
+}
+
+func TestTrendPerWeek(t *testing.T) {
+	dates := []string{"2026-01-01", "2026-01-08", "2026-01-15"}
+	pounds := []float64{170, 169, 168}
+
+	trend := trendPerWeek(dates, pounds)
+	if trend == nil || *trend != -1.0 {
+		t.Errorf("Expected trend -1.0 per week, got %v", trend)
+	}
+
+	if trendPerWeek([]string{"2026-01-01"}, []float64{170}) != nil {
+		t.Error("Expected no trend for a single entry")
+	}
+}
+
+func BenchmarkGetStats(b *testing.B) {
+	setupTestDB(b)
+	defer teardownTestDB()
+
+	// Five years of realistic daily entries
+	opts := synth.DefaultOptions()
+	opts.Days = 5 * 365
+	var weights []db.SeedWeight
+	for _, entry := range synth.Generate(opts) {
+		weights = append(weights, db.SeedWeight{Date: entry.Date, Pounds: entry.Pounds})
+	}
+	if _, err := db.Seed(weights); err != nil {
+		b.Fatalf("Failed to seed weights: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/stats", GetStats)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/stats", nil))
+		if w.Code != http.StatusOK {
+			b.Fatalf("Expected status 200, got %d", w.Code)
+		}
+	}
+}
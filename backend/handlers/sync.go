@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/events"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// syncFields are the fields of a weight entry that sync resolves
+// independently, in the order conflicts are reported. Each has a
+// <field>_changed_at column recording when it last changed.
+var syncFields = []string{"date", "pounds", "note", "tags", "deleted"}
+
+// Sync applies a client's offline changes to weight entries and responds
+// with every server-side change since the client's sync token. A field set
+// in a change is applied only if the client changed it after the server's
+// copy last changed: last writer wins, field by field.
+func Sync(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request models.SyncRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	since, reset := int64(0), request.SyncToken == ""
+	if !reset {
+		token, err := strconv.ParseInt(request.SyncToken, 10, 64)
+		if err != nil || token < 0 {
+			abortWithFields(c, invalid(err), "Invalid request", []models.FieldError{
+				{Field: "sync_token", Constraint: "sync_token", Message: "must be a token returned by an earlier sync"},
+			})
+			return
+		}
+		since = token
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, err, "Failed to sync")
+		return
+	}
+	defer tx.Rollback()
+
+	batch := &syncBatch{c: c, tx: tx, now: time.Now().UTC(), response: models.SyncResponse{
+		Changes:   []models.Weight{},
+		Deleted:   []string{},
+		Conflicts: []models.SyncConflict{},
+		Rejected:  []models.SyncRejection{},
+	}}
+	for i, change := range request.Changes {
+		if err := batch.apply(i, change); err != nil {
+			abortWithError(c, db.WeightError(err), "Failed to apply changes")
+			return
+		}
+	}
+
+	// Writers take sequence values in commit order, so every change up to
+	// the current value is visible and later ones are left for next time
+	var seq int64
+	if err := tx.QueryRowContext(ctx, "SELECT seq FROM sync_state").Scan(&seq); err != nil {
+		abortWithError(c, err, "Failed to sync")
+		return
+	}
+
+	// A token from ahead of the server, such as one issued before a backup
+	// was restored, can't be trusted
+	if since > seq {
+		reset = true
+	}
+
+	if err := batch.changes(since, seq, reset); err != nil {
+		abortWithError(c, err, "Failed to retrieve changes")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, err, "Failed to sync")
+		return
+	}
+	for _, event := range batch.events {
+		broker.Publish(event.typ, event.data)
+	}
+
+	batch.response.SyncToken = strconv.FormatInt(seq, 10)
+	batch.response.Reset = reset
+	c.JSON(http.StatusOK, batch.response)
+}
+
+// syncBatch applies the changes of one sync request within tx
+type syncBatch struct {
+	c        *gin.Context
+	tx       *sql.Tx
+	now      time.Time
+	response models.SyncResponse
+	// events are published once the changes are committed
+	events []syncEvent
+}
+
+type syncEvent struct {
+	typ  string
+	data interface{}
+}
+
+// apply applies the change at index in the request. Changes that can't be
+// applied are rejected in the response; the error is for failures that
+// abort the whole sync.
+func (b *syncBatch) apply(index int, change models.SyncChange) error {
+	ctx := b.c.Request.Context()
+
+	change.UUID = strings.ToLower(change.UUID)
+	if fields := validateSyncChange(change); len(fields) > 0 {
+		b.reject(index, change, models.ErrorCodeValidationFailed, "Invalid change", fields)
+		return nil
+	}
+
+	// A clock running ahead of the server's must not win every later
+	// conflict
+	at, _ := time.Parse(time.RFC3339Nano, change.UpdatedAt)
+	if at.After(b.now) {
+		at = b.now
+	}
+
+	var old models.Weight
+	err := scanWeight(b.tx.QueryRowContext(ctx, weightSelect()+" WHERE uuid = ?", change.UUID), &old)
+	if errors.Is(err, sql.ErrNoRows) {
+		return b.create(index, change, at)
+	}
+	if err != nil {
+		return err
+	}
+	return b.update(index, change, old, at)
+}
+
+// create adds an entry first created offline
+func (b *syncBatch) create(index int, change models.SyncChange, at time.Time) error {
+	ctx := b.c.Request.Context()
+
+	// Created and deleted before ever being synced: nothing to do
+	if change.Deleted != nil && *change.Deleted {
+		return nil
+	}
+
+	var gone int
+	err := b.tx.QueryRowContext(ctx, "SELECT 1 FROM weight_tombstones WHERE uuid = ?", change.UUID).Scan(&gone)
+	if err == nil {
+		b.reject(index, change, models.ErrorCodeNotFound, "Weight entry was permanently deleted", nil)
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var missing []models.FieldError
+	if change.Date == nil {
+		missing = append(missing, models.FieldError{Field: "date", Constraint: "required", Message: "is required"})
+	}
+	if change.Pounds == nil {
+		missing = append(missing, models.FieldError{Field: "pounds", Constraint: "required", Message: "is required"})
+	}
+	if len(missing) > 0 {
+		b.reject(index, change, models.ErrorCodeValidationFailed, "A new entry needs a date and pounds", missing)
+		return nil
+	}
+
+	if taken, err := dateTaken(ctx, b.tx, *change.Date, 0); err != nil || taken {
+		if taken {
+			b.reject(index, change, models.ErrorCodeDuplicateDate, "Weight entry already exists for this date", nil)
+		}
+		return err
+	}
+
+	before, err := selectLatestWeight(ctx, b.tx)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO weights (uuid, date, pounds, note, created_at, updated_at)
+	          VALUES (?, ?, ?, NULLIF(?, ''), ` + db.Now() + `, ` + db.Now() + `) RETURNING id`
+	var id int
+	err = b.tx.QueryRowContext(ctx, query, change.UUID, *change.Date, *change.Pounds, noteValue(change.Note)).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	if err := setWeightTags(ctx, b.tx, id, change.Tags); err != nil {
+		return err
+	}
+	if err := stampFields(ctx, b.tx, id, syncFields, at); err != nil {
+		return err
+	}
+
+	var w models.Weight
+	if err := scanWeight(b.tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", id), &w); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, b.tx, sourceOf(b.c), "weight", id, "create", nil, w); err != nil {
+		return err
+	}
+
+	b.publish(events.WeightCreated, w)
+	return b.checkGoal(id, w, before)
+}
+
+// update applies the fields of an offline edit that are later than the
+// server's, and reports the rest as conflicts
+func (b *syncBatch) update(index int, change models.SyncChange, old models.Weight, at time.Time) error {
+	ctx := b.c.Request.Context()
+
+	times, err := fieldTimes(ctx, b.tx, old.ID)
+	if err != nil {
+		return err
+	}
+
+	// Ties go to the server, which has already told other clients
+	var won, lost []string
+	for _, field := range setSyncFields(change) {
+		if at.After(times[field]) {
+			won = append(won, field)
+		} else {
+			lost = append(lost, field)
+		}
+	}
+
+	date, pounds, note, trashed := old.Date, old.Pounds, noteValue(old.Note), old.DeletedAt != nil
+	for _, field := range won {
+		switch field {
+		case "date":
+			date = *change.Date
+		case "pounds":
+			pounds = *change.Pounds
+		case "note":
+			note = noteValue(change.Note)
+		case "deleted":
+			trashed = *change.Deleted
+		}
+	}
+
+	if len(won) > 0 && !trashed {
+		if taken, err := dateTaken(ctx, b.tx, date, old.ID); err != nil || taken {
+			if taken {
+				b.reject(index, change, models.ErrorCodeDuplicateDate, "Weight entry already exists for this date", nil)
+			}
+			return err
+		}
+	}
+
+	if len(lost) > 0 {
+		b.response.Conflicts = append(b.response.Conflicts, models.SyncConflict{UUID: change.UUID, Fields: lost})
+	}
+	if len(won) == 0 {
+		return nil
+	}
+
+	before, err := selectLatestWeight(ctx, b.tx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE weights SET date = ?, pounds = ?, note = NULLIF(?, ''),
+	          deleted_at = CASE WHEN ? THEN COALESCE(deleted_at, ` + db.Now() + `) END,
+	          version = version + 1, updated_at = ` + db.Now() + `
+	          WHERE id = ? AND version = ?`
+	result, err := b.tx.ExecContext(ctx, query, date, pounds, note, trashed, old.ID, old.Version)
+	if err != nil {
+		return err
+	}
+
+	// Another request updated the entry between reading and writing it
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return db.ErrModified
+	}
+
+	for _, field := range won {
+		if field == "tags" {
+			if err := setWeightTags(ctx, b.tx, old.ID, change.Tags); err != nil {
+				return err
+			}
+		}
+	}
+	if err := stampFields(ctx, b.tx, old.ID, won, at); err != nil {
+		return err
+	}
+
+	var w models.Weight
+	if err := scanWeight(b.tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", old.ID), &w); err != nil {
+		return err
+	}
+
+	// Record the change as the REST request making it would have
+	wasTrashed := old.DeletedAt != nil
+	switch {
+	case !wasTrashed && trashed:
+		err = recordAudit(ctx, b.tx, sourceOf(b.c), "weight", old.ID, "delete", old, nil)
+		b.publish(events.WeightDeleted, gin.H{"id": old.ID})
+	case wasTrashed && !trashed:
+		err = recordAudit(ctx, b.tx, sourceOf(b.c), "weight", old.ID, "restore", old, w)
+		b.publish(events.WeightRestored, w)
+	default:
+		err = recordAudit(ctx, b.tx, sourceOf(b.c), "weight", old.ID, "update", old, w)
+		if !trashed {
+			b.publish(events.WeightUpdated, w)
+		}
+	}
+	if err != nil || trashed {
+		return err
+	}
+
+	return b.checkGoal(old.ID, w, before)
+}
+
+// checkGoal announces the goal being reached by a change to the weight
+// entry with the given id
+func (b *syncBatch) checkGoal(id int, w models.Weight, before *latestWeight) error {
+	goal, reached, err := goalReached(b.c.Request.Context(), b.tx, id, before)
+	if reached {
+		b.publish(events.GoalReached, gin.H{"goal_pounds": goal, "weight": w})
+	}
+	return err
+}
+
+// changes adds the entries changed after since, up to seq, to the
+// response, or every active entry on a reset
+func (b *syncBatch) changes(since, seq int64, reset bool) error {
+	ctx := b.c.Request.Context()
+
+	query := weightSelect() + " WHERE change_seq > ? AND change_seq <= ? ORDER BY change_seq"
+	args := []interface{}{since, seq}
+	if reset {
+		query = weightSelect() + " WHERE deleted_at IS NULL ORDER BY date"
+		args = nil
+	}
+
+	rows, err := b.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w models.Weight
+		if err := scanWeight(rows, &w); err != nil {
+			return err
+		}
+		b.response.Changes = append(b.response.Changes, w)
+	}
+	if err := rows.Err(); err != nil || reset {
+		return err
+	}
+
+	query = "SELECT uuid FROM weight_tombstones WHERE change_seq > ? AND change_seq <= ? ORDER BY change_seq"
+	tombstones, err := b.tx.QueryContext(ctx, query, since, seq)
+	if err != nil {
+		return err
+	}
+	defer tombstones.Close()
+
+	for tombstones.Next() {
+		var uuid string
+		if err := tombstones.Scan(&uuid); err != nil {
+			return err
+		}
+		b.response.Deleted = append(b.response.Deleted, uuid)
+	}
+	return tombstones.Err()
+}
+
+// reject reports a change that could not be applied
+func (b *syncBatch) reject(index int, change models.SyncChange, code, message string, fields []models.FieldError) {
+	b.response.Rejected = append(b.response.Rejected, models.SyncRejection{
+		Index:   index,
+		UUID:    change.UUID,
+		Code:    code,
+		Message: message,
+		Fields:  fields,
+	})
+}
+
+// publish queues an event for once the sync is committed
+func (b *syncBatch) publish(typ string, data interface{}) {
+	b.events = append(b.events, syncEvent{typ: typ, data: data})
+}
+
+// validateSyncChange returns every invalid field of a change
+func validateSyncChange(change models.SyncChange) []models.FieldError {
+	var fields []models.FieldError
+
+	var validationErrs validator.ValidationErrors
+	if err := binding.Validator.ValidateStruct(change); errors.As(err, &validationErrs) {
+		fields = fieldErrors(validationErrs)
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, change.UpdatedAt); err != nil && change.UpdatedAt != "" {
+		fields = append(fields, models.FieldError{
+			Field: "updated_at", Constraint: "datetime", Param: "RFC 3339", Message: "must be an RFC 3339 timestamp",
+		})
+	}
+
+	if change.Date != nil {
+		if err := validateDate(*change.Date); err != nil {
+			fields = append(fields, dateError("date", err))
+		}
+	}
+
+	return fields
+}
+
+// setSyncFields lists the fields set in a change
+func setSyncFields(change models.SyncChange) []string {
+	set := map[string]bool{
+		"date":    change.Date != nil,
+		"pounds":  change.Pounds != nil,
+		"note":    change.Note != nil,
+		"tags":    change.Tags != nil,
+		"deleted": change.Deleted != nil,
+	}
+
+	var fields []string
+	for _, field := range syncFields {
+		if set[field] {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// changedFields lists the sync fields that differ between two copies of a
+// weight entry
+func changedFields(old, w models.Weight) []string {
+	changed := map[string]bool{
+		"date":    old.Date != w.Date,
+		"pounds":  old.Pounds != w.Pounds,
+		"note":    noteValue(old.Note) != noteValue(w.Note),
+		"tags":    strings.Join(old.Tags, "\x00") != strings.Join(w.Tags, "\x00"),
+		"deleted": (old.DeletedAt == nil) != (w.DeletedAt == nil),
+	}
+
+	var fields []string
+	for _, field := range syncFields {
+		if changed[field] {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// stampFields records within tx that fields of the weight entry with the
+// given id changed at the given time, for resolving later sync conflicts
+func stampFields(ctx context.Context, tx *sql.Tx, id int, fields []string, at time.Time) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	sets := make([]string, len(fields))
+	args := make([]interface{}, 0, len(fields)+1)
+	for i, field := range fields {
+		sets[i] = field + "_changed_at = ?"
+		args = append(args, at.UTC().Format(time.RFC3339Nano))
+	}
+	args = append(args, id)
+
+	_, err := tx.ExecContext(ctx, "UPDATE weights SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
+	return err
+}
+
+// fieldTimes returns when each sync field of the weight entry with the
+// given id last changed. Fields without a recorded time, last changed
+// before sync existed, count as changed when the entry was last updated.
+func fieldTimes(ctx context.Context, tx *sql.Tx, id int) (map[string]time.Time, error) {
+	columns := []string{"updated_at"}
+	stamps := make([]sql.NullString, len(syncFields))
+	var updatedAt string
+	dest := []interface{}{&updatedAt}
+	for i, field := range syncFields {
+		columns = append(columns, field+"_changed_at")
+		dest = append(dest, &stamps[i])
+	}
+
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM weights WHERE id = ?"
+	if err := tx.QueryRowContext(ctx, query, id).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	updated, err := time.Parse("2006-01-02 15:04:05", updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[string]time.Time, len(syncFields))
+	for i, field := range syncFields {
+		times[field] = updated
+		if t, err := time.Parse(time.RFC3339Nano, stamps[i].String); stamps[i].Valid && err == nil {
+			times[field] = t
+		}
+	}
+	return times, nil
+}
+
+// dateTaken reports whether an active entry other than the one with the
+// given id is on date
+func dateTaken(ctx context.Context, tx *sql.Tx, date string, id int) (bool, error) {
+	var taken int
+	query := "SELECT 1 FROM weights WHERE date = ? AND deleted_at IS NULL AND id != ?"
+	err := tx.QueryRowContext(ctx, query, date, id).Scan(&taken)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
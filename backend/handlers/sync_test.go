@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+)
+
+const (
+	phoneEntry  = "5f0c3a9e-8d2b-4b71-9a57-0e6f1c2d3b4a"
+	tabletEntry = "9b1e2d3c-4f5a-4b6c-8d7e-0f1a2b3c4d5e"
+)
+
+// syncNow sends a sync request and returns the response
+func syncNow(t *testing.T, router *gin.Engine, body string) models.SyncResponse {
+	t.Helper()
+	w := serve(router, "POST", "/api/v1/sync", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response models.SyncResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// clientTime formats a time offset from now as a client would send it
+func clientTime(offset time.Duration) string {
+	return time.Now().Add(offset).UTC().Format(time.RFC3339Nano)
+}
+
+// syncedWeight finds the entry with the given UUID among changes
+func syncedWeight(t *testing.T, changes []models.Weight, uuid string) models.Weight {
+	t.Helper()
+	for _, w := range changes {
+		if w.UUID == uuid {
+			return w
+		}
+	}
+	t.Fatalf("Expected %s among %+v", uuid, changes)
+	return models.Weight{}
+}
+
+func TestSync_FirstAndIncremental(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	router := apiRouter()
+
+	serve(router, "POST", "/api/v1/weights", `{"date": "2026-01-01", "pounds": 172}`)
+
+	// A first sync creates the client's entries and returns everything
+	first := syncNow(t, router, `{"changes": [
+		{"uuid": "`+strings.ToUpper(phoneEntry)+`", "updated_at": "`+clientTime(-time.Hour)+`", "date": "2026-01-02", "pounds": 171.5, "note": "after run", "tags": ["morning"]}
+	]}`)
+	if !first.Reset || first.SyncToken == "" || len(first.Changes) != 2 || len(first.Rejected) != 0 {
+		t.Fatalf("Unexpected first sync %+v", first)
+	}
+	w := syncedWeight(t, first.Changes, phoneEntry)
+	if w.Pounds != 171.5 || w.Note == nil || *w.Note != "after run" || len(w.Tags) != 1 {
+		t.Errorf("Unexpected created entry %+v", w)
+	}
+	if first.Changes[0].UUID == "" {
+		t.Error("Expected entries created over REST to have a UUID")
+	}
+
+	// Nothing changed since
+	if again := syncNow(t, router, `{"sync_token": "`+first.SyncToken+`"}`); again.Reset || len(again.Changes) != 0 || again.SyncToken != first.SyncToken {
+		t.Errorf("Expected no changes, got %+v", again)
+	}
+
+	// Changes made elsewhere arrive once, including trashed and purged entries
+	serve(router, "PATCH", "/api/v1/weights/1", `{"pounds": 171}`)
+	serve(router, "POST", "/api/v1/weights", `{"date": "2026-01-03", "pounds": 170}`)
+	serve(router, "DELETE", "/api/v1/weights/3", "")
+	next := syncNow(t, router, `{"sync_token": "`+first.SyncToken+`"}`)
+	if next.Reset || len(next.Changes) != 2 || next.Changes[0].ID != 1 || next.Changes[1].DeletedAt == nil {
+		t.Errorf("Expected the update and the trashed entry, got %+v", next.Changes)
+	}
+
+	purged := next.Changes[1].UUID
+	if _, err := db.PurgeTrash(-time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	last := syncNow(t, router, `{"sync_token": "`+next.SyncToken+`"}`)
+	if len(last.Changes) != 0 || len(last.Deleted) != 1 || last.Deleted[0] != purged {
+		t.Errorf("Expected the purged entry to be listed as deleted, got %+v", last)
+	}
+
+	// Purged entries can't come back
+	gone := syncNow(t, router, `{"sync_token": "`+last.SyncToken+`", "changes": [
+		{"uuid": "`+purged+`", "updated_at": "`+clientTime(0)+`", "date": "2026-01-03", "pounds": 170}
+	]}`)
+	if len(gone.Rejected) != 1 || gone.Rejected[0].Code != models.ErrorCodeNotFound {
+		t.Errorf("Expected the purged entry to be rejected, got %+v", gone.Rejected)
+	}
+
+	// Renaming a tag changes the entries carrying it
+	serve(router, "PUT", "/api/v1/tags/1", `{"name": "Morning"}`)
+	renamed := syncNow(t, router, `{"sync_token": "`+gone.SyncToken+`"}`)
+	if len(renamed.Changes) != 1 || renamed.Changes[0].UUID != phoneEntry || renamed.Changes[0].Tags[0] != "Morning" {
+		t.Errorf("Expected the renamed tag's entry, got %+v", renamed.Changes)
+	}
+
+	// A token from ahead of the server starts over
+	if ahead := syncNow(t, router, `{"sync_token": "999999"}`); !ahead.Reset || len(ahead.Changes) != 2 {
+		t.Errorf("Expected a reset, got %+v", ahead)
+	}
+}
+
+func TestSync_LastWriterWinsPerField(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	router := apiRouter()
+
+	created := syncNow(t, router, `{"changes": [
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(-2*time.Hour)+`", "date": "2026-01-02", "pounds": 171, "note": "phone"}
+	]}`)
+	id := created.Changes[0].ID
+
+	// The server edits the note now, after the offline edit below was made
+	serve(router, "PATCH", "/api/v1/weights/"+strconv.Itoa(id), `{"note": "server"}`)
+
+	response := syncNow(t, router, `{"sync_token": "`+created.SyncToken+`", "changes": [
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(-time.Hour)+`", "pounds": 169.5, "note": "offline"}
+	]}`)
+	w := syncedWeight(t, response.Changes, phoneEntry)
+	if w.Pounds != 169.5 || w.Note == nil || *w.Note != "server" {
+		t.Errorf("Expected the offline pounds and the server's later note, got %+v", w)
+	}
+	if len(response.Conflicts) != 1 || response.Conflicts[0].UUID != phoneEntry ||
+		strings.Join(response.Conflicts[0].Fields, ",") != "note" {
+		t.Errorf("Expected a conflict on note, got %+v", response.Conflicts)
+	}
+
+	// An edit from a clock running ahead counts as made when it was synced,
+	// so it doesn't beat a later edit from another client
+	syncNow(t, router, `{"changes": [
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(24*time.Hour)+`", "tags": ["fast clock"]}
+	]}`)
+	response = syncNow(t, router, `{"changes": [
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(0)+`", "tags": ["tablet"]}
+	]}`)
+	if w := syncedWeight(t, response.Changes, phoneEntry); strings.Join(w.Tags, ",") != "tablet" {
+		t.Errorf("Expected the later edit to win, got %v", w.Tags)
+	}
+
+	// Changes are audited like REST requests
+	var count int
+	db.DB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE entity = 'weight' AND entity_id = ?", id).Scan(&count)
+	if count != 5 {
+		t.Errorf("Expected a create and 4 updates in the audit log, got %d", count)
+	}
+}
+
+func TestSync_DeleteAndRestore(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	router := apiRouter()
+
+	syncNow(t, router, `{"changes": [
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(-time.Hour)+`", "date": "2026-01-02", "pounds": 171},
+		{"uuid": "`+tabletEntry+`", "updated_at": "`+clientTime(-time.Hour)+`", "deleted": true}
+	]}`)
+
+	response := syncNow(t, router, `{"changes": [
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(0)+`", "deleted": true}
+	]}`)
+	if len(response.Changes) != 0 {
+		t.Errorf("Expected no active entries, got %+v", response.Changes)
+	}
+	if w := serve(router, "GET", "/api/v1/weights/trash", ""); !strings.Contains(w.Body.String(), phoneEntry) {
+		t.Errorf("Expected the entry in the trash, got %s", w.Body.String())
+	}
+
+	// Restoring onto a date taken in the meantime is rejected as a whole
+	serve(router, "POST", "/api/v1/weights", `{"date": "2026-01-02", "pounds": 170}`)
+	response = syncNow(t, router, `{"changes": [
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(0)+`", "deleted": false, "pounds": 168}
+	]}`)
+	if len(response.Rejected) != 1 || response.Rejected[0].Code != models.ErrorCodeDuplicateDate {
+		t.Fatalf("Expected a duplicate_date rejection, got %+v", response.Rejected)
+	}
+
+	response = syncNow(t, router, `{"changes": [
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(0)+`", "deleted": false, "date": "2026-01-01"}
+	]}`)
+	if w := syncedWeight(t, response.Changes, phoneEntry); w.DeletedAt != nil || w.Date != "2026-01-01" || w.Pounds != 171 {
+		t.Errorf("Expected the entry restored on the new date, got %+v", w)
+	}
+}
+
+func TestSync_Validation(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	router := apiRouter()
+
+	response := syncNow(t, router, `{"changes": [
+		{"uuid": "phone-1", "updated_at": "`+clientTime(0)+`", "date": "2026-01-02", "pounds": 171},
+		{"uuid": "`+phoneEntry+`", "updated_at": "yesterday", "date": "2026-01-02", "pounds": 171},
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(0)+`", "pounds": 171},
+		{"uuid": "`+phoneEntry+`", "updated_at": "`+clientTime(0)+`", "date": "2999-01-01", "pounds": -1},
+		{"uuid": "`+tabletEntry+`", "updated_at": "`+clientTime(0)+`", "date": "2026-01-02", "pounds": 171}
+	]}`)
+
+	want := []struct {
+		index  int
+		fields string
+	}{
+		{0, "uuid"},
+		{1, "updated_at"},
+		{2, "date"},
+		{3, "pounds,date"},
+	}
+	if len(response.Rejected) != len(want) {
+		t.Fatalf("Expected %d rejections, got %+v", len(want), response.Rejected)
+	}
+	for i, w := range want {
+		rejected := response.Rejected[i]
+		var fields []string
+		for _, f := range rejected.Fields {
+			fields = append(fields, f.Field)
+		}
+		if rejected.Index != w.index || rejected.Code != models.ErrorCodeValidationFailed || strings.Join(fields, ",") != w.fields {
+			t.Errorf("Expected change %d rejected on %s, got %+v", w.index, w.fields, rejected)
+		}
+	}
+
+	// The valid change still applied
+	if len(response.Changes) != 1 || response.Changes[0].UUID != tabletEntry {
+		t.Errorf("Expected only the valid change, got %+v", response.Changes)
+	}
+
+	for _, body := range []string{`{"sync_token": "abc"}`, `{"sync_token": "-1"}`, `{"changes": {}}`} {
+		if w := serve(router, "POST", "/api/v1/sync", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/events"
+	"github.com/sddev/weight-tracker/models"
+)
+
+const (
+	maxTagsPerWeight = 20
+	maxTagNameLength = 50
+)
+
+// tagSelect selects every column scanned into a models.Tag, counting only
+// active weight entries
+const tagSelect = `SELECT t.id, t.name, t.exclude_from_stats, (
+		SELECT COUNT(*) FROM weight_tags wt JOIN weights w ON w.id = wt.weight_id
+		WHERE wt.tag_id = t.id AND w.deleted_at IS NULL
+	), t.created_at FROM tags t`
+
+// GetTags retrieves all tags with the number of entries using each
+func GetTags(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	rows, err := db.ReadDB.QueryContext(ctx, tagSelect+" ORDER BY t.name")
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve tags")
+		return
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.ExcludeFromStats, &t.WeightCount, &t.CreatedAt); err != nil {
+			abortWithError(c, err, "Failed to scan tag")
+			return
+		}
+		tags = append(tags, t)
+	}
+
+	c.JSON(http.StatusOK, models.TagsResponse{Tags: tags})
+}
+
+// CreateTag creates a new tag
+func CreateTag(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input models.TagInput
+	if !bindTagInput(c, &input) {
+		return
+	}
+
+	query := "INSERT INTO tags (name, exclude_from_stats) VALUES (?, ?) RETURNING id"
+	var id int
+	err := db.DB.QueryRowContext(ctx, query, input.Name, input.ExcludeFromStats).Scan(&id)
+
+	if err != nil {
+		abortWithError(c, db.TagError(err), "Failed to create tag")
+		return
+	}
+
+	respondWithTag(c, http.StatusCreated, id)
+}
+
+// UpdateTag renames a tag or changes whether its entries count towards stats
+func UpdateTag(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortInvalidID(c, err, "Invalid tag ID")
+		return
+	}
+
+	var input models.TagInput
+	if !bindTagInput(c, &input) {
+		return
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, err, "Failed to update tag")
+		return
+	}
+	defer tx.Rollback()
+
+	var name string
+	if err := tx.QueryRowContext(ctx, "SELECT name FROM tags WHERE id = ?", id).Scan(&name); err != nil {
+		abortWithError(c, db.TagError(err), "Failed to retrieve tag")
+		return
+	}
+
+	// Renaming changes the tags of every entry carrying the tag
+	var tagged []models.Weight
+	if input.Name != name {
+		if tagged, err = taggedWeights(ctx, tx, id); err != nil {
+			abortWithError(c, err, "Failed to update tag")
+			return
+		}
+	}
+
+	query := "UPDATE tags SET name = ?, exclude_from_stats = ? WHERE id = ?"
+	if _, err := tx.ExecContext(ctx, query, input.Name, input.ExcludeFromStats, id); err != nil {
+		abortWithError(c, db.TagError(err), "Failed to update tag")
+		return
+	}
+
+	retagged, err := retagWeights(c, tx, tagged)
+	if err != nil {
+		abortWithError(c, err, "Failed to update tag")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, err, "Failed to update tag")
+		return
+	}
+	publishRetagged(retagged)
+
+	respondWithTag(c, http.StatusOK, id)
+}
+
+// DeleteTag deletes a tag and detaches it from every weight entry
+func DeleteTag(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortInvalidID(c, err, "Invalid tag ID")
+		return
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, err, "Failed to delete tag")
+		return
+	}
+	defer tx.Rollback()
+
+	tagged, err := taggedWeights(ctx, tx, id)
+	if err != nil {
+		abortWithError(c, err, "Failed to delete tag")
+		return
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM weight_tags WHERE tag_id = ?", id); err != nil {
+		abortWithError(c, err, "Failed to delete tag")
+		return
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		abortWithError(c, err, "Failed to delete tag")
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		abortWithError(c, db.ErrNotFound, "Tag not found")
+		return
+	}
+
+	retagged, err := retagWeights(c, tx, tagged)
+	if err != nil {
+		abortWithError(c, err, "Failed to delete tag")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, err, "Failed to delete tag")
+		return
+	}
+	publishRetagged(retagged)
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindTagInput binds and normalizes a tag input. It aborts with a 400
+// and returns false when the input is invalid.
+func bindTagInput(c *gin.Context, input *models.TagInput) bool {
+	if !bindJSON(c, input) {
+		return false
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		abortWithFields(c, invalid(nil), "Invalid request", []models.FieldError{
+			{Field: "name", Constraint: "required", Message: "must not be blank"},
+		})
+		return false
+	}
+
+	return true
+}
+
+// respondWithTag writes the tag with the given id
+func respondWithTag(c *gin.Context, status int, id int) {
+	ctx := c.Request.Context()
+
+	var t models.Tag
+	err := db.ReadDB.QueryRowContext(ctx, tagSelect+" WHERE t.id = ?", id).
+		Scan(&t.ID, &t.Name, &t.ExcludeFromStats, &t.WeightCount, &t.CreatedAt)
+
+	if err != nil {
+		abortWithError(c, db.TagError(err), "Failed to retrieve tag")
+		return
+	}
+
+	c.JSON(status, t)
+}
+
+// setWeightTags replaces the tags of a weight entry within tx, creating any
+// tags that don't exist yet
+func setWeightTags(ctx context.Context, tx *sql.Tx, weightID int, names []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM weight_tags WHERE weight_id = ?", weightID); err != nil {
+		return err
+	}
+
+	for _, name := range normalizeTags(names) {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING", name); err != nil {
+			return err
+		}
+
+		query := "INSERT INTO weight_tags (weight_id, tag_id) VALUES (?, (SELECT id FROM tags WHERE name = ?))"
+		if _, err := tx.ExecContext(ctx, query, weightID, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// taggedWeights returns within tx every weight entry carrying the tag with
+// the given id, trashed ones included
+func taggedWeights(ctx context.Context, tx *sql.Tx, tagID int) ([]models.Weight, error) {
+	query := weightSelect() + " WHERE id IN (SELECT weight_id FROM weight_tags WHERE tag_id = ?) ORDER BY id"
+	rows, err := tx.QueryContext(ctx, query, tagID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weights := []models.Weight{}
+	for rows.Next() {
+		var w models.Weight
+		if err := scanWeight(rows, &w); err != nil {
+			return nil, err
+		}
+		weights = append(weights, w)
+	}
+	return weights, rows.Err()
+}
+
+// retagWeights records within tx that renaming or deleting a tag changed
+// the tags of each entry in old, as read before the change. Like any other
+// update, each entry gets a new version and an audit entry; the updated
+// entries are returned for publishing once committed.
+func retagWeights(c *gin.Context, tx *sql.Tx, old []models.Weight) ([]models.Weight, error) {
+	ctx := c.Request.Context()
+	now := time.Now()
+
+	updated := make([]models.Weight, 0, len(old))
+	for _, o := range old {
+		query := "UPDATE weights SET version = version + 1, updated_at = " + db.Now() + " WHERE id = ?"
+		if _, err := tx.ExecContext(ctx, query, o.ID); err != nil {
+			return nil, err
+		}
+		if err := stampFields(ctx, tx, o.ID, []string{"tags"}, now); err != nil {
+			return nil, err
+		}
+
+		var w models.Weight
+		if err := scanWeight(tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", o.ID), &w); err != nil {
+			return nil, err
+		}
+		if err := recordAudit(ctx, tx, sourceOf(c), "weight", o.ID, "update", o, w); err != nil {
+			return nil, err
+		}
+		updated = append(updated, w)
+	}
+	return updated, nil
+}
+
+// publishRetagged publishes an update for each retagged entry not in the
+// trash
+func publishRetagged(weights []models.Weight) {
+	for _, w := range weights {
+		if w.DeletedAt == nil {
+			broker.Publish(events.WeightUpdated, w)
+		}
+	}
+}
+
+// normalizeTags trims tag names and drops blanks and case-insensitive
+// duplicates, keeping the first spelling
+func normalizeTags(names []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, name)
+	}
+
+	return normalized
+}
+
+// validTags reports whether names satisfies the per-entry tag limits
+func validTags(names []string) bool {
+	if len(names) > maxTagsPerWeight {
+		return false
+	}
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name == "" || utf8.RuneCountInString(name) > maxTagNameLength {
+			return false
+		}
+	}
+	return true
+}
+
+// taggedWith returns a subquery, correlated on weights.id, matching entries
+// that carry any of the given tag names
+func taggedWith(names []string) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	return `SELECT 1 FROM weight_tags wt JOIN tags t ON t.id = wt.tag_id
+		WHERE wt.weight_id = weights.id AND t.name IN (` + placeholders + `)`
+}
+
+// stringArgs converts strings to query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
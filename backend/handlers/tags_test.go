@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/events"
+	"github.com/sddev/weight-tracker/models"
+)
+
+func setupTagRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/weights", GetWeights)
+	router.POST("/weights", CreateWeight)
+	router.PUT("/weights/:id", UpdateWeight)
+	router.PATCH("/weights/:id", PatchWeight)
+	router.GET("/tags", GetTags)
+	router.POST("/tags", CreateTag)
+	router.PUT("/tags/:id", UpdateTag)
+	router.DELETE("/tags/:id", DeleteTag)
+	return router
+}
+
+func TestCreateWeight_WithNoteAndTags(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupTagRouter()
+
+	body := []byte(`{"date": "2026-01-15", "pounds": 170.5, "note": "after holiday", "tags": ["ill", " Post-Workout ", "ILL"]}`)
+	req, _ := http.NewRequest("POST", "/weights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var weight models.Weight
+	if err := json.Unmarshal(w.Body.Bytes(), &weight); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if weight.Note == nil || *weight.Note != "after holiday" {
+		t.Errorf("Expected note 'after holiday', got %v", weight.Note)
+	}
+	if len(weight.Tags) != 2 || weight.Tags[0] != "ill" || weight.Tags[1] != "Post-Workout" {
+		t.Errorf("Expected tags [ill Post-Workout], got %v", weight.Tags)
+	}
+}
+
+func TestUpdateWeight_KeepsNoteAndTagsWhenOmitted(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupTagRouter()
+
+	body := []byte(`{"date": "2026-01-15", "pounds": 170.5, "note": "new scale", "tags": ["scale"]}`)
+	req, _ := http.NewRequest("POST", "/weights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	body = []byte(`{"date": "2026-01-15", "pounds": 169.0}`)
+	req, _ = http.NewRequest("PUT", "/weights/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var weight models.Weight
+	json.Unmarshal(w.Body.Bytes(), &weight)
+
+	if weight.Note == nil || *weight.Note != "new scale" {
+		t.Errorf("Expected note to be kept, got %v", weight.Note)
+	}
+	if len(weight.Tags) != 1 {
+		t.Errorf("Expected tags to be kept, got %v", weight.Tags)
+	}
+
+	// PATCH null clears both
+	body = []byte(`{"note": null, "tags": null}`)
+	req, _ = http.NewRequest("PATCH", "/weights/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	weight = models.Weight{}
+	json.Unmarshal(w.Body.Bytes(), &weight)
+
+	if weight.Note != nil {
+		t.Errorf("Expected note to be cleared, got %v", *weight.Note)
+	}
+	if len(weight.Tags) != 0 {
+		t.Errorf("Expected tags to be cleared, got %v", weight.Tags)
+	}
+}
+
+func TestGetWeights_FilterByTag(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupTagRouter()
+
+	entries := []string{
+		`{"date": "2026-01-01", "pounds": 170, "tags": ["ill"]}`,
+		`{"date": "2026-01-02", "pounds": 171, "tags": ["unreliable"]}`,
+		`{"date": "2026-01-03", "pounds": 172}`,
+	}
+	for _, body := range entries {
+		req, _ := http.NewRequest("POST", "/weights", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?tag=ill", 1},
+		{"?tag=ILL&tag=unreliable", 2},
+		{"?exclude_tag=unreliable", 2},
+		{"", 3},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/weights"+tt.query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response models.WeightsResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		if len(response.Weights) != tt.want {
+			t.Errorf("GET /weights%s: expected %d entries, got %d", tt.query, tt.want, len(response.Weights))
+		}
+	}
+}
+
+func TestTagCRUD(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupTagRouter()
+
+	req, _ := http.NewRequest("POST", "/tags", bytes.NewBufferString(`{"name": "unreliable", "exclude_from_stats": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var tag models.Tag
+	json.Unmarshal(w.Body.Bytes(), &tag)
+	if !tag.ExcludeFromStats {
+		t.Error("Expected exclude_from_stats to be set")
+	}
+
+	// Duplicate names are rejected case-insensitively
+	req, _ = http.NewRequest("POST", "/tags", bytes.NewBufferString(`{"name": "Unreliable"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for duplicate tag, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("PUT", "/tags/1", bytes.NewBufferString(`{"name": "bad scale", "exclude_from_stats": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	json.Unmarshal(w.Body.Bytes(), &tag)
+	if w.Code != http.StatusOK || tag.Name != "bad scale" {
+		t.Errorf("Expected renamed tag, got status %d name %s", w.Code, tag.Name)
+	}
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170)")
+	db.DB.Exec("INSERT INTO weight_tags (weight_id, tag_id) VALUES (1, 1)")
+
+	req, _ = http.NewRequest("DELETE", "/tags/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+
+	var count int
+	db.DB.QueryRow("SELECT COUNT(*) FROM weight_tags").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected tag to be detached from entries, got %d links", count)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/tags/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestPatchWeight_TagLengthCountsCharacters(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupTagRouter()
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170)")
+
+	tests := []struct {
+		tag  string
+		want int
+	}{
+		{strings.Repeat("é", 30), http.StatusOK},
+		{strings.Repeat("é", 50), http.StatusOK},
+		{strings.Repeat("é", 51), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("PATCH", "/weights/1", bytes.NewBufferString(`{"tags": ["`+tt.tag+`"]}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%d-character tag: expected status %d, got %d. Body: %s", len([]rune(tt.tag)), tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTagRenameAndDelete_UpdateEntries(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	router := setupTagRouter()
+
+	req, _ := http.NewRequest("POST", "/weights", bytes.NewBufferString(`{"date": "2026-01-15", "pounds": 170.5, "tags": ["morning"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	sub := broker.Subscribe()
+	defer sub.Close()
+
+	steps := []struct {
+		method string
+		body   string
+		tags   string
+	}{
+		{"PUT", `{"name": "Morning"}`, "Morning"},
+		{"DELETE", "", ""},
+	}
+	for i, step := range steps {
+		req, _ := http.NewRequest(step.method, "/tags/1", bytes.NewBufferString(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s: expected success, got %d. Body: %s", step.method, w.Code, w.Body.String())
+		}
+
+		// The entry's tags changed like any other update: a new version, an
+		// audit entry and an update event
+		var version, audited int
+		db.DB.QueryRow("SELECT version FROM weights WHERE id = 1").Scan(&version)
+		db.DB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE entity = 'weight' AND action = 'update'").Scan(&audited)
+		if version != i+2 || audited != i+1 {
+			t.Errorf("%s: expected version %d and %d audited updates, got %d and %d", step.method, i+2, i+1, version, audited)
+		}
+
+		select {
+		case event := <-sub.C:
+			var weight models.Weight
+			json.Unmarshal(event.Data, &weight)
+			if event.Type != events.WeightUpdated || weight.Version != version || strings.Join(weight.Tags, ",") != step.tags {
+				t.Errorf("%s: unexpected event %s %s", step.method, event.Type, event.Data)
+			}
+		default:
+			t.Errorf("%s: expected an update event", step.method)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/events"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// GetTrash retrieves all trashed weight entries, most recently deleted first
+func GetTrash(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	query := weightSelect() + " WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC"
+
+	rows, err := db.ReadDB.QueryContext(ctx, query)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve trash")
+		return
+	}
+	defer rows.Close()
+
+	weights := []models.Weight{}
+	for rows.Next() {
+		var w models.Weight
+		if err := scanWeight(rows, &w); err != nil {
+			abortWithError(c, err, "Failed to scan weight entry")
+			return
+		}
+		weights = append(weights, w)
+	}
+
+	c.JSON(http.StatusOK, models.WeightsResponse{Weights: weights})
+}
+
+// RestoreWeight moves a trashed weight entry back into the active list
+func RestoreWeight(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortInvalidID(c, err, "Invalid weight ID")
+		return
+	}
+
+	var version int
+	query := "SELECT version FROM weights WHERE id = ? AND deleted_at IS NOT NULL"
+	err = db.WeightError(db.DB.QueryRowContext(ctx, query, id).Scan(&version))
+
+	if errors.Is(err, db.ErrNotFound) {
+		abortWithError(c, db.ErrNotFound, "Weight entry not found in trash")
+		return
+	}
+
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve weight entry")
+		return
+	}
+
+	if !checkIfMatch(c, version) {
+		return
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, err, "Failed to restore weight entry")
+		return
+	}
+	defer tx.Rollback()
+
+	var old models.Weight
+	if err := scanWeight(tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", id), &old); err != nil {
+		abortWithError(c, err, "Failed to retrieve weight entry")
+		return
+	}
+
+	query = `UPDATE weights SET deleted_at = NULL, version = version + 1, updated_at = ` + db.Now() + `
+	         WHERE id = ? AND version = ? AND deleted_at IS NOT NULL`
+	result, err := tx.ExecContext(ctx, query, id, version)
+
+	if err != nil {
+		abortWithError(c, db.WeightError(err), "Failed to restore weight entry")
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		abortWithError(c, db.ErrModified, "Resource has been modified")
+		return
+	}
+
+	if err := stampFields(ctx, tx, id, []string{"deleted"}, time.Now()); err != nil {
+		abortWithError(c, err, "Failed to restore weight entry")
+		return
+	}
+
+	// Retrieve the restored entry
+	var w models.Weight
+	err = scanWeight(tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", id), &w)
+
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve restored weight entry")
+		return
+	}
+
+	if err := recordAudit(ctx, tx, sourceOf(c), "weight", id, "restore", old, w); err != nil {
+		abortWithError(c, err, "Failed to record change")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, err, "Failed to restore weight entry")
+		return
+	}
+	broker.Publish(events.WeightRestored, w)
+
+	c.Header("ETag", versionETag(w.Version))
+	c.JSON(http.StatusOK, w)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+)
+
+func setupTrashRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/weights", GetWeights)
+	router.GET("/weights/trash", GetTrash)
+	router.GET("/weights/:id", GetWeight)
+	router.DELETE("/weights/:id", DeleteWeight)
+	router.POST("/weights/:id/restore", RestoreWeight)
+	return router
+}
+
+func TestDeleteWeight_MovesToTrash(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-15', 170.5)")
+	router := setupTrashRouter()
+
+	req, _ := http.NewRequest("DELETE", "/weights/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	// Hidden from reads
+	req, _ = http.NewRequest("GET", "/weights/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for trashed entry, got %d", w.Code)
+	}
+
+	// Listed in the trash
+	req, _ = http.NewRequest("GET", "/weights/trash", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response models.WeightsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if len(response.Weights) != 1 {
+		t.Fatalf("Expected 1 weight in trash, got %d", len(response.Weights))
+	}
+	if response.Weights[0].DeletedAt == nil {
+		t.Error("Expected deleted_at to be set on trashed entry")
+	}
+}
+
+func TestDeleteWeight_FreesDate(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds, deleted_at) VALUES ('2026-01-15', 170.5, " + db.Now() + ")")
+
+	// A trashed entry must not block a new entry on the same date
+	if _, err := db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-15', 169.0)"); err != nil {
+		t.Errorf("Expected insert on a trashed date to succeed, got %v", err)
+	}
+}
+
+func TestRestoreWeight_Success(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds, deleted_at) VALUES ('2026-01-15', 170.5, " + db.Now() + ")")
+	router := setupTrashRouter()
+
+	req, _ := http.NewRequest("POST", "/weights/1/restore", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var weight models.Weight
+	if err := json.Unmarshal(w.Body.Bytes(), &weight); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if weight.DeletedAt != nil {
+		t.Error("Expected deleted_at to be cleared")
+	}
+
+	req, _ = http.NewRequest("GET", "/weights/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected restored entry to be readable, got %d", w.Code)
+	}
+}
+
+func TestRestoreWeight_DateTaken(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds, deleted_at) VALUES ('2026-01-15', 170.5, " + db.Now() + ")")
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-15', 169.0)")
+	router := setupTrashRouter()
+
+	req, _ := http.NewRequest("POST", "/weights/1/restore", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
+
+func TestRestoreWeight_NotInTrash(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-15', 170.5)")
+	router := setupTrashRouter()
+
+	req, _ := http.NewRequest("POST", "/weights/1/restore", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
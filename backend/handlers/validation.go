@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// errFutureDate rejects dates after today (UTC)
+var errFutureDate = errors.New("date must not be in the future")
+
+func init() {
+	// Report fields by their JSON names rather than Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// jsonFieldName returns the JSON member name of a struct field
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// bindJSON binds the request body into obj. It aborts with a 400 listing
+// every invalid field, or malformed_body if the body isn't JSON of the right
+// shape, and returns false when binding fails.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := bindingError(c.ShouldBindJSON(obj)); err != nil {
+		abortWithOperation(c, err)
+		return false
+	}
+	return true
+}
+
+// bindBody binds a JSON body sent other than as a request body, such as in
+// a live sync frame, exactly as bindJSON would
+func bindBody(body []byte, obj interface{}) error {
+	return bindingError(binding.JSON.BindBody(body, obj))
+}
+
+// bindingError describes an error from binding a JSON body: a 400 listing
+// every invalid field, or malformed_body if the body isn't JSON of the right
+// shape. It returns nil for a nil err.
+func bindingError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		return failedFields(invalid(err), "Invalid request", fieldErrors(validationErrs))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return failedFields(invalid(err), "Invalid request", []models.FieldError{
+			typeError(typeErr.Field, jsonTypeName(typeErr.Type.Kind())),
+		})
+	}
+	return malformedBody(err)
+}
+
+// malformedBody returns the malformed_body error for a body that isn't JSON
+// of the right shape
+func malformedBody(err error) error {
+	return &operationError{err: invalid(err), meta: errorMeta{
+		code:    models.ErrorCodeMalformedBody,
+		message: "Request body must be a JSON object",
+	}}
+}
+
+// fieldErrors reports every failed validator constraint
+func fieldErrors(validationErrs validator.ValidationErrors) []models.FieldError {
+	fields := make([]models.FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fields = append(fields, models.FieldError{
+			Field:      fe.Field(),
+			Constraint: fe.Tag(),
+			Param:      fe.Param(),
+			Message:    constraintMessage(fe),
+		})
+	}
+	return fields
+}
+
+// constraintMessage describes a failed validator constraint
+func constraintMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "min":
+		switch fe.Kind() {
+		case reflect.String:
+			return "must be at least " + fe.Param() + " characters"
+		case reflect.Slice, reflect.Array:
+			return "must have at least " + fe.Param() + " items"
+		}
+		return "must be at least " + fe.Param()
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "url":
+		return "must be a URL"
+	case "uuid":
+		return "must be a UUID"
+	case "max":
+		switch fe.Kind() {
+		case reflect.String:
+			return "must be at most " + fe.Param() + " characters"
+		case reflect.Slice, reflect.Array:
+			return "must have at most " + fe.Param() + " items"
+		}
+		return "must be at most " + fe.Param()
+	}
+	if fe.Param() != "" {
+		return "must satisfy " + fe.Tag() + "=" + fe.Param()
+	}
+	return "must satisfy " + fe.Tag()
+}
+
+// jsonTypeName names the JSON type that decodes into a Go kind
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return kind.String()
+}
+
+// typeError reports a field holding the wrong JSON type
+func typeError(field, jsonType string) models.FieldError {
+	article := "a "
+	if jsonType == "array" || jsonType == "object" || jsonType == "integer" {
+		article = "an "
+	}
+	return models.FieldError{Field: field, Constraint: "type", Param: jsonType, Message: "must be " + article + jsonType}
+}
+
+// dateError reports a date field that validateDate rejected
+func dateError(field string, err error) models.FieldError {
+	if errors.Is(err, errFutureDate) {
+		return models.FieldError{Field: field, Constraint: "not_future", Message: "must not be in the future"}
+	}
+	return models.FieldError{Field: field, Constraint: "date", Param: "YYYY-MM-DD", Message: "must be a valid date in YYYY-MM-DD format"}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// sendJSON sends body to handler and decodes the error response
+func sendJSON(t *testing.T, method, path, route string, handler gin.HandlerFunc, body string) (int, models.ErrorResponse) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Handle(method, route, handler)
+
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func TestCreateWeight_FieldErrors(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	status, response := sendJSON(t, "POST", "/weights", "/weights", CreateWeight,
+		`{"pounds": -1, "note": "`+strings.Repeat("x", 1001)+`"}`)
+
+	if status != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", status)
+	}
+	if response.Code != models.ErrorCodeValidationFailed {
+		t.Errorf("Expected code validation_failed, got %q", response.Code)
+	}
+
+	want := []models.FieldError{
+		{Field: "date", Constraint: "required", Message: "is required"},
+		{Field: "pounds", Constraint: "gt", Param: "0", Message: "must be greater than 0"},
+		{Field: "note", Constraint: "max", Param: "1000", Message: "must be at most 1000 characters"},
+	}
+	if !reflect.DeepEqual(response.Fields, want) {
+		t.Errorf("Expected fields %+v, got %+v", want, response.Fields)
+	}
+}
+
+func TestCreateWeight_TypeAndBodyErrors(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	status, response := sendJSON(t, "POST", "/weights", "/weights", CreateWeight, `{"date": "2026-01-01", "pounds": "heavy"}`)
+	if status != http.StatusBadRequest || len(response.Fields) != 1 {
+		t.Fatalf("Expected one field error, got %d %+v", status, response)
+	}
+	if got := response.Fields[0]; got.Field != "pounds" || got.Constraint != "type" || got.Param != "number" {
+		t.Errorf("Expected pounds type error, got %+v", got)
+	}
+
+	status, response = sendJSON(t, "POST", "/weights", "/weights", CreateWeight, `{"date":`)
+	if status != http.StatusBadRequest || response.Code != models.ErrorCodeMalformedBody {
+		t.Errorf("Expected malformed_body, got %d %q", status, response.Code)
+	}
+}
+
+func TestCreateWeight_FutureDateField(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	status, response := sendJSON(t, "POST", "/weights", "/weights", CreateWeight, `{"date": "2999-01-01", "pounds": 170}`)
+	if status != http.StatusBadRequest || len(response.Fields) != 1 {
+		t.Fatalf("Expected one field error, got %d %+v", status, response)
+	}
+	if got := response.Fields[0]; got.Field != "date" || got.Constraint != "not_future" {
+		t.Errorf("Expected date not_future error, got %+v", got)
+	}
+}
+
+func TestPatchWeight_FieldErrorsSorted(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES (?, ?)", "2026-01-01", 170.0)
+
+	status, response := sendJSON(t, "PATCH", "/weights/1", "/weights/:id", PatchWeight,
+		`{"pounds": 0, "id": 5, "date": "yesterday"}`)
+	if status != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", status)
+	}
+
+	var got []string
+	for _, field := range response.Fields {
+		got = append(got, field.Field+":"+field.Constraint)
+	}
+	want := []string{"date:date", "id:read_only", "pounds:gt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected fields %v, got %v", want, got)
+	}
+}
+
+func TestInvalidID_Code(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	status, response := sendJSON(t, "GET", "/weights/abc", "/weights/:id", GetWeight, "")
+	if status != http.StatusBadRequest || response.Code != models.ErrorCodeInvalidID {
+		t.Errorf("Expected 400 invalid_id, got %d %q", status, response.Code)
+	}
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+	"github.com/sddev/weight-tracker/webhooks"
+)
+
+// webhookSelect selects every column scanned by scanWebhook
+const webhookSelect = "SELECT id, url, events, active, created_at, updated_at FROM webhooks"
+
+// dispatcher delivers change events to webhooks once StartWebhooks is
+// called; tests are sent whether or not it is
+var dispatcher = webhooks.New(webhooks.DefaultOptions())
+
+// StartWebhooks starts delivering change events to webhooks
+func StartWebhooks() {
+	dispatcher.Start(broker)
+}
+
+// CloseWebhooks stops delivering to webhooks, leaving deliveries still to
+// be retried for the next start
+func CloseWebhooks() {
+	dispatcher.Close()
+}
+
+// scanWebhook scans a row selected with webhookSelect into w
+func scanWebhook(row rowScanner, w *models.Webhook) error {
+	var events string
+	if err := row.Scan(&w.ID, &w.URL, &events, &w.Active, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return err
+	}
+	w.Events = strings.Split(events, ",")
+	return nil
+}
+
+// GetWebhooks retrieves every webhook
+func GetWebhooks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	rows, err := db.ReadDB.QueryContext(ctx, webhookSelect+" ORDER BY id")
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve webhooks")
+		return
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		var w models.Webhook
+		if err := scanWebhook(rows, &w); err != nil {
+			abortWithError(c, err, "Failed to scan webhook")
+			return
+		}
+		hooks = append(hooks, w)
+	}
+
+	c.JSON(http.StatusOK, models.WebhooksResponse{Webhooks: hooks})
+}
+
+// GetWebhook retrieves a single webhook
+func GetWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	respondWithWebhook(c, http.StatusOK, id)
+}
+
+// CreateWebhook subscribes a URL to change events
+func CreateWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input models.WebhookInput
+	if !bindWebhookInput(c, &input) {
+		return
+	}
+
+	if input.Secret == "" {
+		abortWithFields(c, invalid(nil), "Invalid request", []models.FieldError{
+			{Field: "secret", Constraint: "required", Message: "is required"},
+		})
+		return
+	}
+
+	query := "INSERT INTO webhooks (url, events, secret, active) VALUES (?, ?, ?, ?) RETURNING id"
+	var id int
+	err := db.DB.QueryRowContext(ctx, query, input.URL, strings.Join(input.Events, ","), input.Secret, webhookActive(input)).
+		Scan(&id)
+
+	if err != nil {
+		abortWithError(c, err, "Failed to create webhook")
+		return
+	}
+
+	respondWithWebhook(c, http.StatusCreated, id)
+}
+
+// UpdateWebhook replaces a webhook's URL, events and active flag, and its
+// secret if one is given
+func UpdateWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	var input models.WebhookInput
+	if !bindWebhookInput(c, &input) {
+		return
+	}
+
+	query := `UPDATE webhooks SET url = ?, events = ?, secret = COALESCE(NULLIF(?, ''), secret), active = ?,
+	          updated_at = ` + db.Now() + ` WHERE id = ?`
+	result, err := db.DB.ExecContext(ctx, query, input.URL, strings.Join(input.Events, ","), input.Secret,
+		webhookActive(input), id)
+
+	if err != nil {
+		abortWithError(c, err, "Failed to update webhook")
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		abortWithError(c, db.ErrNotFound, "Webhook not found")
+		return
+	}
+
+	respondWithWebhook(c, http.StatusOK, id)
+}
+
+// DeleteWebhook deletes a webhook and its delivery log
+func DeleteWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, err, "Failed to delete webhook")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		abortWithError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		abortWithError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		abortWithError(c, db.ErrNotFound, "Webhook not found")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries retrieves a page of a webhook's delivery log
+func GetWebhookDeliveries(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	var exists int
+	if err := db.ReadDB.QueryRowContext(ctx, "SELECT 1 FROM webhooks WHERE id = ?", id).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			err = db.ErrNotFound
+		}
+		abortWithError(c, err, "Webhook not found")
+		return
+	}
+
+	deliveries, total, err := webhooks.Deliveries(ctx, id, limit, offset)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve deliveries")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WebhookDeliveriesResponse{
+		Deliveries: deliveries,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+	})
+}
+
+// TestWebhook sends a ping to a webhook and responds with the delivery,
+// whether or not the receiver accepted it
+func TestWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := dispatcher.Test(c.Request.Context(), id)
+	if errors.Is(err, db.ErrNotFound) {
+		abortWithError(c, err, "Webhook not found")
+		return
+	}
+	if err != nil {
+		abortWithError(c, err, "Failed to send test delivery")
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// webhookID parses the webhook ID path parameter. It aborts with a 400 and
+// returns false when it is invalid.
+func webhookID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortInvalidID(c, err, "Invalid webhook ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// bindWebhookInput binds and normalizes a webhook input. It aborts with a
+// 400 and returns false when the input is invalid.
+func bindWebhookInput(c *gin.Context, input *models.WebhookInput) bool {
+	if !bindJSON(c, input) {
+		return false
+	}
+
+	if u, err := url.Parse(input.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		abortWithFields(c, invalid(err), "Invalid request", []models.FieldError{
+			{Field: "url", Constraint: "url", Param: "http https", Message: "must be an http or https URL"},
+		})
+		return false
+	}
+
+	// Drop repeated events, keeping the order given
+	seen := map[string]bool{}
+	events := input.Events[:0]
+	for _, event := range input.Events {
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	input.Events = events
+
+	return true
+}
+
+// webhookActive returns whether the input's webhook is active, by default
+// true
+func webhookActive(input models.WebhookInput) bool {
+	return input.Active == nil || *input.Active
+}
+
+// respondWithWebhook writes the webhook with the given id
+func respondWithWebhook(c *gin.Context, status int, id int) {
+	ctx := c.Request.Context()
+
+	var w models.Webhook
+	err := scanWebhook(db.ReadDB.QueryRowContext(ctx, webhookSelect+" WHERE id = ?", id), &w)
+
+	if err == sql.ErrNoRows {
+		abortWithError(c, db.ErrNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve webhook")
+		return
+	}
+
+	c.JSON(status, w)
+}
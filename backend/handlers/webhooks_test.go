@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/models"
+	"github.com/sddev/weight-tracker/webhooks"
+)
+
+// webhookReceiver records the payloads posted to it
+func webhookReceiver(t *testing.T) (*httptest.Server, chan webhooks.Payload) {
+	received := make(chan webhooks.Payload, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !webhooks.Verify("0123456789abcdef", r.Header.Get(webhooks.TimestampHeader), body, r.Header.Get(webhooks.SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload webhooks.Payload
+		json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// startWebhooks delivers events to webhooks for the rest of the test
+func startWebhooks(t *testing.T) {
+	previous := dispatcher
+	dispatcher = webhooks.New(webhooks.Options{
+		MaxAttempts: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, Timeout: time.Second, Concurrency: 1,
+	})
+	StartWebhooks()
+	t.Cleanup(func() {
+		CloseWebhooks()
+		dispatcher = previous
+	})
+}
+
+func TestWebhooks_CRUD(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	router := apiRouter()
+
+	w := serve(router, "POST", "/api/v1/webhooks",
+		`{"url": "https://example.com/hook", "events": ["weight.created", "goal.reached", "weight.created"], "secret": "0123456789abcdef"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "0123456789abcdef") {
+		t.Errorf("Expected the secret not to be returned, got %s", w.Body.String())
+	}
+	var hook models.Webhook
+	json.Unmarshal(w.Body.Bytes(), &hook)
+	if hook.URL != "https://example.com/hook" || strings.Join(hook.Events, ",") != "weight.created,goal.reached" || !hook.Active {
+		t.Errorf("Unexpected webhook %+v", hook)
+	}
+
+	// Updating without a secret keeps it
+	w = serve(router, "PUT", "/api/v1/webhooks/1", `{"url": "http://192.168.1.10:8123/api/webhook/weigh-in", "events": ["weight.deleted"], "active": false}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var secret string
+	db.DB.QueryRow("SELECT secret FROM webhooks WHERE id = 1").Scan(&secret)
+	if secret != "0123456789abcdef" {
+		t.Errorf("Expected the secret to be kept, got %q", secret)
+	}
+
+	w = serve(router, "GET", "/api/v1/webhooks", "")
+	var list models.WebhooksResponse
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Webhooks) != 1 || list.Webhooks[0].Active || list.Webhooks[0].Events[0] != "weight.deleted" {
+		t.Errorf("Unexpected webhooks %+v", list)
+	}
+
+	if w := serve(router, "DELETE", "/api/v1/webhooks/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w := serve(router, "GET", "/api/v1/webhooks/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after deletion, got %d", w.Code)
+	}
+}
+
+func TestWebhooks_Validation(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	router := apiRouter()
+
+	tests := []struct {
+		name, body, field, constraint string
+	}{
+		{"missing secret", `{"url": "https://example.com", "events": ["weight.created"]}`, "secret", "required"},
+		{"short secret", `{"url": "https://example.com", "events": ["weight.created"], "secret": "short"}`, "secret", "min"},
+		{"no events", `{"url": "https://example.com", "events": [], "secret": "0123456789abcdef"}`, "events", "min"},
+		{"unknown event", `{"url": "https://example.com", "events": ["weight.eaten"], "secret": "0123456789abcdef"}`, "events[0]", "oneof"},
+		{"not a URL", `{"url": "example", "events": ["weight.created"], "secret": "0123456789abcdef"}`, "url", "url"},
+		{"not http", `{"url": "ftp://example.com", "events": ["weight.created"], "secret": "0123456789abcdef"}`, "url", "url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, "POST", "/api/v1/webhooks", tt.body)
+			var response models.ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if w.Code != http.StatusBadRequest || len(response.Fields) != 1 ||
+				response.Fields[0].Field != tt.field || response.Fields[0].Constraint != tt.constraint {
+				t.Errorf("Expected %s to fail %s, got %d %+v", tt.field, tt.constraint, w.Code, response.Fields)
+			}
+		})
+	}
+
+	if w := serve(router, "PUT", "/api/v1/webhooks/9", `{"url": "https://example.com", "events": ["weight.created"]}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown webhook, got %d", w.Code)
+	}
+}
+
+func TestWebhooks_TestAndDeliveries(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	router := apiRouter()
+	receiver, received := webhookReceiver(t)
+
+	serve(router, "POST", "/api/v1/webhooks",
+		`{"url": "`+receiver.URL+`", "events": ["weight.created"], "secret": "0123456789abcdef", "active": false}`)
+
+	w := serve(router, "POST", "/api/v1/webhooks/1/test", "")
+	var delivery models.WebhookDelivery
+	json.Unmarshal(w.Body.Bytes(), &delivery)
+	if w.Code != http.StatusOK || delivery.Event != webhooks.Ping || delivery.Status != webhooks.StatusSucceeded {
+		t.Fatalf("Expected a successful ping, got %d %s", w.Code, w.Body.String())
+	}
+	if payload := <-received; payload.Event != webhooks.Ping || string(payload.Data) != `{"webhook_id":1}` {
+		t.Errorf("Unexpected ping %+v", payload)
+	}
+
+	w = serve(router, "GET", "/api/v1/webhooks/1/deliveries", "")
+	var log models.WebhookDeliveriesResponse
+	json.Unmarshal(w.Body.Bytes(), &log)
+	if log.Total != 1 || log.Deliveries[0].ID != delivery.ID || log.Deliveries[0].Attempts != 1 {
+		t.Errorf("Expected the ping in the delivery log, got %+v", log)
+	}
+
+	for _, path := range []string{"/api/v1/webhooks/9/test", "/api/v1/webhooks/9/deliveries"} {
+		method := "GET"
+		if strings.HasSuffix(path, "test") {
+			method = "POST"
+		}
+		if w := serve(router, method, path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestWebhooks_DeliverChanges(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	router := apiRouter()
+	receiver, received := webhookReceiver(t)
+	startWebhooks(t)
+
+	serve(router, "POST", "/api/v1/webhooks",
+		`{"url": "`+receiver.URL+`", "events": ["weight.created", "weight.updated", "weight.deleted", "goal.reached"], "secret": "0123456789abcdef"}`)
+	serve(router, "PUT", "/api/v1/goal", `{"pounds": 160}`)
+
+	// Losing towards the goal: reaching it from above is announced once
+	serve(router, "POST", "/api/v1/weights", `{"date": "2026-01-01", "pounds": 165}`)
+	serve(router, "POST", "/api/v1/weights", `{"date": "2026-01-02", "pounds": 162}`)
+	serve(router, "POST", "/api/v1/weights", `{"date": "2026-01-03", "pounds": 159.5}`)
+	serve(router, "POST", "/api/v1/weights", `{"date": "2026-01-04", "pounds": 159}`)
+	// Backdated entries aren't the latest, and moving away isn't reaching
+	serve(router, "POST", "/api/v1/weights", `{"date": "2025-12-31", "pounds": 170}`)
+	serve(router, "PATCH", "/api/v1/weights/4", `{"pounds": 161}`)
+	serve(router, "DELETE", "/api/v1/weights/4", "")
+
+	want := []string{
+		"weight.created", "weight.created", "weight.created", "goal.reached",
+		"weight.created", "weight.created", "weight.updated", "weight.deleted",
+	}
+	var got []string
+	for range want {
+		select {
+		case payload := <-received:
+			got = append(got, payload.Event)
+			if payload.Event == "goal.reached" && !strings.Contains(string(payload.Data), `"goal_pounds":160`) {
+				t.Errorf("Expected the goal in %s", payload.Data)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out after %v", got)
+		}
+	}
+
+	// Deliveries run concurrently, so compare the events regardless of order
+	count := map[string]int{}
+	for _, event := range want {
+		count[event]++
+	}
+	for _, event := range got {
+		count[event]--
+	}
+	for event, n := range count {
+		if n != 0 {
+			t.Errorf("Expected %v, got %v (%s off by %d)", want, got, event, n)
+		}
+	}
+}
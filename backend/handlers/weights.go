@@ -1,43 +1,81 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
+	"encoding/json"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/events"
 	"github.com/sddev/weight-tracker/models"
 )
 
-// GetWeights retrieves all weight entries with optional date filtering
+// weightSelect selects every column scanned by scanWeight, including the
+// entry's tag names as a JSON array
+func weightSelect() string {
+	return `SELECT id, uuid, date, pounds, note, COALESCE((
+		SELECT CAST(` + db.JSONArrayAgg() + `(name) AS TEXT) FROM (
+			SELECT t.name FROM weight_tags wt JOIN tags t ON t.id = wt.tag_id
+			WHERE wt.weight_id = weights.id ORDER BY t.name
+		) AS names
+	), '[]'), version, created_at, updated_at, deleted_at FROM weights`
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanWeight scans a row selected with weightSelect into w
+func scanWeight(row rowScanner, w *models.Weight) error {
+	var tags string
+	err := row.Scan(&w.ID, &w.UUID, &w.Date, &w.Pounds, &w.Note, &tags, &w.Version, &w.CreatedAt, &w.UpdatedAt, &w.DeletedAt)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(tags), &w.Tags)
+}
+
+// GetWeights retrieves all weight entries with optional date and tag
+// filtering. Repeated tag parameters match entries with any of the tags;
+// exclude_tag removes entries with any of the given tags.
 func GetWeights(c *gin.Context) {
+	ctx := c.Request.Context()
+
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
 
-	query := "SELECT id, date, pounds, created_at, updated_at FROM weights"
+	query := weightSelect() + " WHERE deleted_at IS NULL"
 	args := []interface{}{}
 
-	if startDate != "" && endDate != "" {
-		query += " WHERE date >= ? AND date <= ?"
-		args = append(args, startDate, endDate)
-	} else if startDate != "" {
-		query += " WHERE date >= ?"
+	if startDate != "" {
+		query += " AND date >= ?"
 		args = append(args, startDate)
-	} else if endDate != "" {
-		query += " WHERE date <= ?"
+	}
+	if endDate != "" {
+		query += " AND date <= ?"
 		args = append(args, endDate)
 	}
+	if tags := c.QueryArray("tag"); len(tags) > 0 {
+		query += " AND EXISTS (" + taggedWith(tags) + ")"
+		args = append(args, stringArgs(tags)...)
+	}
+	if excluded := c.QueryArray("exclude_tag"); len(excluded) > 0 {
+		query += " AND NOT EXISTS (" + taggedWith(excluded) + ")"
+		args = append(args, stringArgs(excluded)...)
+	}
 
 	query += " ORDER BY date DESC"
 
-	rows, err := db.DB.Query(query, args...)
+	rows, err := db.ReadDB.QueryContext(ctx, query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to retrieve weights",
-		})
+		abortWithError(c, err, "Failed to retrieve weights")
 		return
 	}
 	defer rows.Close()
@@ -45,204 +83,444 @@ func GetWeights(c *gin.Context) {
 	weights := []models.Weight{}
 	for rows.Next() {
 		var w models.Weight
-		if err := rows.Scan(&w.ID, &w.Date, &w.Pounds, &w.CreatedAt, &w.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-				Error: "Failed to scan weight entry",
-			})
+		if err := scanWeight(rows, &w); err != nil {
+			abortWithError(c, err, "Failed to scan weight entry")
 			return
 		}
 		weights = append(weights, w)
 	}
 
-	c.JSON(http.StatusOK, models.WeightsResponse{Weights: weights})
+	respondWithETag(c, http.StatusOK, "", models.WeightsResponse{Weights: weights})
 }
 
 // GetWeight retrieves a single weight entry by ID
 func GetWeight(c *gin.Context) {
+	ctx := c.Request.Context()
+
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: "Invalid weight ID",
-		})
+		abortInvalidID(c, err, "Invalid weight ID")
 		return
 	}
 
 	var w models.Weight
-	query := "SELECT id, date, pounds, created_at, updated_at FROM weights WHERE id = ?"
-	err = db.DB.QueryRow(query, id).Scan(&w.ID, &w.Date, &w.Pounds, &w.CreatedAt, &w.UpdatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error: "Weight entry not found",
-		})
-		return
-	}
+	err = scanWeight(db.ReadDB.QueryRowContext(ctx, weightSelect()+" WHERE id = ? AND deleted_at IS NULL", id), &w)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to retrieve weight entry",
-		})
+		abortWithError(c, db.WeightError(err), "Failed to retrieve weight entry")
 		return
 	}
 
-	c.JSON(http.StatusOK, w)
+	respondWithETag(c, http.StatusOK, versionETag(w.Version), w)
 }
 
 // CreateWeight creates a new weight entry
 func CreateWeight(c *gin.Context) {
 	var input models.WeightInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Details: map[string]interface{}{"validation": err.Error()},
-		})
+	if !bindJSON(c, &input) {
 		return
 	}
 
+	w, err := createWeight(c.Request.Context(), sourceOf(c), input)
+	if err != nil {
+		abortWithOperation(c, err)
+		return
+	}
+
+	c.Header("ETag", versionETag(w.Version))
+	c.JSON(http.StatusCreated, w)
+}
+
+// createWeight creates a weight entry from input and returns it
+func createWeight(ctx context.Context, source changeSource, input models.WeightInput) (models.Weight, error) {
 	// Validate date format and ensure it's not in the future
 	if err := validateDate(input.Date); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid date",
-			Details: map[string]interface{}{"date": err.Error()},
-		})
-		return
+		return models.Weight{}, failedFields(invalid(err), "Invalid date", []models.FieldError{dateError("date", err)})
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Weight{}, failed(err, "Failed to create weight entry")
+	}
+	defer tx.Rollback()
+
+	before, err := selectLatestWeight(ctx, tx)
+	if err != nil {
+		return models.Weight{}, failed(err, "Failed to create weight entry")
 	}
 
 	// Insert the weight entry
-	query := `INSERT INTO weights (date, pounds, created_at, updated_at) 
-	          VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
-	result, err := db.DB.Exec(query, input.Date, input.Pounds)
+	query := `INSERT INTO weights (date, pounds, note, created_at, updated_at) 
+	          VALUES (?, ?, NULLIF(?, ''), ` + db.Now() + `, ` + db.Now() + `) RETURNING id`
+	var id int
+	err = tx.QueryRowContext(ctx, query, input.Date, input.Pounds, noteValue(input.Note)).Scan(&id)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			c.JSON(http.StatusConflict, models.ErrorResponse{
-				Error: "Weight entry already exists for this date",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to create weight entry",
-		})
-		return
+		return models.Weight{}, failed(db.WeightError(err), "Failed to create weight entry")
+	}
+
+	if err := setWeightTags(ctx, tx, id, input.Tags); err != nil {
+		return models.Weight{}, failed(err, "Failed to save weight tags")
 	}
 
-	id, _ := result.LastInsertId()
+	if err := stampFields(ctx, tx, id, syncFields, time.Now()); err != nil {
+		return models.Weight{}, failed(err, "Failed to create weight entry")
+	}
 
 	// Retrieve the created entry
 	var w models.Weight
-	query = "SELECT id, date, pounds, created_at, updated_at FROM weights WHERE id = ?"
-	err = db.DB.QueryRow(query, id).Scan(&w.ID, &w.Date, &w.Pounds, &w.CreatedAt, &w.UpdatedAt)
+	err = scanWeight(tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", id), &w)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to retrieve created weight entry",
-		})
-		return
+		return models.Weight{}, failed(err, "Failed to retrieve created weight entry")
 	}
 
-	c.JSON(http.StatusCreated, w)
+	if err := recordAudit(ctx, tx, source, "weight", w.ID, "create", nil, w); err != nil {
+		return models.Weight{}, failed(err, "Failed to record change")
+	}
+
+	goal, reached, err := goalReached(ctx, tx, w.ID, before)
+	if err != nil {
+		return models.Weight{}, failed(err, "Failed to create weight entry")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Weight{}, failed(err, "Failed to create weight entry")
+	}
+	broker.Publish(events.WeightCreated, w)
+	if reached {
+		broker.Publish(events.GoalReached, gin.H{"goal_pounds": goal, "weight": w})
+	}
+
+	return w, nil
 }
 
 // UpdateWeight updates an existing weight entry
 func UpdateWeight(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: "Invalid weight ID",
-		})
+		abortInvalidID(c, err, "Invalid weight ID")
 		return
 	}
 
 	var input models.WeightInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request",
-			Details: map[string]interface{}{"validation": err.Error()},
-		})
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	w, err := replaceWeight(c.Request.Context(), sourceOf(c), id, c.GetHeader("If-Match"), input)
+	if err != nil {
+		abortWithOperation(c, err)
 		return
 	}
 
+	c.Header("ETag", versionETag(w.Version))
+	c.JSON(http.StatusOK, w)
+}
+
+// replaceWeight writes input over the weight entry with the given id,
+// provided ifMatch, if not empty, matches its current version
+func replaceWeight(ctx context.Context, source changeSource, id int, ifMatch string, input models.WeightInput) (models.Weight, error) {
 	// Validate date format and ensure it's not in the future
 	if err := validateDate(input.Date); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid date",
-			Details: map[string]interface{}{"date": err.Error()},
-		})
+		return models.Weight{}, failedFields(invalid(err), "Invalid date", []models.FieldError{dateError("date", err)})
+	}
+
+	version, err := weightVersion(ctx, id)
+	if err != nil {
+		return models.Weight{}, err
+	}
+	if err := ifMatchError(ifMatch, version); err != nil {
+		return models.Weight{}, err
+	}
+
+	return saveWeight(ctx, source, id, version, input)
+}
+
+// PatchWeight partially updates an existing weight entry using JSON Merge
+// Patch (RFC 7396) semantics: fields present in the body replace the stored
+// value, absent fields are left unchanged.
+func PatchWeight(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortInvalidID(c, err, "Invalid weight ID")
 		return
 	}
 
-	// Check if entry exists
-	var exists bool
-	err = db.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM weights WHERE id = ?)", id).Scan(&exists)
-	if err != nil || !exists {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error: "Weight entry not found",
-		})
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		abortWithOperation(c, malformedBody(err))
 		return
 	}
 
-	// Update the weight entry
-	query := `UPDATE weights SET date = ?, pounds = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err = db.DB.Exec(query, input.Date, input.Pounds, id)
+	w, err := patchWeight(c.Request.Context(), sourceOf(c), id, c.GetHeader("If-Match"), patch)
+	if err != nil {
+		abortWithOperation(c, err)
+		return
+	}
+
+	c.Header("ETag", versionETag(w.Version))
+	c.JSON(http.StatusOK, w)
+}
+
+// patchWeight merges patch onto the weight entry with the given id,
+// provided ifMatch, if not empty, matches its current version
+func patchWeight(ctx context.Context, source changeSource, id int, ifMatch string, patch map[string]json.RawMessage) (models.Weight, error) {
+	// Load the current entry so the patch can be merged onto it
+	var existing models.Weight
+	err := scanWeight(db.DB.QueryRowContext(ctx, weightSelect()+" WHERE id = ? AND deleted_at IS NULL", id), &existing)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			c.JSON(http.StatusConflict, models.ErrorResponse{
-				Error: "Weight entry already exists for this date",
-			})
-			return
+		return models.Weight{}, failed(db.WeightError(err), "Failed to retrieve weight entry")
+	}
+
+	if err := ifMatchError(ifMatch, existing.Version); err != nil {
+		return models.Weight{}, err
+	}
+
+	current := models.WeightInput{
+		Date:   existing.Date,
+		Pounds: existing.Pounds,
+		Note:   existing.Note,
+		Tags:   existing.Tags,
+	}
+	if fields := applyWeightPatch(&current, patch); len(fields) > 0 {
+		return models.Weight{}, failedFields(invalid(nil), "Invalid request", fields)
+	}
+
+	return saveWeight(ctx, source, id, existing.Version, current)
+}
+
+// weightVersion looks up the row version of a weight entry
+func weightVersion(ctx context.Context, id int) (int, error) {
+	var version int
+	query := "SELECT version FROM weights WHERE id = ? AND deleted_at IS NULL"
+	err := db.DB.QueryRowContext(ctx, query, id).Scan(&version)
+
+	if err != nil {
+		return 0, failed(db.WeightError(err), "Failed to retrieve weight entry")
+	}
+
+	return version, nil
+}
+
+// saveWeight writes input over the weight entry with the given id, provided
+// it is still at version, and returns the updated entry
+func saveWeight(ctx context.Context, source changeSource, id, version int, input models.WeightInput) (models.Weight, error) {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Weight{}, failed(err, "Failed to update weight entry")
+	}
+	defer tx.Rollback()
+
+	var old models.Weight
+	if err := scanWeight(tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", id), &old); err != nil {
+		return models.Weight{}, failed(err, "Failed to retrieve weight entry")
+	}
+
+	before, err := selectLatestWeight(ctx, tx)
+	if err != nil {
+		return models.Weight{}, failed(err, "Failed to update weight entry")
+	}
+
+	// A nil note keeps the stored note; an empty one clears it
+	query := `UPDATE weights SET date = ?, pounds = ?, note = CASE WHEN ? THEN NULLIF(?, '') ELSE note END,
+	          version = version + 1, updated_at = ` + db.Now() + `
+	          WHERE id = ? AND version = ? AND deleted_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, input.Date, input.Pounds, input.Note != nil, noteValue(input.Note), id, version)
+
+	if err != nil {
+		return models.Weight{}, failed(db.WeightError(err), "Failed to update weight entry")
+	}
+
+	// Another request updated the entry between reading and writing it
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return models.Weight{}, failed(db.ErrModified, "Resource has been modified")
+	}
+
+	if input.Tags != nil {
+		if err := setWeightTags(ctx, tx, id, input.Tags); err != nil {
+			return models.Weight{}, failed(err, "Failed to save weight tags")
 		}
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to update weight entry",
-		})
-		return
 	}
 
 	// Retrieve the updated entry
 	var w models.Weight
-	query = "SELECT id, date, pounds, created_at, updated_at FROM weights WHERE id = ?"
-	err = db.DB.QueryRow(query, id).Scan(&w.ID, &w.Date, &w.Pounds, &w.CreatedAt, &w.UpdatedAt)
+	err = scanWeight(tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", id), &w)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to retrieve updated weight entry",
-		})
-		return
+		return models.Weight{}, failed(err, "Failed to retrieve updated weight entry")
 	}
 
-	c.JSON(http.StatusOK, w)
+	if err := stampFields(ctx, tx, id, changedFields(old, w), time.Now()); err != nil {
+		return models.Weight{}, failed(err, "Failed to update weight entry")
+	}
+
+	if err := recordAudit(ctx, tx, source, "weight", id, "update", old, w); err != nil {
+		return models.Weight{}, failed(err, "Failed to record change")
+	}
+
+	goal, reached, err := goalReached(ctx, tx, id, before)
+	if err != nil {
+		return models.Weight{}, failed(err, "Failed to update weight entry")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Weight{}, failed(err, "Failed to update weight entry")
+	}
+	broker.Publish(events.WeightUpdated, w)
+	if reached {
+		broker.Publish(events.GoalReached, gin.H{"goal_pounds": goal, "weight": w})
+	}
+
+	return w, nil
+}
+
+// applyWeightPatch merges a JSON Merge Patch onto the editable fields of a
+// weight entry. It returns an error, sorted by field, for every field that
+// could not be applied; the slice is empty on success.
+func applyWeightPatch(current *models.WeightInput, patch map[string]json.RawMessage) []models.FieldError {
+	var fields []models.FieldError
+
+	for field, raw := range patch {
+		// A null value removes the member in merge patch semantics
+		isNull := string(raw) == "null"
+
+		switch field {
+		case "date":
+			var date string
+			if isNull || json.Unmarshal(raw, &date) != nil {
+				fields = append(fields, typeError(field, "string"))
+				continue
+			}
+			if err := validateDate(date); err != nil {
+				fields = append(fields, dateError(field, err))
+				continue
+			}
+			current.Date = date
+		case "pounds":
+			var pounds float64
+			if isNull || json.Unmarshal(raw, &pounds) != nil {
+				fields = append(fields, typeError(field, "number"))
+				continue
+			}
+			if pounds <= 0 {
+				fields = append(fields, models.FieldError{Field: field, Constraint: "gt", Param: "0", Message: "must be greater than 0"})
+				continue
+			}
+			current.Pounds = pounds
+		case "note":
+			var note string
+			if isNull {
+				current.Note = &note
+				continue
+			}
+			if json.Unmarshal(raw, &note) != nil {
+				fields = append(fields, typeError(field, "string"))
+				continue
+			}
+			if utf8.RuneCountInString(note) > 1000 {
+				fields = append(fields, models.FieldError{Field: field, Constraint: "max", Param: "1000", Message: "must be at most 1000 characters"})
+				continue
+			}
+			current.Note = &note
+		case "tags":
+			tags := []string{}
+			if isNull {
+				current.Tags = tags
+				continue
+			}
+			if json.Unmarshal(raw, &tags) != nil {
+				fields = append(fields, typeError(field, "array"))
+				continue
+			}
+			if !validTags(tags) {
+				fields = append(fields, models.FieldError{Field: field, Constraint: "tags", Message: "must be at most 20 tag names of 1-50 characters"})
+				continue
+			}
+			current.Tags = tags
+		default:
+			fields = append(fields, models.FieldError{Field: field, Constraint: "read_only", Message: "is unknown or read-only"})
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
 }
 
-// DeleteWeight deletes a weight entry
+// DeleteWeight moves a weight entry to the trash. Trashed entries are hidden
+// from every read and purged once the retention period has passed.
 func DeleteWeight(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error: "Invalid weight ID",
-		})
+		abortInvalidID(c, err, "Invalid weight ID")
 		return
 	}
 
-	// Check if entry exists
-	var exists bool
-	err = db.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM weights WHERE id = ?)", id).Scan(&exists)
-	if err != nil || !exists {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error: "Weight entry not found",
-		})
+	if err := deleteWeight(c.Request.Context(), sourceOf(c), id, c.GetHeader("If-Match")); err != nil {
+		abortWithOperation(c, err)
 		return
 	}
 
-	// Delete the entry
-	_, err = db.DB.Exec("DELETE FROM weights WHERE id = ?", id)
+	c.Status(http.StatusNoContent)
+}
+
+// deleteWeight moves the weight entry with the given id to the trash,
+// provided ifMatch, if not empty, matches its current version
+func deleteWeight(ctx context.Context, source changeSource, id int, ifMatch string) error {
+	version, err := weightVersion(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error: "Failed to delete weight entry",
-		})
-		return
+		return err
+	}
+	if err := ifMatchError(ifMatch, version); err != nil {
+		return err
 	}
 
-	c.Status(http.StatusNoContent)
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return failed(err, "Failed to delete weight entry")
+	}
+	defer tx.Rollback()
+
+	var old models.Weight
+	if err := scanWeight(tx.QueryRowContext(ctx, weightSelect()+" WHERE id = ?", id), &old); err != nil {
+		return failed(err, "Failed to retrieve weight entry")
+	}
+
+	// Trash the entry, provided it wasn't modified since the check
+	query := `UPDATE weights SET deleted_at = ` + db.Now() + `, version = version + 1, updated_at = ` + db.Now() + `
+	          WHERE id = ? AND version = ? AND deleted_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return failed(err, "Failed to delete weight entry")
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return failed(db.ErrModified, "Resource has been modified")
+	}
+
+	if err := stampFields(ctx, tx, id, []string{"deleted"}, time.Now()); err != nil {
+		return failed(err, "Failed to delete weight entry")
+	}
+
+	if err := recordAudit(ctx, tx, source, "weight", id, "delete", old, nil); err != nil {
+		return failed(err, "Failed to record change")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return failed(err, "Failed to delete weight entry")
+	}
+	broker.Publish(events.WeightDeleted, gin.H{"id": id})
+
+	return nil
+}
+
+// noteValue returns the bind value for an optional note
+func noteValue(note *string) interface{} {
+	if note == nil {
+		return nil
+	}
+	return strings.TrimSpace(*note)
 }
 
 // validateDate validates that the date is in YYYY-MM-DD format and not in the future
@@ -258,7 +536,7 @@ func validateDate(dateStr string) error {
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 
 	if date.After(today) {
-		return sql.ErrNoRows // Reuse error, or create custom error
+		return errFutureDate
 	}
 
 	return nil
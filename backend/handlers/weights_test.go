@@ -6,44 +6,43 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/sddev/weight-tracker/db"
 	"github.com/sddev/weight-tracker/models"
 )
 
-func setupTestDB(t *testing.T) {
-	var err error
-	db.DB, err = sql.Open("sqlite3", ":memory:")
-	if err != nil {
-		t.Fatalf("Failed to open test database: %v", err)
+// setupTestDB opens a fresh in-memory SQLite database or, when
+// TEST_DATABASE_URL is set, the PostgreSQL database it names after emptying
+// it. Every handler test runs against whichever is selected.
+func setupTestDB(t testing.TB) {
+	if databaseURL := os.Getenv("TEST_DATABASE_URL"); databaseURL != "" {
+		resetPostgres(t, databaseURL)
+		if err := db.InitPostgres(databaseURL, 4); err != nil {
+			t.Fatalf("Failed to open test database: %v", err)
+		}
+		return
 	}
 
-	schema := `
-	CREATE TABLE IF NOT EXISTS weights (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		date TEXT NOT NULL UNIQUE,
-		pounds REAL NOT NULL,
-		created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_weights_date ON weights(date DESC);
-
-	CREATE TABLE IF NOT EXISTS settings (
-		key TEXT PRIMARY KEY,
-		value TEXT,
-		updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
+	if err := db.InitDB(":memory:", db.DefaultOptions()); err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+}
 
-	INSERT OR IGNORE INTO settings (key, value) VALUES ('goal_weight', NULL);
-	`
+// resetPostgres drops every table so each test starts from an empty schema
+func resetPostgres(t testing.TB, databaseURL string) {
+	conn, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer conn.Close()
 
-	if _, err := db.DB.Exec(schema); err != nil {
-		t.Fatalf("Failed to create schema: %v", err)
+	if _, err := conn.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+		t.Fatalf("Failed to reset test database: %v", err)
 	}
 }
 
@@ -53,12 +52,31 @@ func teardownTestDB() {
 	}
 }
 
+// apiRouter builds the full API router, as the server registers it
+func apiRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	RegisterRoutes(router, NoRoute)
+	return router
+}
+
+// serve sends a request through router
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
 func TestGetWeights_Empty(t *testing.T) {
 	setupTestDB(t)
 	defer teardownTestDB()
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.GET("/weights", GetWeights)
 
 	req, _ := http.NewRequest("GET", "/weights", nil)
@@ -85,6 +103,7 @@ func TestCreateWeight_Success(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.POST("/weights", CreateWeight)
 
 	today := time.Now().Format("2006-01-02")
@@ -125,6 +144,7 @@ func TestCreateWeight_InvalidDate(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.POST("/weights", CreateWeight)
 
 	input := models.WeightInput{
@@ -149,6 +169,7 @@ func TestCreateWeight_FutureDate(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.POST("/weights", CreateWeight)
 
 	futureDate := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
@@ -174,6 +195,7 @@ func TestCreateWeight_InvalidPounds(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.POST("/weights", CreateWeight)
 
 	today := time.Now().Format("2006-01-02")
@@ -199,6 +221,7 @@ func TestCreateWeight_DuplicateDate(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.POST("/weights", CreateWeight)
 
 	today := time.Now().Format("2006-01-02")
@@ -235,11 +258,12 @@ func TestGetWeight_Success(t *testing.T) {
 
 	// Insert test data
 	today := time.Now().Format("2006-01-02")
-	result, _ := db.DB.Exec("INSERT INTO weights (date, pounds) VALUES (?, ?)", today, 170.5)
-	id, _ := result.LastInsertId()
+	var id int
+	db.DB.QueryRow("INSERT INTO weights (date, pounds) VALUES (?, ?) RETURNING id", today, 170.5).Scan(&id)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.GET("/weights/:id", GetWeight)
 
 	req, _ := http.NewRequest("GET", "/weights/1", nil)
@@ -255,7 +279,7 @@ func TestGetWeight_Success(t *testing.T) {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
 
-	if weight.ID != int(id) {
+	if weight.ID != id {
 		t.Errorf("Expected ID %d, got %d", id, weight.ID)
 	}
 	if weight.Pounds != 170.5 {
@@ -269,6 +293,7 @@ func TestGetWeight_NotFound(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.GET("/weights/:id", GetWeight)
 
 	req, _ := http.NewRequest("GET", "/weights/999", nil)
@@ -290,6 +315,7 @@ func TestUpdateWeight_Success(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.PUT("/weights/:id", UpdateWeight)
 
 	input := models.WeightInput{
@@ -323,6 +349,7 @@ func TestUpdateWeight_NotFound(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.PUT("/weights/:id", UpdateWeight)
 
 	today := time.Now().Format("2006-01-02")
@@ -352,6 +379,7 @@ func TestDeleteWeight_Success(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.DELETE("/weights/:id", DeleteWeight)
 
 	req, _ := http.NewRequest("DELETE", "/weights/1", nil)
@@ -362,9 +390,9 @@ func TestDeleteWeight_Success(t *testing.T) {
 		t.Errorf("Expected status 204, got %d", w.Code)
 	}
 
-	// Verify deletion
+	// Verify deletion moved the entry to the trash
 	var count int
-	db.DB.QueryRow("SELECT COUNT(*) FROM weights WHERE id = 1").Scan(&count)
+	db.DB.QueryRow("SELECT COUNT(*) FROM weights WHERE id = 1 AND deleted_at IS NULL").Scan(&count)
 	if count != 0 {
 		t.Error("Weight entry should be deleted")
 	}
@@ -376,6 +404,7 @@ func TestDeleteWeight_NotFound(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.DELETE("/weights/:id", DeleteWeight)
 
 	req, _ := http.NewRequest("DELETE", "/weights/999", nil)
@@ -398,6 +427,7 @@ func TestGetWeights_WithDateRange(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(ErrorHandler())
 	router.GET("/weights", GetWeights)
 
 	req, _ := http.NewRequest("GET", "/weights?start_date=2026-01-10&end_date=2026-01-20", nil)
@@ -421,3 +451,247 @@ func TestGetWeights_WithDateRange(t *testing.T) {
 		t.Errorf("Expected date 2026-01-15, got %s", response.Weights[0].Date)
 	}
 }
+
+func TestGetWeights_NotModified(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-15', 170.5)")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/weights", GetWeights)
+
+	req1, _ := http.NewRequest("GET", "/weights", nil)
+	w1 := httptest.NewRecorder()
+	router.ServeHTTP(w1, req1)
+
+	etag := w1.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header on weights list")
+	}
+
+	// Unchanged data should produce 304
+	req2, _ := http.NewRequest("GET", "/weights", nil)
+	req2.Header.Set("If-None-Match", etag)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
+	if w2.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w2.Code)
+	}
+
+	// Any change should produce a fresh list
+	db.DB.Exec("UPDATE weights SET pounds = 169.0, version = version + 1 WHERE id = 1")
+
+	req3, _ := http.NewRequest("GET", "/weights", nil)
+	req3.Header.Set("If-None-Match", etag)
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+
+	if w3.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after change, got %d", w3.Code)
+	}
+}
+
+func TestUpdateWeight_IfMatch(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-15', 170.5)")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/weights/:id", GetWeight)
+	router.PUT("/weights/:id", UpdateWeight)
+
+	req1, _ := http.NewRequest("GET", "/weights/1", nil)
+	w1 := httptest.NewRecorder()
+	router.ServeHTTP(w1, req1)
+
+	etag := w1.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("Expected ETag \"1\", got %s", etag)
+	}
+
+	input := models.WeightInput{Date: "2026-01-15", Pounds: 168.0}
+	body, _ := json.Marshal(input)
+
+	// First writer wins
+	req2, _ := http.NewRequest("PUT", "/weights/1", bytes.NewBuffer(body))
+	req2.Header.Set("Content-Type", "application/json")
+	req2.Header.Set("If-Match", etag)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+
+	if w2.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w2.Code, w2.Body.String())
+	}
+	if w2.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected new ETag \"2\", got %s", w2.Header().Get("ETag"))
+	}
+
+	// Second writer with the stale ETag is rejected
+	req3, _ := http.NewRequest("PUT", "/weights/1", bytes.NewBuffer(body))
+	req3.Header.Set("Content-Type", "application/json")
+	req3.Header.Set("If-Match", etag)
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+
+	if w3.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412, got %d", w3.Code)
+	}
+
+	// A weak tag never satisfies If-Match, even for the current version
+	req4, _ := http.NewRequest("PUT", "/weights/1", bytes.NewBuffer(body))
+	req4.Header.Set("Content-Type", "application/json")
+	req4.Header.Set("If-Match", "W/"+w2.Header().Get("ETag"))
+	w4 := httptest.NewRecorder()
+	router.ServeHTTP(w4, req4)
+
+	if w4.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for a weak tag, got %d", w4.Code)
+	}
+}
+
+func TestDeleteWeight_IfMatchStale(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds, version) VALUES ('2026-01-15', 170.5, 2)")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.DELETE("/weights/:id", DeleteWeight)
+
+	req, _ := http.NewRequest("DELETE", "/weights/1", nil)
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412, got %d", w.Code)
+	}
+
+	var count int
+	db.DB.QueryRow("SELECT COUNT(*) FROM weights WHERE id = 1").Scan(&count)
+	if count != 1 {
+		t.Error("Weight entry should not be deleted")
+	}
+}
+
+func TestPatchWeight_KeepsOmittedFields(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds, note) VALUES ('2026-01-15', 170.5, 'after run')")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.PATCH("/weights/:id", PatchWeight)
+
+	req, _ := http.NewRequest("PATCH", "/weights/1", bytes.NewBufferString(`{"pounds": 169}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var weight models.Weight
+	if err := json.Unmarshal(w.Body.Bytes(), &weight); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if weight.Pounds != 169 || weight.Date != "2026-01-15" || weight.Note == nil || *weight.Note != "after run" {
+		t.Errorf("Expected only pounds to change, got %+v", weight)
+	}
+}
+
+func TestPatchWeight_Errors(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-15', 170.5)")
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-16', 170)")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.PATCH("/weights/:id", PatchWeight)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"onto another entry's date", "/weights/1", `{"date": "2026-01-16"}`, http.StatusConflict, models.ErrorCodeDuplicateDate},
+		{"unknown id", "/weights/99", `{"pounds": 169}`, http.StatusNotFound, models.ErrorCodeNotFound},
+		{"invalid id", "/weights/abc", `{"pounds": 169}`, http.StatusBadRequest, models.ErrorCodeInvalidID},
+		{"null date", "/weights/1", `{"date": null}`, http.StatusBadRequest, models.ErrorCodeValidationFailed},
+		{"null pounds", "/weights/1", `{"pounds": null}`, http.StatusBadRequest, models.ErrorCodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PATCH", tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var response models.ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if w.Code != tt.status || response.Code != tt.code {
+				t.Errorf("Expected %d %s, got %d %s", tt.status, tt.code, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Nothing was changed by the rejected patches
+	var date string
+	var pounds float64
+	db.DB.QueryRow("SELECT date, pounds FROM weights WHERE id = 1").Scan(&date, &pounds)
+	if date != "2026-01-15" || pounds != 170.5 {
+		t.Errorf("Expected the entry unchanged, got %s %v", date, pounds)
+	}
+}
+
+func TestPatchWeight_NoteLengthCountsCharacters(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.POST("/weights", CreateWeight)
+	router.PATCH("/weights/:id", PatchWeight)
+
+	// The same multibyte note is accepted by both create and patch
+	note := strings.Repeat("é", 600)
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/weights", `{"date": "2026-01-15", "pounds": 170, "note": "` + note + `"}`, http.StatusCreated},
+		{"PATCH", "/weights/1", `{"note": "` + note + `"}`, http.StatusOK},
+		{"PATCH", "/weights/1", `{"note": "` + strings.Repeat("é", 1001) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d. Body: %s", tt.method, tt.path, tt.status, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNew_InvalidOptions(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "trace", "json"); err == nil {
+		t.Error("Expected error for invalid level")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("Expected error for invalid format")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, GetRequestID(c)) })
+
+	tests := []struct {
+		name   string
+		header string
+		reuse  bool
+	}{
+		{"generated", "", false},
+		{"reused", "abc-123", true},
+		{"rejects control characters", "abc\n123", false},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		if id == "" || id != w.Body.String() {
+			t.Errorf("%s: expected matching request ID in header and context, got %q and %q", tt.name, id, w.Body.String())
+		}
+		if (id == tt.header) != tt.reuse {
+			t.Errorf("%s: unexpected request ID %q for header %q", tt.name, id, tt.header)
+		}
+	}
+}
+
+func TestMiddleware_LogsErrors(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Middleware(logger))
+	router.GET("/fail/:id", func(c *gin.Context) {
+		c.Error(errors.New("disk I/O error"))
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req, _ := http.NewRequest("GET", "/fail/1", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to parse log record %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"level":      "ERROR",
+		"request_id": "req-1",
+		"route":      "/fail/:id",
+		"status":     float64(500),
+		"error":      "disk I/O error",
+	}
+	for key, want := range expected {
+		if record[key] != want {
+			t.Errorf("Expected %s %v, got %v", key, want, record[key])
+		}
+	}
+}
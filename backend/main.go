@@ -1,70 +1,227 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/config"
 	"github.com/sddev/weight-tracker/db"
 	"github.com/sddev/weight-tracker/handlers"
+	"github.com/sddev/weight-tracker/logging"
+	"github.com/sddev/weight-tracker/metrics"
+	"github.com/sddev/weight-tracker/tracing"
+	"github.com/sddev/weight-tracker/version"
 )
 
 func main() {
-	// Initialize database
-	if err := db.InitDB(); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// A leading non-flag argument names an admin command; serving is the
+	// default
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
-	defer db.CloseDB()
+	if command != "serve" {
+		os.Exit(runAdmin(command, args, os.Stdout, os.Stderr))
+	}
+
+	// Load configuration from defaults, config file, environment and flags
+	cfg, err := config.Load(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if cfg.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
+
+	// Structured logging; the standard log package is routed through it too
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// Open the database, creating or migrating its schema
+	if err := openDatabase(cfg); err != nil {
+		slog.Error("Failed to initialize database", "error", err)
+		os.Exit(1)
+	}
+
+	// Export traces of requests and SQL statements
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, os.Stdout)
+	if err != nil {
+		slog.Error("Failed to configure tracing", "error", err)
+		os.Exit(1)
+	}
+	db.AddQueryHook(tracing.QueryHook{})
+
+	// Collect Prometheus metrics for requests, queries and stored data
+	appMetrics := metrics.New(db.DB, db.ReadDB)
+	db.AddQueryHook(appMetrics)
+
+	// Cancelled on SIGINT/SIGTERM to begin a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Periodically purge trashed entries past their retention period
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		purgeTrash(ctx, cfg.TrashRetention())
+	}()
 
 	// Set Gin mode
-	if os.Getenv("GIN_MODE") == "" {
-		gin.SetMode(gin.ReleaseMode)
+	gin.SetMode(cfg.GinMode)
+
+	// Create Gin router with request IDs, structured access logs, metrics
+	// and error responses for errors handlers attach to the request.
+	// Recovery runs inside the logging and metrics middleware so panics are
+	// logged and counted as 500s.
+	router := gin.New()
+	router.Use(
+		logging.RequestID(),
+		tracing.Middleware(),
+		logging.Middleware(logger),
+		appMetrics.Middleware(),
+		handlers.ErrorHandler(),
+		gin.CustomRecovery(handlers.Recover),
+	)
+	router.NoRoute(handlers.NoRoute)
+
+	// Configure CORS; credentials cannot be combined with a wildcard origin
+	corsConfig := cors.Config{
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Accept", "If-Match", "If-None-Match", "X-Actor", logging.RequestIDHeader, "traceparent", "tracestate"},
+		ExposeHeaders: []string{"Content-Length", "ETag", logging.RequestIDHeader},
+	}
+	if cfg.AllowsAllOrigins() {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = cfg.CORSOrigins
+		corsConfig.AllowCredentials = true
 	}
+	router.Use(cors.New(corsConfig))
 
-	// Create Gin router
-	router := gin.Default()
+	// Health checks, metrics and the API, as described by the OpenAPI
+	// document at /api/v1/openapi.json
+	handlers.SetBackupDir(cfg.BackupDir)
+	handlers.SetAllowedOrigins(cfg.CORSOrigins)
+	handlers.RegisterRoutes(router, appMetrics.Handler())
 
-	// Configure CORS
-	corsOrigin := os.Getenv("CORS_ORIGIN")
-	if corsOrigin == "" {
-		corsOrigin = "http://localhost:3000"
+	server := &http.Server{
+		Addr:    ":" + strconv.Itoa(cfg.Port),
+		Handler: router,
 	}
+	// Event streams never finish on their own; end them so shutdown can
+	// drain
+	server.RegisterOnShutdown(handlers.CloseEvents)
 
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{corsOrigin},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
+	// Deliver change events to webhooks, resuming unfinished deliveries
+	handlers.StartWebhooks()
 
-	// Health check endpoint
-	router.GET("/health", handlers.HealthCheck)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "port", cfg.Port, "version", version.Version, "commit", version.Commit)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	var startErr error
+	select {
+	case startErr = <-serverErr:
+		if startErr != nil {
+			slog.Error("Failed to start server", "error", startErr)
+		}
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received, draining in-flight requests")
+	}
+	stop()
+
+	// Stop accepting connections and wait for in-flight requests
+	timeout := cfg.ShutdownTimeoutDuration()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Server did not drain in time", "timeout", timeout, "error", err)
+	}
+	// Live sync sessions are hijacked, so Shutdown doesn't wait for them;
+	// they may still be writing to the database
+	if err := handlers.WaitEvents(shutdownCtx); err != nil {
+		slog.Warn("Event streams did not close in time", "timeout", timeout, "error", err)
+	}
 
-	// API v1 routes
-	v1 := router.Group("/api/v1")
-	{
-		// Weight endpoints
-		v1.GET("/weights", handlers.GetWeights)
-		v1.GET("/weights/:id", handlers.GetWeight)
-		v1.POST("/weights", handlers.CreateWeight)
-		v1.PUT("/weights/:id", handlers.UpdateWeight)
-		v1.DELETE("/weights/:id", handlers.DeleteWeight)
+	// Wait for background work, then checkpoint and close the database.
+	// Webhook deliveries still to be retried resume on the next start.
+	background.Wait()
+	handlers.CloseWebhooks()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	if err := db.CloseDB(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+	}
 
-		// Goal endpoints
-		v1.GET("/goal", handlers.GetGoal)
-		v1.PUT("/goal", handlers.UpdateGoal)
+	slog.Info("Server stopped")
+	if startErr != nil {
+		os.Exit(1)
 	}
+}
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// openDatabase opens and migrates the configured database: PostgreSQL if a
+// URL is configured, else SQLite
+func openDatabase(cfg *config.Config) error {
+	if cfg.UsesPostgres() {
+		return db.InitPostgres(cfg.DatabaseURL, cfg.PostgresMaxConns)
 	}
+	return db.InitDB(cfg.DatabasePath, db.Options{
+		JournalMode:  cfg.SQLiteJournalMode,
+		Synchronous:  cfg.SQLiteSynchronous,
+		BusyTimeout:  cfg.SQLiteBusyTimeoutDuration(),
+		ForeignKeys:  cfg.SQLiteForeignKeys,
+		MaxReadConns: cfg.SQLiteMaxReadConns,
+	})
+}
+
+// purgeTrash removes expired trash entries on startup and then hourly until
+// ctx is cancelled
+func purgeTrash(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := db.PurgeTrash(retention); err != nil {
+			slog.Error("Failed to purge trash", "error", err)
+		} else if purged > 0 {
+			slog.Info("Purged expired entries from trash", "count", purged)
+		}
 
-	log.Printf("Starting server on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
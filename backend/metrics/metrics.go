@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sddev/weight-tracker/db"
+)
+
+const namespace = "weight_tracker"
+
+// Metrics holds the Prometheus registry and the collectors fed by the HTTP
+// middleware and the database query hook
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+}
+
+// New creates a registry with process, Go runtime, HTTP, query, connection
+// pool and data collectors. Pool stats are labelled db_name="writer" and
+// "reader"; data is queried through reader.
+func New(writer, reader *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Database statement latency by operation and outcome.",
+			Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+		}, []string{"operation", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+		collectors.NewDBStatsCollector(writer, "writer"),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+		&dataCollector{db: reader},
+	)
+
+	// In-memory databases share one pool
+	if reader != writer {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(reader, "reader"))
+	}
+
+	return m
+}
+
+// Middleware records the count and latency of every request, labelled by
+// the matched route pattern so that ids don't create new series
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		m.requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.requestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// queryStartKey carries the start time of a statement between hook calls
+type queryStartKey struct{}
+
+// BeforeQuery implements db.QueryHook
+func (m *Metrics) BeforeQuery(ctx context.Context, operation, query string) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, time.Now())
+}
+
+// AfterQuery implements db.QueryHook
+func (m *Metrics) AfterQuery(ctx context.Context, operation, query string, err error) {
+	start, ok := ctx.Value(queryStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.queryDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
+
+var _ db.QueryHook = (*Metrics)(nil)
+
+// dataCollector reports entry counts and weigh-in freshness, queried from
+// the database at scrape time
+type dataCollector struct {
+	db *sql.DB
+}
+
+var (
+	weightEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "weight_entries"),
+		"Number of weight entries by state (active or trashed).",
+		[]string{"state"}, nil,
+	)
+	tagsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "tags"),
+		"Number of tags.",
+		nil, nil,
+	)
+	daysSinceWeighInDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "days_since_last_weigh_in"),
+		"Whole days between today (UTC) and the most recent active weight entry.",
+		nil, nil,
+	)
+	goalSetDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "goal_set"),
+		"1 if a goal weight is set, otherwise 0.",
+		nil, nil,
+	)
+)
+
+func (d *dataCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- weightEntriesDesc
+	ch <- tagsDesc
+	ch <- daysSinceWeighInDesc
+	ch <- goalSetDesc
+}
+
+func (d *dataCollector) Collect(ch chan<- prometheus.Metric) {
+	var active, trashed, tags int
+	var lastDate sql.NullString
+	var goalSet bool
+
+	query := `SELECT
+		(SELECT COUNT(*) FROM weights WHERE deleted_at IS NULL),
+		(SELECT COUNT(*) FROM weights WHERE deleted_at IS NOT NULL),
+		(SELECT COUNT(*) FROM tags),
+		(SELECT MAX(date) FROM weights WHERE deleted_at IS NULL),
+		(SELECT value IS NOT NULL FROM settings WHERE key = 'goal_weight')`
+	err := d.db.QueryRow(query).Scan(&active, &trashed, &tags, &lastDate, &goalSet)
+	if err != nil {
+		slog.Error("Failed to collect data metrics", "error", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(weightEntriesDesc, prometheus.GaugeValue, float64(active), "active")
+	ch <- prometheus.MustNewConstMetric(weightEntriesDesc, prometheus.GaugeValue, float64(trashed), "trashed")
+	ch <- prometheus.MustNewConstMetric(tagsDesc, prometheus.GaugeValue, float64(tags))
+
+	goal := 0.0
+	if goalSet {
+		goal = 1
+	}
+	ch <- prometheus.MustNewConstMetric(goalSetDesc, prometheus.GaugeValue, goal)
+
+	// No series until there is at least one entry, so absent() can alert
+	if lastDate.Valid {
+		if last, err := time.Parse("2006-01-02", lastDate.String); err == nil {
+			now := time.Now().UTC()
+			today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+			days := today.Sub(last).Hours() / 24
+			ch <- prometheus.MustNewConstMetric(daysSinceWeighInDesc, prometheus.GaugeValue, days)
+		}
+	}
+}
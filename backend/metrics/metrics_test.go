@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sddev/weight-tracker/db"
+)
+
+func setupMetrics(t *testing.T) (*Metrics, *gin.Engine) {
+	if err := db.InitDB(":memory:", db.DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+
+	m := New(db.DB, db.ReadDB)
+	db.AddQueryHook(m)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/weights/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	router.GET("/metrics", m.Handler())
+
+	return m, router
+}
+
+func TestMiddleware_LabelsByRoute(t *testing.T) {
+	m, router := setupMetrics(t)
+
+	for _, path := range []string{"/weights/1", "/weights/2", "/nowhere"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "/weights/:id", "404")); got != 2 {
+		t.Errorf("Expected 2 requests for /weights/:id, got %f", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("Expected 1 unmatched request, got %f", got)
+	}
+}
+
+func TestHandler_ExposesDataMetrics(t *testing.T) {
+	_, router := setupMetrics(t)
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+	db.DB.Exec("INSERT INTO weights (date, pounds, deleted_at) VALUES ('2026-01-02', 170.0, CURRENT_TIMESTAMP)")
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	expected := []string{
+		`weight_tracker_weight_entries{state="active"} 1`,
+		`weight_tracker_weight_entries{state="trashed"} 1`,
+		`weight_tracker_days_since_last_weigh_in`,
+		`weight_tracker_goal_set 0`,
+		`go_sql_open_connections{db_name="writer"}`,
+		`weight_tracker_db_query_duration_seconds_count{operation="insert",status="ok"}`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics output to contain %q", want)
+		}
+	}
+}
@@ -1,18 +1,64 @@
 package models
 
+import "encoding/json"
+
 // Weight represents a weight entry
 type Weight struct {
-	ID        int     `json:"id"`
-	Date      string  `json:"date"`
-	Pounds    float64 `json:"pounds"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
+	ID        int      `json:"id"`
+	UUID      string   `json:"uuid"`
+	Date      string   `json:"date"`
+	Pounds    float64  `json:"pounds"`
+	Note      *string  `json:"note"`
+	Tags      []string `json:"tags"`
+	Version   int      `json:"version"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+	DeletedAt *string  `json:"deleted_at,omitempty"`
 }
 
-// WeightInput represents the input for creating/updating a weight entry
+// WeightInput represents the input for creating/updating a weight entry.
+// A nil Note or Tags leaves the stored value unchanged on update.
 type WeightInput struct {
-	Date   string  `json:"date" binding:"required"`
-	Pounds float64 `json:"pounds" binding:"required,gt=0"`
+	Date   string   `json:"date" binding:"required"`
+	Pounds float64  `json:"pounds" binding:"required,gt=0"`
+	Note   *string  `json:"note" binding:"omitempty,max=1000"`
+	Tags   []string `json:"tags" binding:"omitempty,max=20,dive,required,max=50"`
+}
+
+// Tag represents a label that can be attached to weight entries
+type Tag struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	ExcludeFromStats bool   `json:"exclude_from_stats"`
+	WeightCount      int    `json:"weight_count"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// TagInput represents the input for creating/updating a tag
+type TagInput struct {
+	Name             string `json:"name" binding:"required,max=50"`
+	ExcludeFromStats bool   `json:"exclude_from_stats"`
+}
+
+// TagsResponse represents the response for listing tags
+type TagsResponse struct {
+	Tags []Tag `json:"tags"`
+}
+
+// StatsResponse represents summary statistics over a range of weight entries.
+// Values are nil when no entries are included.
+type StatsResponse struct {
+	Count              int      `json:"count"`
+	ExcludedCount      int      `json:"excluded_count"`
+	FirstDate          *string  `json:"first_date"`
+	LastDate           *string  `json:"last_date"`
+	StartPounds        *float64 `json:"start_pounds"`
+	LatestPounds       *float64 `json:"latest_pounds"`
+	MinPounds          *float64 `json:"min_pounds"`
+	MaxPounds          *float64 `json:"max_pounds"`
+	AveragePounds      *float64 `json:"average_pounds"`
+	ChangePounds       *float64 `json:"change_pounds"`
+	TrendPoundsPerWeek *float64 `json:"trend_pounds_per_week"`
 }
 
 // Goal represents the goal weight setting
@@ -26,20 +72,237 @@ type GoalInput struct {
 	Pounds *float64 `json:"pounds" binding:"omitempty,gt=0"`
 }
 
-// HealthResponse represents the health check response
+// HealthResponse represents the health check response. The build, uptime
+// and checks fields are only included in verbose and readiness responses.
 type HealthResponse struct {
-	Status    string `json:"status"`
-	Database  string `json:"database"`
-	Timestamp string `json:"timestamp"`
+	Status        string                 `json:"status"`
+	Database      string                 `json:"database,omitempty"`
+	Timestamp     string                 `json:"timestamp"`
+	Version       string                 `json:"version,omitempty"`
+	Commit        string                 `json:"commit,omitempty"`
+	UptimeSeconds int64                  `json:"uptime_seconds,omitempty"`
+	Checks        map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the outcome of a single health check. Status is "pass",
+// "warn" or "fail".
+type HealthCheck struct {
+	Status    string                 `json:"status"`
+	LatencyMS float64                `json:"latency_ms"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Error     string                 `json:"error,omitempty"`
 }
 
-// ErrorResponse represents an error response
+// Stable error codes. Clients should branch on these rather than on the
+// human-readable message, which may change.
+const (
+	ErrorCodeValidationFailed   = "validation_failed"
+	ErrorCodeMalformedBody      = "malformed_body"
+	ErrorCodeInvalidID          = "invalid_id"
+	ErrorCodeOriginNotAllowed   = "origin_not_allowed"
+	ErrorCodeNotFound           = "not_found"
+	ErrorCodeRouteNotFound      = "route_not_found"
+	ErrorCodeDuplicateDate      = "duplicate_date"
+	ErrorCodeDuplicateTag       = "duplicate_tag"
+	ErrorCodePreconditionFailed = "precondition_failed"
+	ErrorCodeInternal           = "internal_error"
+)
+
+// ErrorResponse represents an error response. Code is one of the ErrorCode
+// constants; Fields lists each invalid field of a rejected request.
 type ErrorResponse struct {
-	Error   string                 `json:"error"`
-	Details map[string]interface{} `json:"details,omitempty"`
+	Code      string                 `json:"code"`
+	Error     string                 `json:"error"`
+	Fields    []FieldError           `json:"fields,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+}
+
+// FieldError describes why one field of a request was rejected. Field is
+// the JSON member or query parameter name, with an index for array items
+// (e.g. "tags[2]"); Constraint names the rule that failed, such as
+// "required", "gt" or "max", and Param its argument, if any.
+type FieldError struct {
+	Field      string `json:"field"`
+	Constraint string `json:"constraint"`
+	Param      string `json:"param,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ProblemDetails is an RFC 9457 problem document, sent in place of
+// ErrorResponse to clients that accept application/problem+json. Code,
+// Fields, Details, RequestID and TraceID are extension members.
+type ProblemDetails struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	Code      string                 `json:"code"`
+	Fields    []FieldError           `json:"fields,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	TraceID   string                 `json:"trace_id,omitempty"`
 }
 
 // WeightsResponse represents the response for listing weights
 type WeightsResponse struct {
 	Weights []Weight `json:"weights"`
 }
+
+// AuditEntry represents one recorded change to a weight entry or the goal
+type AuditEntry struct {
+	ID        int             `json:"id"`
+	Entity    string          `json:"entity"`
+	EntityID  *int            `json:"entity_id"`
+	Action    string          `json:"action"`
+	OldValue  json.RawMessage `json:"old_value"`
+	NewValue  json.RawMessage `json:"new_value"`
+	Actor     string          `json:"actor"`
+	SourceIP  *string         `json:"source_ip"`
+	UserAgent *string         `json:"user_agent"`
+	CreatedAt string          `json:"created_at"`
+}
+
+// AuditLogResponse represents a page of the audit log, newest first
+type AuditLogResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int          `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+}
+
+// LiveRequest is a frame sent by a live sync (WebSocket) client. Type is
+// "subscribe", "unsubscribe", "create", "update" or "delete"; ID is echoed
+// in the reply. Changes apply to the weight entry EntityID, or with Entity
+// "goal" to the goal, and Data is the REST request body: a WeightInput to
+// create, a merge patch to update a weight, a GoalInput to update the goal.
+// A non-zero Version applies the change only at that version, like
+// If-Match. LastEventID resumes a subscription after that event.
+type LiveRequest struct {
+	Type        string          `json:"type"`
+	ID          string          `json:"id,omitempty"`
+	Entity      string          `json:"entity,omitempty"`
+	EntityID    int             `json:"entity_id,omitempty"`
+	Version     int             `json:"version,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	LastEventID string          `json:"last_event_id,omitempty"`
+}
+
+// LiveFrame is a frame sent by the live sync server: an "ack" of request ID
+// with the REST status and response body as Data, an "error" with the REST
+// error, or an "event" with a change delta as Data.
+type LiveFrame struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Status  int             `json:"status,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	EventID string          `json:"event_id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   *ErrorResponse  `json:"error,omitempty"`
+}
+
+// Webhook is a subscription delivering change events to a URL. Its secret
+// is never returned.
+type Webhook struct {
+	ID        int      `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Active    bool     `json:"active"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+// WebhookInput represents the input for creating/updating a webhook. The
+// secret is required on creation and kept when omitted on update; a nil
+// Active means true.
+type WebhookInput struct {
+	URL    string   `json:"url" binding:"required,url,max=2048"`
+	Events []string `json:"events" binding:"required,min=1,max=10,dive,oneof=weight.created weight.updated weight.deleted weight.restored goal.reached"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=256"`
+	Active *bool    `json:"active"`
+}
+
+// WebhooksResponse represents the response for listing webhooks
+type WebhooksResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// WebhookDelivery is one event sent, or being sent, to a webhook. Status is
+// "pending", "succeeded" or "failed"; ResponseStatus and Error describe the
+// last attempt.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	EventID        *string         `json:"event_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	Error          *string         `json:"error"`
+	CreatedAt      string          `json:"created_at"`
+	UpdatedAt      string          `json:"updated_at"`
+}
+
+// WebhookDeliveriesResponse represents a page of a webhook's delivery log,
+// newest first
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Total      int               `json:"total"`
+	Limit      int               `json:"limit"`
+	Offset     int               `json:"offset"`
+}
+
+// SyncRequest is a client's offline changes to weight entries. SyncToken is
+// the token returned by the client's last sync, or empty for a first sync.
+type SyncRequest struct {
+	SyncToken string       `json:"sync_token"`
+	Changes   []SyncChange `json:"changes" binding:"max=500"`
+}
+
+// SyncChange is a weight entry created, edited or deleted offline, identified
+// by its client-generated UUID. Only the fields set were changed, at
+// UpdatedAt (RFC 3339); an empty Note clears it and Deleted moves the entry
+// to or from the trash.
+type SyncChange struct {
+	UUID      string   `json:"uuid" binding:"required,uuid"`
+	UpdatedAt string   `json:"updated_at" binding:"required"`
+	Date      *string  `json:"date"`
+	Pounds    *float64 `json:"pounds" binding:"omitempty,gt=0"`
+	Note      *string  `json:"note" binding:"omitempty,max=1000"`
+	Tags      []string `json:"tags" binding:"omitempty,max=20,dive,required,max=50"`
+	Deleted   *bool    `json:"deleted"`
+}
+
+// SyncConflict lists the fields of a client change that lost to a later
+// server-side change and were not applied
+type SyncConflict struct {
+	UUID   string   `json:"uuid"`
+	Fields []string `json:"fields"`
+}
+
+// SyncRejection is a client change that could not be applied at all. Index
+// is its position in the request and Code one of the ErrorCode constants.
+type SyncRejection struct {
+	Index   int          `json:"index"`
+	UUID    string       `json:"uuid"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// SyncResponse carries every server-side change since the request's sync
+// token, including the outcome of the client's own changes, and the token to
+// send next time. When Reset is true the token was empty or unknown and
+// Changes holds every active entry instead, replacing the client's copy.
+// Deleted lists the UUIDs of entries permanently deleted since the token.
+type SyncResponse struct {
+	SyncToken string          `json:"sync_token"`
+	Reset     bool            `json:"reset"`
+	Changes   []Weight        `json:"changes"`
+	Deleted   []string        `json:"deleted"`
+	Conflicts []SyncConflict  `json:"conflicts"`
+	Rejected  []SyncRejection `json:"rejected"`
+}
@@ -0,0 +1,193 @@
+// Package synth generates realistic synthetic weight series for demos, tests
+// and load tests. A series is fully determined by its Options, seed
+// included, so the same options always produce the same entries.
+package synth
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Options shapes a generated series
+type Options struct {
+	// Seed drives every random choice
+	Seed int64
+	// Start is the first day of the series and Days its length; days
+	// skipped as missing still count
+	Start time.Time
+	Days  int
+
+	// StartPounds is the underlying weight on the first day
+	StartPounds float64
+	// TrendPoundsPerWeek is the underlying change per week, negative for a
+	// loss. It stops at GoalPounds, after which the weight drifts around the
+	// goal. A zero GoalPounds never stops the trend.
+	TrendPoundsPerWeek float64
+	GoalPounds         float64
+
+	// WeeklyAmplitude is the size of the weekly cycle: heaviest on Monday
+	// after the weekend, lightest on Friday
+	WeeklyAmplitude float64
+	// Noise is the standard deviation of day-to-day fluctuation
+	Noise float64
+	// MissingRate is the chance that a day has no weigh-in
+	MissingRate float64
+	// PlateauChance is the weekly chance that the trend stalls, for two to
+	// six weeks
+	PlateauChance float64
+	// Holidays adds gains over Thanksgiving, Christmas and a summer vacation
+	// that fade over the following weeks; weigh-ins are sparse on vacation
+	Holidays bool
+}
+
+// Entry is one generated weigh-in
+type Entry struct {
+	// Date is YYYY-MM-DD
+	Date   string
+	Pounds float64
+}
+
+// DefaultOptions returns a year of steady loss from 190 lb toward 165 lb with
+// typical fluctuation, gaps, plateaus and holidays
+func DefaultOptions() Options {
+	return Options{
+		Seed:               1,
+		Start:              time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Days:               365,
+		StartPounds:        190,
+		TrendPoundsPerWeek: -0.75,
+		GoalPounds:         165,
+		WeeklyAmplitude:    0.8,
+		Noise:              0.6,
+		MissingRate:        0.15,
+		PlateauChance:      0.05,
+		Holidays:           true,
+	}
+}
+
+// weekdayCycle scales WeeklyAmplitude by day of the week, Sunday first
+var weekdayCycle = [7]float64{0.4, 1, 0.6, 0.1, -0.4, -1, -0.6}
+
+const (
+	// holidayHalfLife is how quickly a holiday gain fades
+	holidayHalfLife = 10 * 24 * time.Hour
+	// vacationMissingRate replaces MissingRate while on vacation
+	vacationMissingRate = 0.8
+	// driftReversion pulls the weight back toward the goal once reached
+	driftReversion = 0.02
+)
+
+// holiday is a period of gain
+type holiday struct {
+	start, end time.Time
+	gain       float64
+	vacation   bool
+}
+
+// Generate returns the series' weigh-ins in date order, rounded to 0.1 lb
+func Generate(opts Options) []Entry {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	start := time.Date(opts.Start.Year(), opts.Start.Month(), opts.Start.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, opts.Days)
+
+	var holidays []holiday
+	if opts.Holidays {
+		holidays = holidaysBetween(start, end, rng)
+	}
+
+	entries := make([]Entry, 0, opts.Days)
+	level := opts.StartPounds
+	reachedGoal := false
+	plateauDays := 0
+
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		// Draw every random value each day so that changing one option
+		// doesn't reshuffle the rest of the series
+		noise := rng.NormFloat64() * opts.Noise
+		drift := rng.NormFloat64() * 0.1
+		skip := rng.Float64()
+		stall := rng.Float64()
+		plateauLength := 14 + rng.Intn(29)
+
+		pounds := level + weekdayCycle[day.Weekday()]*opts.WeeklyAmplitude + noise
+		missingRate := opts.MissingRate
+		for _, h := range holidays {
+			pounds += h.offset(day)
+			if h.vacation && !day.Before(h.start) && day.Before(h.end) {
+				missingRate = vacationMissingRate
+			}
+		}
+
+		// Move the underlying weight on to the next day: the trend, paused
+		// by plateaus, or drift around the goal once reached
+		if plateauDays > 0 {
+			plateauDays--
+		} else if day.Weekday() == time.Monday && stall < opts.PlateauChance {
+			plateauDays = plateauLength
+		}
+		switch {
+		case reachedGoal:
+			level += drift + (opts.GoalPounds-level)*driftReversion
+		case plateauDays == 0:
+			level += opts.TrendPoundsPerWeek / 7
+		}
+		if opts.GoalPounds > 0 && !reachedGoal && passed(level, opts.GoalPounds, opts.TrendPoundsPerWeek) {
+			level, reachedGoal = opts.GoalPounds, true
+		}
+
+		if skip < missingRate {
+			continue
+		}
+		entries = append(entries, Entry{
+			Date:   day.Format("2006-01-02"),
+			Pounds: math.Round(pounds*10) / 10,
+		})
+	}
+	return entries
+}
+
+// passed reports whether level has reached goal in the trend's direction
+func passed(level, goal, trend float64) bool {
+	if trend < 0 {
+		return level <= goal
+	}
+	return trend > 0 && level >= goal
+}
+
+// offset is the holiday's gain on day: building up over the holiday, then
+// fading
+func (h holiday) offset(day time.Time) float64 {
+	switch {
+	case day.Before(h.start):
+		return 0
+	case day.Before(h.end):
+		return h.gain * float64(day.Sub(h.start)+24*time.Hour) / float64(h.end.Sub(h.start))
+	}
+	halfLives := float64(day.Sub(h.end)) / float64(holidayHalfLife)
+	return h.gain * math.Pow(0.5, halfLives)
+}
+
+// holidaysBetween lists the holidays affecting the days from start to end,
+// including those of the year before, whose gains may still be fading
+func holidaysBetween(start, end time.Time, rng *rand.Rand) []holiday {
+	var holidays []holiday
+	for year := start.Year() - 1; year <= end.Year(); year++ {
+		thanksgiving := fourthThursday(year, time.November)
+		christmas := time.Date(year, time.December, 22, 0, 0, 0, 0, time.UTC)
+		vacation := time.Date(year, time.July, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, rng.Intn(50))
+		holidays = append(holidays,
+			holiday{start: thanksgiving, end: thanksgiving.AddDate(0, 0, 4), gain: 1.5 + rng.Float64()},
+			holiday{start: christmas, end: christmas.AddDate(0, 0, 11), gain: 3 + 2*rng.Float64()},
+			holiday{start: vacation, end: vacation.AddDate(0, 0, 7+rng.Intn(8)), gain: 2 + rng.Float64(), vacation: true},
+		)
+	}
+	return holidays
+}
+
+// fourthThursday returns the fourth Thursday of a month
+func fourthThursday(year int, month time.Month) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(time.Thursday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+21)
+}
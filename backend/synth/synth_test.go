@@ -0,0 +1,162 @@
+package synth
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// quiet returns options with every source of variation switched off
+func quiet() Options {
+	return Options{
+		Seed:        1,
+		Start:       time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Days:        28,
+		StartPounds: 180,
+	}
+}
+
+// byDate indexes entries by date
+func byDate(entries []Entry) map[string]float64 {
+	pounds := make(map[string]float64, len(entries))
+	for _, e := range entries {
+		pounds[e.Date] = e.Pounds
+	}
+	return pounds
+}
+
+func TestGenerate_Deterministic(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Days = 3 * 365
+
+	first, second := Generate(opts), Generate(opts)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Expected the same options to produce the same series")
+	}
+
+	opts.Seed = 2
+	if reflect.DeepEqual(first, Generate(opts)) {
+		t.Error("Expected a different seed to produce a different series")
+	}
+}
+
+func TestGenerate_DatesInOrderAndRange(t *testing.T) {
+	opts := DefaultOptions()
+	entries := Generate(opts)
+
+	last := ""
+	for _, e := range entries {
+		if e.Date <= last {
+			t.Fatalf("Expected ascending unique dates, got %s after %s", e.Date, last)
+		}
+		last = e.Date
+	}
+	if entries[0].Date < "2025-01-01" || last > "2025-12-31" {
+		t.Errorf("Expected dates within 2025, got %s to %s", entries[0].Date, last)
+	}
+
+	// Roughly MissingRate of days are skipped, more on vacation
+	missing := 1 - float64(len(entries))/float64(opts.Days)
+	if missing < 0.1 || missing > 0.3 {
+		t.Errorf("Expected about 15-20%% of days missing, got %.0f%%", missing*100)
+	}
+}
+
+func TestGenerate_Quiet(t *testing.T) {
+	entries := Generate(quiet())
+	if len(entries) != 28 {
+		t.Fatalf("Expected an entry every day, got %d", len(entries))
+	}
+	for _, e := range entries {
+		if e.Pounds != 180 {
+			t.Fatalf("Expected a flat series, got %v on %s", e.Pounds, e.Date)
+		}
+	}
+}
+
+func TestGenerate_TrendStopsAtGoal(t *testing.T) {
+	opts := quiet()
+	opts.Days = 200
+	opts.TrendPoundsPerWeek = -1
+	opts.GoalPounds = 170
+
+	pounds := byDate(Generate(opts))
+	if got := pounds["2025-01-08"]; got != 179 {
+		t.Errorf("Expected 179 after a week, got %v", got)
+	}
+	// Ten weeks to the goal, then drift around it
+	for _, date := range []string{"2025-03-12", "2025-06-01", "2025-07-19"} {
+		if got := pounds[date]; math.Abs(got-170) > 3 {
+			t.Errorf("Expected about 170 on %s, got %v", date, got)
+		}
+	}
+}
+
+func TestGenerate_WeeklyCycle(t *testing.T) {
+	opts := quiet()
+	opts.WeeklyAmplitude = 1
+
+	// 2025-01-06 is a Monday
+	pounds := byDate(Generate(opts))
+	if pounds["2025-01-06"] != 181 || pounds["2025-01-10"] != 179 {
+		t.Errorf("Expected Monday 181 and Friday 179, got %v and %v", pounds["2025-01-06"], pounds["2025-01-10"])
+	}
+}
+
+func TestGenerate_Plateaus(t *testing.T) {
+	opts := quiet()
+	opts.Days = 3 * 365
+	opts.TrendPoundsPerWeek = -0.1
+	opts.PlateauChance = 1
+
+	// With a plateau starting every chance there is, the trend only runs on
+	// the days between them
+	entries := Generate(opts)
+	stalled := 0
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Pounds == entries[i-1].Pounds {
+			stalled++
+		}
+	}
+	if stalled < len(entries)/2 {
+		t.Errorf("Expected mostly stalled days, got %d of %d", stalled, len(entries))
+	}
+}
+
+func TestGenerate_Holidays(t *testing.T) {
+	opts := quiet()
+	opts.Start = time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	opts.Days = 60
+	opts.Holidays = true
+
+	// Thanksgiving's gain is still fading before Christmas
+	pounds := byDate(Generate(opts))
+	before, peak := pounds["2025-12-21"], pounds["2026-01-01"]
+	if before < 180 || before > 181 {
+		t.Errorf("Expected little gain before Christmas, got %v", before)
+	}
+	if gain := peak - before; gain < 2.5 || gain > 5 {
+		t.Errorf("Expected a 3-5 lb gain by New Year, got %v", gain)
+	}
+	if faded := pounds["2026-01-29"]; faded >= peak-1 || faded <= 180 {
+		t.Errorf("Expected the gain to be fading by late January, got %v", faded)
+	}
+}
+
+func TestFourthThursday(t *testing.T) {
+	tests := map[int]string{2024: "2024-11-28", 2025: "2025-11-27", 2026: "2026-11-26"}
+	for year, want := range tests {
+		if got := fourthThursday(year, time.November).Format("2006-01-02"); got != want {
+			t.Errorf("%d: expected %s, got %s", year, want, got)
+		}
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	opts := DefaultOptions()
+	opts.Days = 10 * 365
+	for i := 0; i < b.N; i++ {
+		Generate(opts)
+	}
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sddev/weight-tracker/db"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent   = "00-" + parentTraceID + "-00f067aa0ba902b7-01"
+)
+
+// setupRecorder installs a tracer provider recording spans in memory
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	if _, err := Setup(context.Background(), "none", nil); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func setupDB(t *testing.T) {
+	if err := db.InitDB(":memory:", db.DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+}
+
+func TestMiddleware_ContinuesTraceWithSQLSpans(t *testing.T) {
+	recorder := setupRecorder(t)
+	setupDB(t)
+	db.AddQueryHook(QueryHook{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/weights/:id", func(c *gin.Context) {
+		var count int
+		db.DB.QueryRowContext(c.Request.Context(), "SELECT COUNT(*) FROM weights").Scan(&count)
+		c.String(http.StatusOK, TraceID(c.Request.Context()))
+	})
+
+	req, _ := http.NewRequest("GET", "/weights/1", nil)
+	req.Header.Set("traceparent", traceparent)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Body.String() != parentTraceID {
+		t.Errorf("Expected handler to see trace ID %s, got %s", parentTraceID, w.Body.String())
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	sqlSpan, serverSpan := spans[0], spans[1]
+	if serverSpan.Name() != "GET /weights/:id" || serverSpan.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Unexpected server span %s (%s)", serverSpan.Name(), serverSpan.SpanKind())
+	}
+	if serverSpan.Parent().TraceID().String() != parentTraceID || !serverSpan.Parent().IsRemote() {
+		t.Errorf("Expected server span to continue remote trace, got parent %v", serverSpan.Parent())
+	}
+	if sqlSpan.Name() != "sql select" || sqlSpan.Parent().SpanID() != serverSpan.SpanContext().SpanID() {
+		t.Errorf("Expected sql select span under the server span, got %s with parent %v", sqlSpan.Name(), sqlSpan.Parent())
+	}
+}
+
+func TestQueryHook_SkipsUntracedStatements(t *testing.T) {
+	recorder := setupRecorder(t)
+	setupDB(t)
+	db.AddQueryHook(QueryHook{})
+
+	db.DB.Exec("INSERT INTO weights (date, pounds) VALUES ('2026-01-01', 170.0)")
+
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("Expected no spans without a parent, got %d", len(spans))
+	}
+}
+
+func TestSetup_StdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), "stdout", &buf)
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "work")
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"Name":"work"`) || !strings.Contains(buf.String(), ServiceName) {
+		t.Errorf("Expected exported span in stdout output, got %q", buf.String())
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), "jaeger", nil); err == nil {
+		t.Error("Expected error for unknown exporter")
+	}
+}
@@ -0,0 +1,28 @@
+package version
+
+import "runtime/debug"
+
+// Version and Commit are set at build time with
+//
+//	-ldflags "-X github.com/sddev/weight-tracker/version.Version=1.2.0 -X github.com/sddev/weight-tracker/version.Commit=abc1234"
+//
+// When Commit is not set, the VCS revision stamped by the Go toolchain is used.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+func init() {
+	if Commit != "" {
+		return
+	}
+
+	Commit = "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				Commit = setting.Value
+			}
+		}
+	}
+}
@@ -0,0 +1,506 @@
+// Package webhooks delivers change events to the URLs subscribed to them.
+// Every delivery is signed with its webhook's secret, retried with
+// exponential backoff and logged in the webhook_deliveries table.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/events"
+	"github.com/sddev/weight-tracker/models"
+	"github.com/sddev/weight-tracker/version"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// Ping is the event sent by Test
+const Ping = "ping"
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	// keepDeliveries is how many finished deliveries are logged per webhook
+	keepDeliveries = 100
+	// maxResponseBody is how much of a response is read so the connection
+	// can be reused
+	maxResponseBody = 64 << 10
+)
+
+// Options configure a Dispatcher
+type Options struct {
+	// MaxAttempts is how many times an event is sent before giving up
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubling for each later
+	// one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds each attempt
+	Timeout time.Duration
+	// Concurrency is how many attempts may be in flight at once
+	Concurrency int
+}
+
+// DefaultOptions returns options retrying for about two and a half minutes
+func DefaultOptions() Options {
+	return Options{
+		MaxAttempts: 6,
+		Backoff:     5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+		Timeout:     10 * time.Second,
+		Concurrency: 4,
+	}
+}
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	Event     string          `json:"event"`
+	EventID   string          `json:"event_id,omitempty"`
+	Timestamp string          `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sign returns the signature sent in SignatureHeader: "sha256=" and the hex
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of a delivery. Receivers
+// should also reject timestamps more than a few minutes old.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher sends change events to the webhooks subscribed to them
+type Dispatcher struct {
+	opts   Options
+	client *http.Client
+	// slots limits the attempts in flight
+	slots  chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a dispatcher; it delivers nothing until started
+func New(opts Options) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		opts: opts,
+		client: &http.Client{
+			// A redirect is a misconfigured URL, not a delivery
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		slots:  make(chan struct{}, opts.Concurrency),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start resumes the deliveries a previous run left pending, then delivers
+// the events published to b until b or the dispatcher is closed
+func (d *Dispatcher) Start(b *events.Broker) {
+	// Subscribe first so nothing published while resuming is missed
+	sub := b.Subscribe()
+	d.resume()
+
+	d.wg.Add(1)
+	go d.run(b, sub)
+}
+
+// Close stops delivering and waits for attempts in flight. Deliveries still
+// to be retried stay pending and are resumed by the next Start.
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Test sends a ping to a webhook once, whether or not it is active, and
+// returns the logged delivery
+func (d *Dispatcher) Test(ctx context.Context, webhookID int) (models.WebhookDelivery, error) {
+	var h hook
+	err := db.ReadDB.QueryRowContext(ctx, "SELECT id, url, secret FROM webhooks WHERE id = ?", webhookID).
+		Scan(&h.id, &h.url, &h.secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WebhookDelivery{}, db.ErrNotFound
+	}
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	data, _ := json.Marshal(map[string]int{"webhook_id": webhookID})
+	del, err := d.queue(ctx, h, events.Event{Type: Ping, Data: data})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	result := d.attempt(ctx, del)
+	status := StatusFailed
+	if result.ok() {
+		status = StatusSucceeded
+	}
+	if err := d.record(del, status, 1, result); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return Delivery(ctx, del.id)
+}
+
+// run delivers events from sub, catching up from b's history if it falls
+// behind
+func (d *Dispatcher) run(b *events.Broker, sub *events.Subscription) {
+	defer d.wg.Done()
+	defer func() { sub.Close() }()
+
+	var lastID string
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if ok {
+				lastID = event.ID
+				d.dispatch(event)
+				continue
+			}
+			if !errors.Is(sub.Err(), events.ErrSlow) {
+				return
+			}
+
+			var missed []events.Event
+			sub, missed = b.Resume(lastID)
+			for _, event := range missed {
+				if event.Type == events.Reset {
+					slog.Warn("Webhook deliveries fell behind; some events were not delivered")
+					continue
+				}
+				lastID = event.ID
+				d.dispatch(event)
+			}
+		}
+	}
+}
+
+// hook is the part of a webhook needed to deliver to it
+type hook struct {
+	id          int
+	url, secret string
+}
+
+// delivery is a logged delivery being attempted
+type delivery struct {
+	id      int
+	hook    hook
+	event   string
+	payload []byte
+}
+
+// dispatch logs and starts a delivery of event to every active webhook
+// subscribed to it
+func (d *Dispatcher) dispatch(event events.Event) {
+	hooks, err := subscribed(event.Type)
+	if err != nil {
+		slog.Error("Failed to find webhooks", "event", event.Type, "error", err)
+		return
+	}
+
+	for _, h := range hooks {
+		del, err := d.queue(context.Background(), h, event)
+		if err != nil {
+			slog.Error("Failed to log webhook delivery", "webhook_id", h.id, "event", event.Type, "error", err)
+			continue
+		}
+		d.wg.Add(1)
+		go d.deliver(del, 0)
+	}
+}
+
+// subscribed returns the active webhooks subscribed to eventType
+func subscribed(eventType string) ([]hook, error) {
+	rows, err := db.ReadDB.Query("SELECT id, url, secret, events FROM webhooks WHERE active = ?", true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []hook
+	for rows.Next() {
+		var h hook
+		var eventTypes string
+		if err := rows.Scan(&h.id, &h.url, &h.secret, &eventTypes); err != nil {
+			return nil, err
+		}
+		for _, t := range strings.Split(eventTypes, ",") {
+			if t == eventType {
+				hooks = append(hooks, h)
+				break
+			}
+		}
+	}
+	return hooks, rows.Err()
+}
+
+// queue logs a pending delivery of event to h
+func (d *Dispatcher) queue(ctx context.Context, h hook, event events.Event) (delivery, error) {
+	payload, err := json.Marshal(Payload{
+		Event:     event.Type,
+		EventID:   event.ID,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Data:      event.Data,
+	})
+	if err != nil {
+		return delivery{}, err
+	}
+
+	var eventID interface{}
+	if event.ID != "" {
+		eventID = event.ID
+	}
+
+	del := delivery{hook: h, event: event.Type, payload: payload}
+	query := `INSERT INTO webhook_deliveries (webhook_id, event, event_id, payload, status)
+	          VALUES (?, ?, ?, ?, ?) RETURNING id`
+	err = db.DB.QueryRowContext(ctx, query, h.id, event.Type, eventID, string(payload), StatusPending).Scan(&del.id)
+	return del, err
+}
+
+// resume restarts the pending deliveries to active webhooks
+func (d *Dispatcher) resume() {
+	query := `SELECT d.id, d.event, d.payload, d.attempts, w.id, w.url, w.secret
+	          FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+	          WHERE d.status = ? AND w.active = ?`
+	rows, err := db.ReadDB.Query(query, StatusPending, true)
+	if err != nil {
+		slog.Error("Failed to resume webhook deliveries", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var del delivery
+		var payload string
+		var attempts int
+		if err := rows.Scan(&del.id, &del.event, &payload, &attempts, &del.hook.id, &del.hook.url, &del.hook.secret); err != nil {
+			slog.Error("Failed to resume webhook deliveries", "error", err)
+			return
+		}
+		del.payload = []byte(payload)
+		d.wg.Add(1)
+		go d.deliver(del, attempts)
+	}
+}
+
+// deliver attempts a delivery until it succeeds, runs out of attempts or
+// the dispatcher is closed
+func (d *Dispatcher) deliver(del delivery, attempts int) {
+	defer d.wg.Done()
+
+	for {
+		result := d.attempt(d.ctx, del)
+		// An attempt cut short by Close doesn't count
+		if d.ctx.Err() != nil {
+			return
+		}
+
+		attempts++
+		status := StatusPending
+		switch {
+		case result.ok():
+			status = StatusSucceeded
+		case attempts >= d.opts.MaxAttempts:
+			status = StatusFailed
+			slog.Warn("Webhook delivery failed", "webhook_id", del.hook.id, "delivery_id", del.id,
+				"event", del.event, "attempts", attempts, "status", result.status, "error", result.err)
+		}
+		if err := d.record(del, status, attempts, result); err != nil {
+			slog.Error("Failed to log webhook delivery", "delivery_id", del.id, "error", err)
+		}
+		if status != StatusPending {
+			return
+		}
+
+		timer := time.NewTimer(d.backoff(attempts))
+		select {
+		case <-timer.C:
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.Backoff
+	for i := 1; i < attempts && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		delay = d.opts.MaxBackoff
+	}
+	return delay
+}
+
+// result is the outcome of an attempt: the response status, or why there
+// was no response
+type result struct {
+	status int
+	err    error
+}
+
+// ok reports whether the receiver accepted the delivery
+func (r result) ok() bool {
+	return r.err == nil && r.status >= 200 && r.status < 300
+}
+
+// attempt sends a delivery once
+func (d *Dispatcher) attempt(ctx context.Context, del delivery) result {
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return result{err: ctx.Err()}
+	}
+	defer func() { <-d.slots }()
+
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.hook.url, bytes.NewReader(del.payload))
+	if err != nil {
+		return result{err: err}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "weight-tracker-webhooks/"+version.Version)
+	req.Header.Set(EventHeader, del.event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(del.id))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(del.hook.secret, timestamp, del.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return result{err: err}
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return result{status: resp.StatusCode, err: fmt.Errorf("receiver responded %s", resp.Status)}
+	}
+	return result{status: resp.StatusCode}
+}
+
+// record logs the outcome of a delivery's latest attempt, pruning the
+// webhook's oldest finished deliveries once it is finished
+func (d *Dispatcher) record(del delivery, status string, attempts int, r result) error {
+	var responseStatus, errMessage interface{}
+	if r.status != 0 {
+		responseStatus = r.status
+	}
+	if r.err != nil {
+		errMessage = r.err.Error()
+	}
+
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, error = ?,
+	          updated_at = ` + db.Now() + ` WHERE id = ?`
+	if _, err := db.DB.Exec(query, status, attempts, responseStatus, errMessage, del.id); err != nil {
+		return err
+	}
+	if status == StatusPending {
+		return nil
+	}
+
+	query = `DELETE FROM webhook_deliveries WHERE webhook_id = ? AND status != ? AND id <= (
+	             SELECT id FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?)`
+	_, err := db.DB.Exec(query, del.hook.id, StatusPending, del.hook.id, keepDeliveries)
+	return err
+}
+
+// deliverySelect selects every column scanned by scanDelivery
+const deliverySelect = `SELECT id, webhook_id, event, event_id, payload, status, attempts, response_status, error,
+	created_at, updated_at FROM webhook_deliveries`
+
+// scanDelivery scans a row selected with deliverySelect
+func scanDelivery(row interface{ Scan(...interface{}) error }, del *models.WebhookDelivery) error {
+	var payload string
+	var responseStatus sql.NullInt64
+	err := row.Scan(&del.ID, &del.WebhookID, &del.Event, &del.EventID, &payload, &del.Status, &del.Attempts,
+		&responseStatus, &del.Error, &del.CreatedAt, &del.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	del.Payload = json.RawMessage(payload)
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		del.ResponseStatus = &status
+	}
+	return nil
+}
+
+// Delivery returns a logged delivery
+func Delivery(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	var del models.WebhookDelivery
+	err := scanDelivery(db.ReadDB.QueryRowContext(ctx, deliverySelect+" WHERE id = ?", id), &del)
+	if errors.Is(err, sql.ErrNoRows) {
+		return del, db.ErrNotFound
+	}
+	return del, err
+}
+
+// Deliveries returns a page of a webhook's delivery log, newest first, and
+// the number of deliveries logged
+func Deliveries(ctx context.Context, webhookID, limit, offset int) ([]models.WebhookDelivery, int, error) {
+	var total int
+	err := db.ReadDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ?", webhookID).
+		Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.ReadDB.QueryContext(ctx, deliverySelect+" WHERE webhook_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var del models.WebhookDelivery
+		if err := scanDelivery(rows, &del); err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, del)
+	}
+	return deliveries, total, rows.Err()
+}
@@ -0,0 +1,268 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sddev/weight-tracker/db"
+	"github.com/sddev/weight-tracker/events"
+	"github.com/sddev/weight-tracker/models"
+)
+
+// receiver is a webhook endpoint answering with the given statuses in turn,
+// then 200
+type receiver struct {
+	t        *testing.T
+	server   *httptest.Server
+	statuses []int
+
+	mu       sync.Mutex
+	received []*http.Request
+	bodies   [][]byte
+	got      chan struct{}
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{t: t, statuses: statuses, got: make(chan struct{}, 100)}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		status := http.StatusOK
+		if n := len(r.received); n < len(r.statuses) {
+			status = r.statuses[n]
+		}
+		r.received = append(r.received, req)
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+		r.got <- struct{}{}
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// wait waits for n requests
+func (r *receiver) wait(n int) {
+	r.t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.got:
+		case <-time.After(5 * time.Second):
+			r.t.Fatalf("Timed out waiting for request %d", i+1)
+		}
+	}
+}
+
+func setupDB(t *testing.T) {
+	if err := db.InitDB(":memory:", db.DefaultOptions()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+}
+
+// addWebhook creates a webhook subscribed to eventTypes (comma-separated)
+func addWebhook(t *testing.T, url, eventTypes string, active bool) int {
+	t.Helper()
+	var id int
+	err := db.DB.QueryRow("INSERT INTO webhooks (url, events, secret, active) VALUES (?, ?, ?, ?) RETURNING id",
+		url, eventTypes, "0123456789abcdef", active).Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to add webhook: %v", err)
+	}
+	return id
+}
+
+func testOptions() Options {
+	return Options{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, Timeout: time.Second, Concurrency: 2}
+}
+
+// waitForStatus waits for the delivery to reach status
+func waitForStatus(t *testing.T, id int, status string) models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		del, err := Delivery(context.Background(), id)
+		if err == nil && del.Status == status {
+			return del
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected delivery %d to be %s, got %+v (%v)", id, status, del, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"event":"ping"}`)
+	signature := Sign("secret", "1700000000", body)
+
+	// echo -n '1700000000.{"event":"ping"}' | openssl dgst -sha256 -hmac secret
+	if want := "sha256=4d39bd2442f073b6bc62e95d0297ce25475582a17389ab860abdc778fe1d9f77"; signature != want {
+		t.Errorf("Expected %s, got %s", want, signature)
+	}
+	if !Verify("secret", "1700000000", body, signature) {
+		t.Error("Expected the signature to verify")
+	}
+	for _, tt := range []struct{ secret, timestamp, body string }{
+		{"other", "1700000000", `{"event":"ping"}`},
+		{"secret", "1700000001", `{"event":"ping"}`},
+		{"secret", "1700000000", `{"event":"pong"}`},
+	} {
+		if Verify(tt.secret, tt.timestamp, []byte(tt.body), signature) {
+			t.Errorf("Expected %+v not to verify", tt)
+		}
+	}
+}
+
+func TestDispatcher_Delivers(t *testing.T) {
+	setupDB(t)
+	r := newReceiver(t)
+	subscribed := addWebhook(t, r.server.URL, "weight.created,goal.reached", true)
+	addWebhook(t, r.server.URL, "weight.deleted", true)
+	addWebhook(t, r.server.URL, "weight.created", false)
+
+	broker := events.NewBroker(10, 10)
+	d := New(testOptions())
+	d.Start(broker)
+	defer d.Close()
+
+	broker.Publish(events.WeightCreated, map[string]int{"id": 1})
+	r.wait(1)
+
+	// Only the active webhook subscribed to the event received it
+	deliveries, total, err := Deliveries(context.Background(), subscribed, 10, 0)
+	if err != nil || total != 1 {
+		t.Fatalf("Expected one logged delivery, got %d (%v)", total, err)
+	}
+	del := waitForStatus(t, deliveries[0].ID, StatusSucceeded)
+	if del.Attempts != 1 || del.ResponseStatus == nil || *del.ResponseStatus != http.StatusOK {
+		t.Errorf("Expected one successful attempt, got %+v", del)
+	}
+
+	r.mu.Lock()
+	req, body := r.received[0], r.bodies[0]
+	r.mu.Unlock()
+	if !Verify("0123456789abcdef", req.Header.Get(TimestampHeader), body, req.Header.Get(SignatureHeader)) {
+		t.Errorf("Expected a valid signature, got %s", req.Header.Get(SignatureHeader))
+	}
+	if req.Header.Get(EventHeader) != events.WeightCreated || req.Header.Get(DeliveryHeader) == "" {
+		t.Errorf("Unexpected headers %v", req.Header)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Event != events.WeightCreated ||
+		payload.EventID == "" || string(payload.Data) != `{"id":1}` {
+		t.Errorf("Unexpected payload %s", body)
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	setupDB(t)
+	flaky := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	down := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	flakyID := addWebhook(t, flaky.server.URL, "weight.updated", true)
+	downID := addWebhook(t, down.server.URL, "weight.updated", true)
+
+	broker := events.NewBroker(10, 10)
+	d := New(testOptions())
+	d.Start(broker)
+	defer d.Close()
+
+	broker.Publish(events.WeightUpdated, map[string]int{"id": 1})
+	flaky.wait(3)
+	down.wait(3)
+
+	deliveries, _, _ := Deliveries(context.Background(), flakyID, 10, 0)
+	if del := waitForStatus(t, deliveries[0].ID, StatusSucceeded); del.Attempts != 3 || del.Error != nil {
+		t.Errorf("Expected success on the third attempt, got %+v", del)
+	}
+
+	deliveries, _, _ = Deliveries(context.Background(), downID, 10, 0)
+	del := waitForStatus(t, deliveries[0].ID, StatusFailed)
+	if del.Attempts != 3 || del.ResponseStatus == nil || *del.ResponseStatus != http.StatusInternalServerError || del.Error == nil {
+		t.Errorf("Expected failure after 3 attempts, got %+v", del)
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := New(Options{Backoff: time.Second, MaxBackoff: 5 * time.Second})
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("After %d attempts: expected %s, got %s", attempts, want, got)
+		}
+	}
+}
+
+func TestDispatcher_ResumesPending(t *testing.T) {
+	setupDB(t)
+	r := newReceiver(t)
+	id := addWebhook(t, r.server.URL, "weight.created", true)
+
+	var deliveryID int
+	err := db.DB.QueryRow(`INSERT INTO webhook_deliveries (webhook_id, event, event_id, payload, status, attempts)
+		VALUES (?, 'weight.created', 'x-1', '{"event":"weight.created"}', 'pending', 1) RETURNING id`, id).Scan(&deliveryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(testOptions())
+	d.Start(events.NewBroker(10, 10))
+	defer d.Close()
+
+	r.wait(1)
+	if del := waitForStatus(t, deliveryID, StatusSucceeded); del.Attempts != 2 {
+		t.Errorf("Expected the earlier attempt to count, got %+v", del)
+	}
+}
+
+func TestDispatcher_Test(t *testing.T) {
+	setupDB(t)
+	ok := newReceiver(t)
+	failing := newReceiver(t, http.StatusGone)
+	okID := addWebhook(t, ok.server.URL, "weight.created", false)
+	failingID := addWebhook(t, failing.server.URL, "weight.created", true)
+
+	d := New(testOptions())
+
+	// Inactive webhooks can be tested too
+	del, err := d.Test(context.Background(), okID)
+	if err != nil || del.Event != Ping || del.Status != StatusSucceeded || del.EventID != nil {
+		t.Errorf("Expected a successful ping, got %+v (%v)", del, err)
+	}
+
+	// A test is attempted once
+	del, err = d.Test(context.Background(), failingID)
+	if err != nil || del.Status != StatusFailed || del.Attempts != 1 || *del.ResponseStatus != http.StatusGone {
+		t.Errorf("Expected one failed attempt, got %+v (%v)", del, err)
+	}
+
+	if _, err := d.Test(context.Background(), 99); err != db.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDispatcher_PrunesLog(t *testing.T) {
+	setupDB(t)
+	r := newReceiver(t)
+	id := addWebhook(t, r.server.URL, "weight.created", true)
+	for i := 0; i < keepDeliveries+5; i++ {
+		db.DB.Exec(`INSERT INTO webhook_deliveries (webhook_id, event, payload, status) VALUES (?, 'ping', '{}', 'succeeded')`, id)
+	}
+
+	d := New(testOptions())
+	if _, err := d.Test(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, total, _ := Deliveries(context.Background(), id, 10, 0); total != keepDeliveries {
+		t.Errorf("Expected the log to keep %d deliveries, got %d", keepDeliveries, total)
+	}
+}
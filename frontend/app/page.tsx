@@ -7,7 +7,7 @@ import WeightChart from '@/components/WeightChart';
 import WeightList from '@/components/WeightList';
 import UnitToggle from '@/components/UnitToggle';
 import DateRangeFilter from '@/components/DateRangeFilter';
-import { getWeights, getGoal } from '@/lib/api';
+import { getWeights, getGoal, subscribeToChanges } from '@/lib/api';
 import { getDateRangeFromFilter } from '@/lib/dateUtils';
 import type { Weight, DateRange, Unit } from '@/lib/types';
 
@@ -45,6 +45,18 @@ export default function Home() {
         loadGoal();
     }, [dateRange]);
 
+    // Refresh when another device changes something
+    useEffect(() => {
+        return subscribeToChanges((event) => {
+            if (event !== 'goal.updated') {
+                loadWeights();
+            }
+            if (event === 'goal.updated' || event === 'reset') {
+                loadGoal();
+            }
+        });
+    }, [dateRange]);
+
     const handleWeightAdded = () => {
         loadWeights();
     };
@@ -99,3 +99,29 @@ export async function updateGoal(pounds: number | null): Promise<Goal> {
 
   return response.json();
 }
+
+export type ChangeEvent =
+  | 'weight.created'
+  | 'weight.updated'
+  | 'weight.deleted'
+  | 'weight.restored'
+  | 'goal.updated'
+  | 'reset';
+
+const changeEvents: ChangeEvent[] = [
+  'weight.created',
+  'weight.updated',
+  'weight.deleted',
+  'weight.restored',
+  'goal.updated',
+  'reset',
+];
+
+// Calls onChange for every change made on the server, from any device.
+// The browser reconnects on its own and is sent what it missed, or 'reset'
+// when it should reload everything. Returns a function that unsubscribes.
+export function subscribeToChanges(onChange: (event: ChangeEvent) => void): () => void {
+  const source = new EventSource(`${API_URL}/events`);
+  changeEvents.forEach((event) => source.addEventListener(event, () => onChange(event)));
+  return () => source.close();
+}